@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The nonlinearity a layer applies to the weighted sum of its inputs. Each layer of the network has its own, so
+// e.g. a ReLU hidden layer can feed a softmax output layer. Layers work on column vectors, but activations also
+// accept a matrix of them (one sample per column), which lsuv() uses to push a whole batch through at once.
+type Activation interface {
+	// What the activation is called in model files, and in Keras.
+	name() string
+
+	// The layer's output, given its weighted sum z.
+	activate(z mat.Matrix) mat.Matrix
+
+	// Turns the gradient of the cost with respect to the layer's output a into the gradient with respect to its
+	// weighted sum z. For most activations that's just grad times the slope at each neuron, but softmax mixes
+	// every neuron of a column into every output.
+	backprop(z, a, grad mat.Matrix) mat.Matrix
+}
+
+// An activation with learnable parameters of its own, which get trained by gradient descent right along with the
+// weights.
+type paramActivation interface {
+	Activation
+
+	// The parameters themselves, which step() updates in place.
+	params() []float64
+
+	// The gradient of the cost with respect to params(), given its gradient with respect to the layer's output.
+	paramGrad(z, grad mat.Matrix) []float64
+}
+
+// Gradients of the activation parameters of the [hidden, output] layer, nil for a layer whose activation has none.
+type activationGrads [2][]float64
+
+func (g *activationGrads) add(o activationGrads) {
+	for l := range g {
+		if o[l] == nil {
+			continue
+		}
+		if g[l] == nil {
+			g[l] = make([]float64, len(o[l]))
+		}
+		for i, v := range o[l] {
+			g[l][i] += v
+		}
+	}
+}
+
+func (g *activationGrads) scale(f float64) {
+	for l := range g {
+		for i := range g[l] {
+			g[l][i] *= f
+		}
+	}
+}
+
+type (
+	Sigmoid struct{}
+	ReLU    struct{}
+	Tanh    struct{}
+	Linear  struct{} // Passes the weighted sum through untouched, for regression outputs
+	Softmax struct{} // Turns a layer into probabilities that sum to 1, for classification outputs
+
+	// Smooth relatives of ReLU: close to the identity for large inputs and to 0 for very negative ones, but with
+	// a small dip below 0 in between instead of a hard corner, which tends to make small networks train better.
+	GELU  struct{} // x·Φ(x), Φ being the standard normal CDF (Hendrycks & Gimpel 2016)
+	Swish struct{} // x·σ(x), also known as SiLU (Ramachandran et al. 2017)
+	Mish  struct{} // x·tanh(softplus(x)) (Misra 2019)
+
+	ELU      struct{} // x for positive inputs, eˣ - 1 for negative ones (Clevert et al. 2015)
+	Softplus struct{} // log(1 + eˣ), a smooth ReLU that never quite reaches 0
+	Exp      struct{} // eˣ, for outputs that are rates or counts (the log link, see PoissonNLL)
+
+	// Scaled ELU (Klambauer et al. 2017). Its two constants are picked so that, layer after layer, activations are
+	// pulled towards zero mean and unit variance on their own ("self-normalizing"). That only holds if the weights
+	// start out with variance 1/inputs, so pair it with lecunInit(). Regular dropout breaks the normalization too
+	// (the paper replaces it with "alpha dropout", which this network doesn't have), so leave dropout at 0.
+	SELU struct{}
+)
+
+const (
+	seluAlpha = 1.6732632423543772
+	seluScale = 1.0507009873554805
+)
+
+func activationByName(name string) (Activation, error) {
+	if a, ok, err := wrappedActivation(name); ok {
+		return a, err
+	}
+	for _, a := range []Activation{Sigmoid{}, ReLU{}, Tanh{}, Linear{}, Softmax{}, GELU{}, Swish{}, Mish{}, &PReLU{}, ELU{}, SELU{}, Softplus{}, Exp{}, Maxout{}} {
+		if a.name() == name {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown activation %q", name)
+}
+
+// Gives every layer of the network its own activation. Weights trained with one activation generally don't mean
+// much with another, so this is best done before training.
+func (net *MPNN) setActivations(hidden, output Activation) {
+	net.hidAct, net.outAct = hidden, output
+}
+
+func (Sigmoid) name() string                              { return "sigmoid" }
+func (Sigmoid) activate(z mat.Matrix) mat.Matrix          { return apply(sigmoid, z) }
+func (Sigmoid) backprop(z, a, grad mat.Matrix) mat.Matrix { return mult(grad, sigmoidDerivative(a)) }
+
+func (ReLU) name() string { return "relu" }
+func (ReLU) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return math.Max(0, v) }, z)
+}
+func (ReLU) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return 0
+	}, z))
+}
+
+func (Tanh) name() string { return "tanh" }
+func (Tanh) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return math.Tanh(v) }, z)
+}
+func (Tanh) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(_, _ int, v float64) float64 { return 1 - v*v }, a))
+}
+
+func (Linear) name() string                              { return "linear" }
+func (Linear) activate(z mat.Matrix) mat.Matrix          { return mat.DenseCopyOf(z) }
+func (Linear) backprop(z, a, grad mat.Matrix) mat.Matrix { return mat.DenseCopyOf(grad) }
+
+func (Softmax) name() string { return "softmax" }
+
+// Subtracting the column's largest value first keeps exp() from overflowing, and doesn't change the result.
+func (Softmax) activate(z mat.Matrix) mat.Matrix {
+	r, c := z.Dims()
+	out := mat.NewDense(r, c, nil)
+	for j := 0; j < c; j++ {
+		max := math.Inf(-1)
+		for i := 0; i < r; i++ {
+			max = math.Max(max, z.At(i, j))
+		}
+		total := 0.0
+		for i := 0; i < r; i++ {
+			e := math.Exp(z.At(i, j) - max)
+			out.Set(i, j, e)
+			total += e
+		}
+		for i := 0; i < r; i++ {
+			out.Set(i, j, out.At(i, j)/total)
+		}
+	}
+	return out
+}
+
+// ∂a_i/∂z_k = a_i(δ_ik - a_k), so the gradient works out to a ⊙ (grad - grad·a).
+func (Softmax) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	r, c := a.Dims()
+	out := mat.NewDense(r, c, nil)
+	for j := 0; j < c; j++ {
+		dot := 0.0
+		for i := 0; i < r; i++ {
+			dot += grad.At(i, j) * a.At(i, j)
+		}
+		for i := 0; i < r; i++ {
+			out.Set(i, j, a.At(i, j)*(grad.At(i, j)-dot))
+		}
+	}
+	return out
+}
+
+func (GELU) name() string { return "gelu" }
+func (GELU) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return v * normalCDF(v) }, z)
+}
+func (GELU) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(_, _ int, v float64) float64 {
+		return normalCDF(v) + v*math.Exp(-v*v/2)/math.Sqrt(2*math.Pi)
+	}, z))
+}
+
+func normalCDF(x float64) float64 {
+	return (1 + math.Erf(x/math.Sqrt2)) / 2
+}
+
+func (Swish) name() string { return "swish" }
+func (Swish) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(i, j int, v float64) float64 { return v * sigmoid(i, j, v) }, z)
+}
+func (Swish) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(i, j int, v float64) float64 {
+		s := sigmoid(i, j, v)
+		return s + v*s*(1-s)
+	}, z))
+}
+
+func (Mish) name() string { return "mish" }
+func (Mish) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return v * math.Tanh(softplus(v)) }, z)
+}
+func (Mish) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(i, j int, v float64) float64 {
+		t := math.Tanh(softplus(v))
+		return t + v*(1-t*t)*sigmoid(i, j, v)
+	}, z))
+}
+
+// log(1 + eˣ), written so large x doesn't overflow.
+func softplus(x float64) float64 {
+	if x > 30 {
+		return x
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+func (ELU) name() string { return "elu" }
+func (ELU) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return elu(v) }, z)
+}
+
+// For negative inputs the slope is eˣ, which is just the output + 1.
+func (ELU) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(i, j int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return a.At(i, j) + 1
+	}, z))
+}
+
+func elu(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return math.Expm1(x)
+}
+
+func (SELU) name() string { return "selu" }
+func (SELU) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return seluScale * v
+		}
+		return seluScale * seluAlpha * math.Expm1(v)
+	}, z)
+}
+func (SELU) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(_, _ int, v float64) float64 {
+		if v > 0 {
+			return seluScale
+		}
+		return seluScale * seluAlpha * math.Exp(v)
+	}, z))
+}
+
+func (Softplus) name() string { return "softplus" }
+func (Softplus) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return softplus(v) }, z)
+}
+
+// The slope of softplus is the sigmoid.
+func (Softplus) backprop(z, a, grad mat.Matrix) mat.Matrix { return mult(grad, apply(sigmoid, z)) }
+
+func (Exp) name() string { return "exp" }
+func (Exp) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(_, _ int, v float64) float64 { return math.Exp(v) }, z)
+}
+
+// eˣ is its own slope.
+func (Exp) backprop(z, a, grad mat.Matrix) mat.Matrix { return mult(grad, a) }
+
+// Parametric ReLU (He et al. 2015): x for positive inputs and Alpha·x for negative ones, where every neuron
+// learns its own Alpha. A ReLU neuron whose input is always negative stops learning for good, a PReLU one still
+// passes some gradient back.
+type PReLU struct {
+	Alpha []float64 // Slope of each neuron for negative inputs
+}
+
+// A PReLU for a layer of size neurons, all starting with the same slope (the paper uses 0.25).
+func initPReLU(size int, alpha float64) *PReLU {
+	p := &PReLU{Alpha: make([]float64, size)}
+	for i := range p.Alpha {
+		p.Alpha[i] = alpha
+	}
+	return p
+}
+
+func (p *PReLU) name() string { return "prelu" }
+func (p *PReLU) activate(z mat.Matrix) mat.Matrix {
+	return apply(func(i, _ int, v float64) float64 {
+		if v > 0 {
+			return v
+		}
+		return p.Alpha[i] * v
+	}, z)
+}
+func (p *PReLU) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return mult(grad, apply(func(i, _ int, v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return p.Alpha[i]
+	}, z))
+}
+func (p *PReLU) params() []float64 { return p.Alpha }
+
+// ∂a_i/∂α_i is z_i where z_i is negative and 0 elsewhere.
+func (p *PReLU) paramGrad(z, grad mat.Matrix) []float64 {
+	g := make([]float64, len(p.Alpha))
+	r, c := z.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if v := z.At(i, j); v <= 0 {
+				g[i] += grad.At(i, j) * v
+			}
+		}
+	}
+	return g
+}
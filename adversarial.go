@@ -0,0 +1,92 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// Fast gradient sign method (Goodfellow et al. 2014): builds an adversarial version of a sample's input by
+// nudging every feature by epsilon in whichever direction increases the network's error the most. The changes
+// are tiny, but they're all pointed the wrong way at once, which is often enough to flip the prediction. Useful
+// for testing how robust a trained model (and whatever depends on it) is to small input perturbations.
+func (net *MPNN) fgsm(s Sample, epsilon float64) []float64 {
+	grad := net.inputLossGradient(s.Input, s.Target)
+	adv := make([]float64, len(s.Input))
+	for i, x := range s.Input {
+		adv[i] = x + epsilon*sign(grad.At(i, 0))
+	}
+	return adv
+}
+
+// The gradient of the cost with respect to the input rather than the weights.
+func (net *MPNN) inputLossGradient(input, target []float64) mat.Matrix {
+	c := net.forward(input)
+	return net.inputGradient(c, net.outputGrad(c, target))
+}
+
+// Keeps adversarial inputs inside the range real inputs can take (e.g. 0-1 pixel values).
+func clipInput(x []float64, lo, hi float64) {
+	for i := range x {
+		if x[i] < lo {
+			x[i] = lo
+		} else if x[i] > hi {
+			x[i] = hi
+		}
+	}
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
+// Projected gradient descent attack (Madry et al. 2017), a stronger multi-step version of FGSM: takes `steps`
+// smaller sign-gradient steps, pulling the input back to within epsilon of the original (in every feature) after
+// each one.
+func (net *MPNN) pgd(s Sample, epsilon float64, steps int) []float64 {
+	if steps <= 1 {
+		return net.fgsm(s, epsilon)
+	}
+	// 2.5·epsilon/steps is the step size from the paper, big enough to reach the edge of the ball and move along it.
+	alpha := 2.5 * epsilon / float64(steps)
+	adv := append([]float64(nil), s.Input...)
+	for k := 0; k < steps; k++ {
+		grad := net.inputLossGradient(adv, s.Target)
+		for i := range adv {
+			adv[i] += alpha * sign(grad.At(i, 0))
+			if adv[i] > s.Input[i]+epsilon {
+				adv[i] = s.Input[i] + epsilon
+			} else if adv[i] < s.Input[i]-epsilon {
+				adv[i] = s.Input[i] - epsilon
+			}
+		}
+	}
+	return adv
+}
+
+// Adversarial training: every batch also gets an attacked copy of each of its samples (with the original target),
+// made against the current weights. Training on them teaches the network to give the same answer for anything
+// within Epsilon of an input, hardening it against small perturbations.
+type AdversarialTraining struct {
+	Epsilon float64
+	Steps   int // 1 uses FGSM, more uses PGD with that many steps
+
+	// Keeps attacked inputs inside the range real inputs can take. Ignored if both are 0.
+	Min, Max float64
+}
+
+func (a *AdversarialTraining) augment(net *MPNN, batch []Sample) []Sample {
+	mixed := append([]Sample(nil), batch...)
+	for _, s := range batch {
+		adv := net.pgd(s, a.Epsilon, a.Steps)
+		if a.Min != 0 || a.Max != 0 {
+			clipInput(adv, a.Min, a.Max)
+		}
+		mixed = append(mixed, Sample{Input: adv, Target: s.Target})
+	}
+	return mixed
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/rand"
+)
+
+// Simulated annealing over the network's parameters. Every step nudges a few weights at random and keeps the
+// change if fitness went up, or, if it went down, with probability exp(Δfitness / temperature). Early on the
+// temperature is high and plenty of bad moves are taken, which lets it climb out of local optima that gradient
+// descent would settle into; it cools geometrically to Final, by which point it only ever goes uphill.
+//
+// One fitness evaluation per step makes it cheap per step but slow to get anywhere with many weights, it's best
+// suited to tiny networks and objectives too rugged for gradients to help.
+type Annealing struct {
+	Initial, Final float64 // Temperature at the first and last step
+	Scale          float64 // Standard deviation of the noise added to each nudged weight
+	Fraction       float64 // Share of the weights nudged each step, at least one
+
+	// Higher is better, like Evolution.Fitness.
+	Fitness func(net *MPNN) float64
+
+	// Logs progress every tenth of the steps, nil for defaultLogger.
+	Logger Logger
+
+	rnd *rand.Rand
+}
+
+func initAnnealing(fitness func(net *MPNN) float64) *Annealing {
+	return &Annealing{Initial: 1, Final: 1e-4, Scale: 0.1, Fraction: 0.1, Fitness: fitness, rnd: newRand()}
+}
+
+func (a *Annealing) log() Logger {
+	if a.Logger == nil {
+		return defaultLogger
+	}
+	return a.Logger
+}
+
+// Anneals for the given number of steps, leaving net with the fittest parameters seen (which needn't be where the
+// walk ended up). Returns their fitness.
+func (a *Annealing) Run(net *MPNN, steps int) (float64, error) {
+	if a.Fitness == nil {
+		return 0, fmt.Errorf("no fitness function")
+	}
+	if a.Initial <= 0 || a.Final <= 0 || a.Final > a.Initial {
+		return 0, fmt.Errorf("temperatures must be positive and cool down, got %v to %v", a.Initial, a.Final)
+	}
+	if a.rnd == nil {
+		a.rnd = newRand()
+	}
+	fitness := func(n *MPNN) float64 {
+		f := a.Fitness(n)
+		if math.IsNaN(f) {
+			return math.Inf(-1)
+		}
+		return f
+	}
+
+	scratch := net.clone()
+	current := net.paramVector()
+	currentFit := fitness(&scratch)
+	best, bestFit := append([]float64(nil), current...), currentFit
+	nudged := int(math.Max(1, a.Fraction*float64(len(current))))
+	candidate := make([]float64, len(current))
+	cooling := math.Pow(a.Final/a.Initial, 1/math.Max(1, float64(steps-1)))
+
+	temp, accepted := a.Initial, 0
+	for i := 0; i < steps; i++ {
+		copy(candidate, current)
+		for j := 0; j < nudged; j++ {
+			candidate[a.rnd.Intn(len(candidate))] += a.Scale * a.rnd.NormFloat64()
+		}
+		scratch.setParamVector(candidate)
+		f := fitness(&scratch)
+		if f >= currentFit || a.rnd.Float64() < math.Exp((f-currentFit)/temp) {
+			current, candidate = candidate, current
+			currentFit = f
+			accepted++
+			if f > bestFit {
+				best, bestFit = append(best[:0], current...), f
+			}
+		}
+		if (i+1)%int(math.Max(1, float64(steps/10))) == 0 {
+			a.log().Debug("annealing", "step", i+1, "temperature", temp, "fitness", currentFit, "best", bestFit,
+				"accepted", float64(accepted)/float64(i+1))
+		}
+		temp *= cooling
+	}
+
+	net.setParamVector(best)
+	return bestFit, nil
+}
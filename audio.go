@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/dsp/fourier"
+)
+
+// Reads a WAV file's samples, mixed down to mono and scaled to [-1, 1], and its sample rate. Handles 8, 16, 24 and
+// 32 bit PCM and 32 or 64 bit float, which covers what recorders and audio editors write.
+func readWAV(r io.Reader) (samples []float64, rate int, err error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, 0, fmt.Errorf("reading WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+
+	var format, channels, bits int
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, 0, fmt.Errorf("WAV file has no data chunk")
+		}
+		size := int64(binary.LittleEndian.Uint32(head[4:]))
+		switch string(head[:4]) {
+		case "fmt ":
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil || size < 16 {
+				return nil, 0, fmt.Errorf("reading WAV format: bad fmt chunk")
+			}
+			format = int(binary.LittleEndian.Uint16(fmtChunk[0:]))
+			channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			rate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+			bits = int(binary.LittleEndian.Uint16(fmtChunk[14:]))
+			if format == 0xFFFE && size >= 26 { // WAVE_FORMAT_EXTENSIBLE, the real format starts the sub-format GUID
+				format = int(binary.LittleEndian.Uint16(fmtChunk[24:]))
+			}
+		case "data":
+			if channels == 0 {
+				return nil, 0, fmt.Errorf("WAV data comes before its format")
+			}
+			data := make([]byte, size)
+			n, _ := io.ReadFull(r, data) // Recorders cut off mid-write leave the size too big, keep what's there
+			samples, err = decodePCM(data[:n], format, channels, bits)
+			return samples, rate, err
+		default:
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return nil, 0, fmt.Errorf("WAV file has no data chunk")
+			}
+		}
+		if size%2 == 1 { // Chunks are padded to an even length
+			io.CopyN(io.Discard, r, 1)
+		}
+	}
+}
+
+func decodePCM(data []byte, format, channels, bits int) ([]float64, error) {
+	width := bits / 8
+	var sample func(b []byte) float64
+	switch {
+	case format == 1 && bits == 8:
+		sample = func(b []byte) float64 { return (float64(b[0]) - 128) / 128 } // 8 bit is unsigned
+	case format == 1 && bits == 16:
+		sample = func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case format == 1 && bits == 24:
+		sample = func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case format == 1 && bits == 32:
+		sample = func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case format == 3 && bits == 32:
+		sample = func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case format == 3 && bits == 64:
+		sample = func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }
+	default:
+		return nil, fmt.Errorf("unsupported WAV encoding: format %d, %d bits", format, bits)
+	}
+
+	frame := width * channels
+	samples := make([]float64, len(data)/frame)
+	for i := range samples {
+		for c := 0; c < channels; c++ {
+			samples[i] += sample(data[i*frame+c*width:])
+		}
+		samples[i] /= float64(channels)
+	}
+	return samples, nil
+}
+
+func loadWAV(path string) ([]float64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	samples, rate, err := readWAV(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return samples, rate, nil
+}
+
+// Turns audio into network inputs, the way speech recognizers look at sound: the signal is cut into short
+// overlapping frames, and each frame's spectrum is summed into Mels bands spaced like human pitch perception (the
+// mel scale). The log of the band energies is the log-mel spectrogram; with Coefficients set, a DCT of that gives
+// the MFCCs, a smaller and less correlated summary of the spectrum's shape.
+type AudioFeatures struct {
+	Coefficients int     // MFCCs per frame, 0 for the log-mel energies themselves (13 is usual)
+	Mels         int     // Mel bands, 0 for 40
+	FrameLength  float64 // Seconds per frame, 0 for 0.025
+	FrameStep    float64 // Seconds between frame starts, 0 for 0.01
+
+	// For a fixed size input, the number of frames every clip is cut or padded (with silence) to, see vector().
+	Frames int
+}
+
+func (a AudioFeatures) withDefaults() AudioFeatures {
+	if a.Mels == 0 {
+		a.Mels = 40
+	}
+	if a.FrameLength == 0 {
+		a.FrameLength = 0.025
+	}
+	if a.FrameStep == 0 {
+		a.FrameStep = 0.01
+	}
+	return a
+}
+
+// Values per frame.
+func (a AudioFeatures) width() int {
+	if a.Coefficients > 0 {
+		return a.Coefficients
+	}
+	return a.withDefaults().Mels
+}
+
+// The features of each frame of a signal sampled at rate.
+func (a AudioFeatures) frames(signal []float64, rate int) ([][]float64, error) {
+	a = a.withDefaults()
+	length, step := int(a.FrameLength*float64(rate)), int(a.FrameStep*float64(rate))
+	if length < 2 || step < 1 {
+		return nil, fmt.Errorf("frames too short for a sample rate of %d Hz", rate)
+	}
+	if a.Coefficients > a.Mels {
+		return nil, fmt.Errorf("can't get %d coefficients out of %d mel bands", a.Coefficients, a.Mels)
+	}
+	n := 1
+	for n < length {
+		n *= 2
+	}
+	fft := fourier.NewFFT(n)
+	bank := melFilterbank(a.Mels, n, rate)
+
+	// A Hamming window keeps the frame's cut-off edges from smearing the spectrum.
+	window := make([]float64, length)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(length-1))
+	}
+
+	var frames [][]float64
+	buf := make([]float64, n)
+	var coeffs []complex128
+	for start := 0; start+length <= len(signal) || start == 0; start += step {
+		for i := range buf {
+			buf[i] = 0
+			if i < length && start+i < len(signal) {
+				// Pre-emphasis first: high frequencies are quieter in speech but carry as much information.
+				prev := 0.0
+				if start+i > 0 {
+					prev = signal[start+i-1]
+				}
+				buf[i] = (signal[start+i] - 0.97*prev) * window[i]
+			}
+		}
+		coeffs = fft.Coefficients(coeffs, buf)
+		energies := make([]float64, a.Mels)
+		for m, filter := range bank {
+			for k, w := range filter {
+				if w != 0 {
+					p := real(coeffs[k])*real(coeffs[k]) + imag(coeffs[k])*imag(coeffs[k])
+					energies[m] += w * p / float64(n)
+				}
+			}
+			energies[m] = math.Log(math.Max(energies[m], 1e-10))
+		}
+		if a.Coefficients > 0 {
+			energies = dct(energies, a.Coefficients)
+		}
+		frames = append(frames, energies)
+	}
+	return frames, nil
+}
+
+// A clip's frames joined into one input of Frames × width() values: longer clips are cut short, and shorter ones
+// padded with the features of silence, so clips of any length fit the same network.
+func (a AudioFeatures) vector(signal []float64, rate int) ([]float64, error) {
+	if a.Frames < 1 {
+		return nil, fmt.Errorf("set Frames for fixed size audio inputs")
+	}
+	frames, err := a.frames(signal, rate)
+	if err != nil {
+		return nil, err
+	}
+	silence, _ := a.frames(nil, rate)
+	v := make([]float64, 0, a.Frames*a.width())
+	for i := 0; i < a.Frames; i++ {
+		if i < len(frames) {
+			v = append(v, frames[i]...)
+		} else {
+			v = append(v, silence[0]...)
+		}
+	}
+	return v, nil
+}
+
+// Triangular filters, evenly spaced on the mel scale from 0 Hz to half the sample rate, each a weight for every
+// bin of an n point FFT.
+func melFilterbank(mels, n, rate int) [][]float64 {
+	mel := func(f float64) float64 { return 2595 * math.Log10(1+f/700) }
+	hz := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+	top := mel(float64(rate) / 2)
+	edges := make([]float64, mels+2) // In FFT bins
+	for i := range edges {
+		edges[i] = hz(top*float64(i)/float64(mels+1)) * float64(n) / float64(rate)
+	}
+	bank := make([][]float64, mels)
+	for m := range bank {
+		bank[m] = make([]float64, n/2+1)
+		lo, mid, hi := edges[m], edges[m+1], edges[m+2]
+		for k := range bank[m] {
+			f := float64(k)
+			switch {
+			case f > lo && f <= mid:
+				bank[m][k] = (f - lo) / (mid - lo)
+			case f > mid && f < hi:
+				bank[m][k] = (hi - f) / (hi - mid)
+			}
+		}
+	}
+	return bank
+}
+
+// The first k coefficients of the orthonormal DCT-II of x.
+func dct(x []float64, k int) []float64 {
+	out := make([]float64, k)
+	m := float64(len(x))
+	for i := range out {
+		for j, v := range x {
+			out[i] += v * math.Cos(math.Pi*float64(i)*(float64(j)+0.5)/m)
+		}
+		if i == 0 {
+			out[i] *= math.Sqrt(1 / m)
+		} else {
+			out[i] *= math.Sqrt(2 / m)
+		}
+	}
+	return out
+}
+
+// Loads a directory of labeled clips laid out like the Speech Commands dataset: one subdirectory per class, named
+// after it, holding that class's .wav files. Each clip becomes a sample with a one-hot target, in the order of the
+// returned class names (alphabetical).
+func loadAudioDir(dir string, features AudioFeatures) (data []Sample, classes []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			classes = append(classes, e.Name())
+		}
+	}
+	sort.Strings(classes)
+	if len(classes) == 0 {
+		return nil, nil, fmt.Errorf("%s has no class subdirectories", dir)
+	}
+	for c, class := range classes {
+		files, err := os.ReadDir(filepath.Join(dir, class))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.EqualFold(filepath.Ext(f.Name()), ".wav") {
+				continue
+			}
+			path := filepath.Join(dir, class, f.Name())
+			signal, rate, err := loadWAV(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			input, err := features.vector(signal, rate)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			target := make([]float64, len(classes))
+			target[c] = 1
+			data = append(data, Sample{Input: input, Target: target})
+		}
+	}
+	return data, classes, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Mixup (Zhang et al. 2017): trains on blends of pairs of samples instead of the samples themselves. Each sample
+// in the batch is mixed with another random sample from the same batch,
+//
+//	input  = λ·input₁  + (1-λ)·input₂
+//	target = λ·target₁ + (1-λ)·target₂
+//
+// with λ drawn from Beta(alpha, alpha). With one-hot targets the network learns to give in-between answers for
+// in-between inputs, which keeps it from getting overconfident. Small alphas (0.1 - 0.4) mostly give λ close to
+// 0 or 1, so most blends are only lightly mixed.
+func mixup(batch []Sample, alpha float64, rnd *rand.Rand) []Sample {
+	beta := distuv.Beta{Alpha: alpha, Beta: alpha, Src: rnd}
+	partners := rnd.Perm(len(batch))
+
+	mixed := make([]Sample, len(batch))
+	for i, s := range batch {
+		p := batch[partners[i]]
+		lambda := beta.Rand()
+		mixed[i] = Sample{
+			Input:  blend(s.Input, p.Input, lambda),
+			Target: blend(s.Target, p.Target, lambda),
+		}
+	}
+	return mixed
+}
+
+func blend(a, b []float64, lambda float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = lambda*a[i] + (1-lambda)*b[i]
+	}
+	return out
+}
+
+// Random erasing / cutout (Zhong et al. 2017, DeVries & Taylor 2017) for inputs that are flattened images.
+// Each sample has a chance of getting a random rectangle of pixels blanked out, so the network can't rely on any
+// one part of the image always being visible and copes better with occlusion.
+//
+// Inputs are read row by row, Width*Height values per channel with channels one after another, and the same
+// rectangle is erased from every channel.
+type RandomErasing struct {
+	Width, Height int
+
+	Prob    float64 // Chance each sample gets erased
+	MinArea float64 // Smallest patch, as a fraction of the image area (e.g. 0.02)
+	MaxArea float64 // Largest patch, as a fraction of the image area (e.g. 0.33)
+	Value   float64 // What erased pixels are set to
+}
+
+// Returns the batch with erasing applied, leaving the original samples untouched.
+func (e *RandomErasing) apply(batch []Sample, rnd *rand.Rand) []Sample {
+	out := make([]Sample, len(batch))
+	for i, s := range batch {
+		out[i] = s
+		if rnd.Float64() < e.Prob {
+			out[i].Input = e.erase(s.Input, rnd)
+		}
+	}
+	return out
+}
+
+func (e *RandomErasing) erase(input []float64, rnd *rand.Rand) []float64 {
+	pixels := e.Width * e.Height
+	if pixels == 0 || len(input)%pixels != 0 {
+		return input
+	}
+	out := append([]float64(nil), input...)
+
+	// Pick a patch size and shape, trying again if it doesn't fit inside the image.
+	for try := 0; try < 10; try++ {
+		area := (e.MinArea + rnd.Float64()*(e.MaxArea-e.MinArea)) * float64(pixels)
+		aspect := 0.3 + rnd.Float64()*(1/0.3-0.3)
+		h := int(math.Sqrt(area * aspect))
+		w := int(math.Sqrt(area / aspect))
+		if w < 1 || h < 1 || w > e.Width || h > e.Height {
+			continue
+		}
+
+		x := rnd.Intn(e.Width - w + 1)
+		y := rnd.Intn(e.Height - h + 1)
+		for c := 0; c < len(input); c += pixels {
+			for row := y; row < y+h; row++ {
+				for col := x; col < x+w; col++ {
+					out[c+row*e.Width+col] = e.Value
+				}
+			}
+		}
+		return out
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Deep supervision (Lee et al. 2015): a small classifier of its own reading straight off the hidden layer and
+// predicting the same targets as the network, whose loss is added to the network's during training. The hidden
+// layer then gets an error signal directly, instead of only what's left of one after coming back through the
+// output layer, which is what keeps stacked sigmoids from learning anything when their gradients vanish on the
+// way down.
+//
+// The auxiliary head only exists for training: it's never part of Predict() or of saved models.
+type Auxiliary struct {
+	Weight float64    // How much the auxiliary loss counts next to the network's own
+	Act    Activation // nil for Sigmoid
+	Loss   Loss       // nil for the network's loss
+
+	weights *mat.Dense // The head's own weights, hidden → outputs
+}
+
+func initAuxiliary(weight float64) *Auxiliary {
+	return &Auxiliary{Weight: weight}
+}
+
+func (x *Auxiliary) act() Activation {
+	if x.Act == nil {
+		return Sigmoid{}
+	}
+	return x.Act
+}
+
+func (x *Auxiliary) lossFn(net *MPNN) Loss {
+	if x.Loss == nil {
+		return net.lossFn()
+	}
+	return x.Loss
+}
+
+func (x *Auxiliary) check() error {
+	if _, ok := x.act().(paramActivation); ok || pieces(x.act()) != 1 {
+		return fmt.Errorf("auxiliary head can't use %s", x.act().name())
+	}
+	return nil
+}
+
+// The gradient of Weight times the auxiliary loss with respect to the hidden weights, averaged over the batch,
+// which gets added to the network's own. The head's weights take their gradient descent step here too, at rate.
+func (x *Auxiliary) gradients(net *MPNN, batch []Sample, rate float64) *mat.Dense {
+	if x.weights == nil || !sameShape(x.weights, net.outWeights) {
+		x.weights = mat.NewDense(net.out, net.hidden, uniformInit(net.out, net.hidden))
+	}
+	act, loss := x.act(), x.lossFn(net)
+	hidGrad, auxGrad := zeros(net.hidWeights), zeros(x.weights)
+	for _, s := range batch {
+		c := net.forwardSample(s, nil)
+		z := dot(x.weights, c.inLayerWeightsOut)
+		a := act.activate(z)
+		out := mat.Col(nil, 0, a)
+		delta := act.backprop(z, a, mat.NewDense(len(out), 1, loss.grad(out, s.Target)))
+		auxGrad.Add(auxGrad, dot(delta, c.inLayerWeightsOut.T()))
+
+		hiddenDelta := net.hidAct.backprop(c.inLayerWeightsIn, c.inLayerWeightsOut, dot(x.weights.T(), delta))
+		if c.sparseIn != nil {
+			hidGrad.Add(hidGrad, c.sparseIn.outer(hiddenDelta))
+		} else {
+			hidGrad.Add(hidGrad, dot(hiddenDelta, c.inLayer.T()))
+		}
+	}
+	f := x.Weight / float64(len(batch))
+	hidGrad.Scale(f, hidGrad)
+	auxGrad.Scale(f, auxGrad)
+	x.weights.Sub(x.weights, scale(rate, auxGrad))
+	return hidGrad
+}
+
+// The head's own predictions, to see how far the hidden layer alone gets on the task.
+func (x *Auxiliary) Predict(net *MPNN, input []float64) ([]float64, error) {
+	if x.weights == nil {
+		return nil, fmt.Errorf("auxiliary head hasn't been trained")
+	}
+	if len(input) != net.in {
+		return nil, fmt.Errorf("input has %d values, network expects %d", len(input), net.in)
+	}
+	c := net.forward(input)
+	return mat.Col(nil, 0, x.act().activate(dot(x.weights, c.inLayerWeightsOut))), nil
+}
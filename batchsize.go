@@ -0,0 +1,75 @@
+package main
+
+import (
+	"runtime"
+	"time"
+)
+
+// How one batch size did in findBatchSize().
+type BatchSizeProbe struct {
+	Size          int
+	SamplesPerSec float64
+	BytesPerBatch uint64 // Allocated while working out one batch's gradient
+}
+
+// How long each batch size is timed for, at the least.
+const batchProbeTime = 100 * time.Millisecond
+
+// Suggests a BatchSize for this Trainer on this machine. Batch sizes 1, 2, 4, ... up to the size of data are
+// each timed working out gradients (with the Trainer's Workers, which is where bigger batches pay off: they give
+// every goroutine more to do per synchronization), and how much memory a batch allocates is measured along the
+// way. Probing stops once a batch allocates more than maxBytes (0 for no limit).
+//
+// The suggestion is the largest size whose throughput is within 10% of the best one seen, the biggest batch that
+// the machine handles about as efficiently as any. Treat it as an upper bound: bigger batches also mean fewer
+// weight updates per epoch, which can slow learning down. Nothing is trained, the network is left as it was.
+func (t *Trainer) findBatchSize(data []Sample, maxBytes uint64) (int, []BatchSizeProbe) {
+	var probes []BatchSizeProbe
+	for size := 1; size <= len(data); size *= 2 {
+		p := t.probeBatchSize(data, size)
+		if maxBytes > 0 && p.BytesPerBatch > maxBytes {
+			break
+		}
+		probes = append(probes, p)
+	}
+
+	best := 0.0
+	for _, p := range probes {
+		if p.SamplesPerSec > best {
+			best = p.SamplesPerSec
+		}
+	}
+	suggestion := 1
+	for _, p := range probes {
+		if p.SamplesPerSec >= 0.9*best {
+			suggestion = p.Size
+		}
+	}
+	return suggestion, probes
+}
+
+func (t *Trainer) probeBatchSize(data []Sample, size int) BatchSizeProbe {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	batches, samples, offset := 0, 0, 0
+	start := time.Now()
+	for time.Since(start) < batchProbeTime || batches < 3 {
+		if offset+size > len(data) {
+			offset = 0
+		}
+		t.gradients(t.net, data[offset:offset+size])
+		offset += size
+		batches++
+		samples += size
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return BatchSizeProbe{
+		Size:          size,
+		SamplesPerSec: float64(samples) / elapsed.Seconds(),
+		BytesPerBatch: (after.TotalAlloc - before.TotalAlloc) / uint64(batches),
+	}
+}
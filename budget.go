@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Whether Trainer.Budget has run out for the current Fit().
+func (t *Trainer) outOfTime() bool {
+	return t.Budget > 0 && time.Since(t.fitStart) >= t.Budget
+}
+
+// Trains for as long as the budget allows rather than for a number of epochs, handy when it's the wall clock
+// that's fixed ("whatever it gets to in 10 minutes"). The deadline is checked between batches, so training
+// stops at most a batch late and never leaves the weights half updated.
+//
+// The network the Trainer holds ends up wherever training stopped, the copy returned is the one that did best
+// along the way: lowest loss on Trainer.Validation, or on the training data without it. There's no real epoch
+// count, so callbacks like Dashboard and Webhook leave out totals and ETAs while Trainer.Budget is set.
+func (t *Trainer) FitFor(data []Sample, budget time.Duration) (MPNN, error) {
+	if budget <= 0 {
+		// Fit() would never stop.
+		return MPNN{}, fmt.Errorf("time budget must be positive, got %s", budget)
+	}
+	best := &bestTracker{data: data, loss: math.Inf(1)}
+	saved, callbacks := t.Budget, t.Callbacks
+	t.Budget, t.Callbacks = budget, append(append([]Callback(nil), callbacks...), best)
+	defer func() { t.Budget, t.Callbacks = saved, callbacks }()
+
+	if err := t.Fit(data, math.MaxInt32); err != nil {
+		return MPNN{}, err
+	}
+	if best.net == nil {
+		// Out of time before the first epoch ended.
+		return t.net.clone(), nil
+	}
+	return *best.net, nil
+}
+
+// Keeps a copy of the network from the epoch with the lowest loss.
+type bestTracker struct {
+	data []Sample
+	loss float64
+	net  *MPNN
+}
+
+func (b *bestTracker) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	loss := stats.ValLoss
+	if t.Validation == nil {
+		loss = t.net.meanLoss(b.data)
+	}
+	if loss < b.loss {
+		c := t.net.clone()
+		b.loss, b.net = loss, &c
+	}
+	return false
+}
+
+// A deep copy of the network, sharing nothing with the original.
+func (net *MPNN) clone() MPNN {
+	tensors := map[string]*mat.Dense{}
+	for _, t := range net.tensors() {
+		tensors[t.name] = mat.DenseCopyOf(t.m)
+	}
+	c, err := networkFromParts(net.metadata(), lookupTensor(tensors))
+	if err != nil {
+		// Everything came from a working network, so it can't fail to fit back together.
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,65 @@
+package main
+
+import "math"
+
+// Hooks into Trainer.Fit() to watch training as it goes, and step in if needed.
+type Callback interface {
+	// Called after every epoch with that epoch's stats. Returning true stops training after this epoch (the
+	// rest of the callbacks still get called).
+	OnEpochEnd(t *Trainer, stats EpochStats) (stop bool)
+}
+
+// Callbacks that also want to know when Fit() is over implement this too. err is what Fit() is about to return,
+// nil if training ran to the end or a callback stopped it.
+type fitEndCallback interface {
+	OnFitEnd(t *Trainer, err error)
+}
+
+type OverfitAction int
+
+const (
+	OverfitWarn            OverfitAction = iota // Just print a warning
+	OverfitIncreaseDropout                      // Raise the network's dropout by DropoutStep
+	OverfitStop                                 // Stop training
+)
+
+// Watches for the network starting to memorize its training data: the validation loss pulling away from the
+// training loss. Once the validation loss has been more than Gap (relative, 0.2 = 20%) above the training loss
+// for Patience epochs in a row, it takes its Action, and then waits another Patience epochs before acting again.
+// Needs Trainer.Validation to be set, and does nothing without it.
+type OverfitDetector struct {
+	Gap         float64
+	Patience    int
+	Action      OverfitAction
+	DropoutStep float64 // How much OverfitIncreaseDropout raises dropout by each time, capped at 0.9
+
+	streak int
+}
+
+func (o *OverfitDetector) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	if t.Validation == nil || stats.TrainLoss <= 0 {
+		return false
+	}
+	gap := stats.ValLoss/stats.TrainLoss - 1
+	if gap <= o.Gap {
+		o.streak = 0
+		return false
+	}
+	o.streak++
+	if o.streak < o.Patience {
+		return false
+	}
+	o.streak = 0
+
+	t.log().Warn("validation loss pulling away from training loss, the network may be overfitting",
+		"epoch", stats.Epoch, "val_loss", stats.ValLoss, "loss", stats.TrainLoss, "gap", gap)
+	switch o.Action {
+	case OverfitIncreaseDropout:
+		t.net.dropout = math.Min(t.net.dropout+o.DropoutStep, 0.9)
+		t.log().Info("raising dropout", "dropout", t.net.dropout)
+	case OverfitStop:
+		t.log().Info("stopping training", "epoch", stats.Epoch)
+		return true
+	}
+	return false
+}
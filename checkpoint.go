@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Saves the network to Dir (or Storage) every Every epochs as it trains, as epoch-000012.mpnn and so on, so a long run can be
+// picked up again (or rolled back) from any of them. Left alone that's a file per epoch, so it cleans up after
+// itself: only the KeepLast most recent checkpoints and the KeepBest ones with the lowest validation loss are
+// kept, and the rest are deleted. With both at 0 every checkpoint is kept. KeepBest needs Trainer.Validation.
+//
+// Checkpoints are written atomically (see Storage.Put()), and loadLatestCheckpoint() picks a run back up from the
+// newest one that loads. Only files it wrote itself are ever deleted. A checkpoint that fails to save doesn't stop
+// training: the error is logged and kept in Err.
+type Checkpointer struct {
+	Dir      string
+	Storage  Storage // Used instead of Dir when set, e.g. an S3Storage
+	Every    int     // 0 saves every epoch
+	KeepLast int
+	KeepBest int
+
+	Err   error
+	saved []checkpoint
+}
+
+type checkpoint struct {
+	name  string
+	epoch int
+	loss  float64
+}
+
+func (c *Checkpointer) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	if c.Every > 1 && (stats.Epoch+1)%c.Every != 0 {
+		return false
+	}
+	name := fmt.Sprintf("epoch-%06d.mpnn", stats.Epoch)
+	if err := t.net.saveTo(c.store(), name); err != nil {
+		c.fail(t, err)
+		return false
+	}
+	t.log().Debug("saved checkpoint", "epoch", stats.Epoch, "name", name)
+
+	// Replaces any earlier entry for the same file, e.g. after the history was reset.
+	for i, s := range c.saved {
+		if s.name == name {
+			c.saved = append(c.saved[:i], c.saved[i+1:]...)
+			break
+		}
+	}
+	c.saved = append(c.saved, checkpoint{name: name, epoch: stats.Epoch, loss: stats.ValLoss})
+	c.cleanup(t)
+	return false
+}
+
+// The checkpoints the retention policy keeps, in the order they were saved.
+func (c *Checkpointer) keep(t *Trainer) []checkpoint {
+	if c.KeepLast <= 0 && c.KeepBest <= 0 {
+		return c.saved
+	}
+	keep := map[string]bool{}
+	for i := len(c.saved) - 1; i >= 0 && i >= len(c.saved)-c.KeepLast; i-- {
+		keep[c.saved[i].name] = true
+	}
+	if c.KeepBest > 0 && t.Validation != nil {
+		byLoss := append([]checkpoint(nil), c.saved...)
+		sort.SliceStable(byLoss, func(i, j int) bool { return byLoss[i].loss < byLoss[j].loss })
+		for i := 0; i < c.KeepBest && i < len(byLoss); i++ {
+			keep[byLoss[i].name] = true
+		}
+	}
+	var kept []checkpoint
+	for _, s := range c.saved {
+		if keep[s.name] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// Deletes every checkpoint the policy doesn't keep.
+func (c *Checkpointer) cleanup(t *Trainer) {
+	kept := c.keep(t)
+	if len(kept) == len(c.saved) {
+		return
+	}
+	keep := map[string]bool{}
+	for _, s := range kept {
+		keep[s.name] = true
+	}
+	for _, s := range c.saved {
+		if keep[s.name] {
+			continue
+		}
+		if err := c.store().Remove(s.name); err != nil {
+			c.fail(t, err)
+			continue
+		}
+		t.log().Debug("removed checkpoint", "epoch", s.epoch, "name", s.name)
+	}
+	c.saved = kept
+}
+
+// The name of the checkpoint with the lowest validation loss, or the latest one without validation data. Empty if
+// nothing has been saved.
+func (c *Checkpointer) Best(t *Trainer) string {
+	if len(c.saved) == 0 {
+		return ""
+	}
+	best := c.saved[len(c.saved)-1]
+	if t.Validation != nil {
+		for _, s := range c.saved {
+			if s.loss < best.loss {
+				best = s
+			}
+		}
+	}
+	return best.name
+}
+
+func (c *Checkpointer) store() Storage {
+	if c.Storage != nil {
+		return c.Storage
+	}
+	return DirStorage(c.Dir)
+}
+
+func (c *Checkpointer) fail(t *Trainer, err error) {
+	c.Err = err
+	t.log().Error("checkpoint failed", "dir", c.Dir, "err", err)
+}
+
+// Loads the most recent checkpoint in storage that's intact, for resuming a run (use DirStorage(dir) for a local
+// directory). Checkpoints are saved atomically, but a file can still be damaged some other way (a disk filling up
+// mid-copy, a sync that was interrupted), so any that don't load are skipped with a warning and the one before is
+// tried, back to the oldest. Also returns the name of the checkpoint loaded.
+func loadLatestCheckpoint(s Storage, log Logger) (MPNN, string, error) {
+	if log == nil {
+		log = defaultLogger
+	}
+	names, err := s.List("epoch-")
+	if err != nil {
+		return MPNN{}, "", err
+	}
+	// The epoch numbers are zero padded, so name order is epoch order.
+	for i := len(names) - 1; i >= 0; i-- {
+		if !strings.HasSuffix(names[i], ".mpnn") {
+			continue // Including a DirStorage temporary file left by a crash
+		}
+		net, err := loadFrom(s, names[i])
+		if err == nil {
+			return net, names[i], nil
+		}
+		log.Warn("skipping damaged checkpoint", "name", names[i], "err", err)
+	}
+	return MPNN{}, "", fmt.Errorf("no usable checkpoint found")
+}
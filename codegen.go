@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Go function bodies for each activation that works one neuron at a time, in terms of the neuron's weighted sum
+// v. They do the same arithmetic in the same order as the network's own, so the generated code gives exactly the
+// same outputs.
+var goActivations = map[string]string{
+	"sigmoid":  "return 1 / (1 + math.Exp(-v))",
+	"relu":     "return math.Max(0, v)",
+	"tanh":     "return math.Tanh(v)",
+	"gelu":     "return v * ((1 + math.Erf(v/math.Sqrt2)) / 2)",
+	"swish":    "return v * (1 / (1 + math.Exp(-v)))",
+	"mish":     "s := v\nif v <= 30 {\ns = math.Log1p(math.Exp(v))\n}\nreturn v * math.Tanh(s)",
+	"elu":      "if v > 0 {\nreturn v\n}\nreturn math.Expm1(v)",
+	"selu":     "if v > 0 {\nreturn 1.0507009873554805 * v\n}\nreturn 1.0507009873554805 * 1.6732632423543772 * math.Expm1(v)",
+	"softplus": "if v > 30 {\nreturn v\n}\nreturn math.Log1p(math.Exp(v))",
+	"exp":      "return math.Exp(v)",
+}
+
+// Writes the network as a standalone Go source file: the weights as package level arrays, and a function fn that
+// runs the network on them with nothing but the standard library. A model small enough to fit in a source file
+// can then be compiled straight into another program, with no model file to ship and no dependency on this one.
+//
+// fn takes and returns fixed size arrays, so the compiler catches inputs of the wrong size. Maxout, normalized and
+// multi-head layers have no equivalent in the generated code and are refused.
+func (net *MPNN) exportGo(w io.Writer, pkg, fn string) error {
+	if !token.IsIdentifier(pkg) || !token.IsIdentifier(fn) {
+		return fmt.Errorf("%q and %q have to be Go identifiers", pkg, fn)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s runs a %d-%d-%d network (%s hidden layer, %s output layer) on an input.\n",
+		fn, net.in, net.hidden, net.out, net.hidAct.name(), net.outAct.name())
+	fmt.Fprintf(&b, "func %s(input [%d]float64) (output [%d]float64) {\n", fn, net.in, net.out)
+	fmt.Fprintf(&b, "var hidden [%d]float64\n", net.hidden)
+
+	var vars bytes.Buffer
+	unexported := strings.ToLower(fn[:1]) + fn[1:]
+	helpers := map[string]bool{}
+	for l, layer := range []struct {
+		in, out string
+		w       *mat.Dense
+		act     Activation
+	}{{"input", "hidden", net.hidWeights, net.hidAct}, {"hidden", "output", net.outWeights, net.outAct}} {
+		name := unexported + [2]string{"Hidden", "Output"}[l]
+		writeGoMatrix(&vars, name, layer.w)
+		fmt.Fprintf(&b, "for i := range %s {\nv := 0.0\nfor j, x := range %s {\nv += %s[i][j] * x\n}\n",
+			layer.out, layer.in, name)
+
+		switch act := layer.act.(type) {
+		case Softmax:
+			// Needs every weighted sum first, so it gets its own pass below.
+			fmt.Fprintf(&b, "%s[i] = v\n}\n", layer.out)
+			fmt.Fprintf(&b, "{\ntop := math.Inf(-1)\nfor _, v := range %[1]s {\ntop = math.Max(top, v)\n}\n"+
+				"total := 0.0\nfor i, v := range %[1]s {\n%[1]s[i] = math.Exp(v - top)\ntotal += %[1]s[i]\n}\n"+
+				"for i := range %[1]s {\n%[1]s[i] /= total\n}\n}\n", layer.out)
+		case *PReLU:
+			fmt.Fprintf(&vars, "var %sSlopes = [%d]float64{%s}\n\n", name, len(act.Alpha), goFloats(act.Alpha))
+			fmt.Fprintf(&b, "if v <= 0 {\nv *= %sSlopes[i]\n}\n%s[i] = v\n}\n", name, layer.out)
+		case Linear:
+			fmt.Fprintf(&b, "%s[i] = v\n}\n", layer.out)
+		default:
+			body, ok := goActivations[act.name()]
+			if !ok {
+				return fmt.Errorf("can't export a %s layer to Go", act.name())
+			}
+			helper := unexported + strings.ToUpper(act.name()[:1]) + act.name()[1:]
+			fmt.Fprintf(&b, "%s[i] = %s(v)\n}\n", layer.out, helper)
+			if !helpers[helper] {
+				helpers[helper] = true
+				fmt.Fprintf(&vars, "func %s(v float64) float64 {\n%s\n}\n\n", helper, body)
+			}
+		}
+	}
+	b.WriteString("return output\n}\n\n")
+	b.Write(vars.Bytes())
+
+	header := fmt.Sprintf("// Code generated by mpnn export; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if strings.Contains(b.String(), "math.") {
+		header += "import \"math\"\n\n"
+	}
+	out, err := format.Source([]byte(header + b.String()))
+	if err != nil {
+		return fmt.Errorf("generating Go: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func writeGoMatrix(w io.Writer, name string, m *mat.Dense) {
+	r, c := m.Dims()
+	fmt.Fprintf(w, "var %s = [%d][%d]float64{\n", name, r, c)
+	for i := 0; i < r; i++ {
+		fmt.Fprintf(w, "{%s},\n", goFloats(m.RawRowView(i)))
+	}
+	fmt.Fprintf(w, "}\n\n")
+}
+
+// Shortest form that parses back to exactly the same float64.
+func goFloats(xs []float64) string {
+	parts := make([]string, len(xs))
+	for i, x := range xs {
+		parts[i] = strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mpnn export --format go|csv|coreml [-o path] [--package name] [--func name] model.mpnn
+func runExport(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "go", "go, csv or coreml")
+	out := fs.String("o", "", "where to write it: a file, or a directory for csv (go writes to stdout without it)")
+	pkg := fs.String("package", "main", "package of the generated Go file")
+	fn := fs.String("func", "Predict", "name of the generated Go function")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return fmt.Errorf("usage: mpnn export --format go|csv|coreml [-o path] [--package name] [--func name] <model.mpnn>")
+	}
+	net, err := loadMPNN(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "go":
+		if *out == "" {
+			return net.exportGo(w, *pkg, *fn)
+		}
+		var buf bytes.Buffer
+		if err := net.exportGo(&buf, *pkg, *fn); err != nil {
+			return err
+		}
+		return os.WriteFile(*out, buf.Bytes(), 0o644)
+	case "csv", "coreml":
+		if *out == "" {
+			return fmt.Errorf("exporting to %s needs -o", *format)
+		}
+		if *format == "csv" {
+			return net.exportCSV(*out)
+		}
+		return net.exportCoreML(*out)
+	}
+	return fmt.Errorf("unknown export format %q", *format)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// How one layer's parameters differ between two networks.
+type LayerDiff struct {
+	Name    string // As stored in model files, e.g. "hidden.weight"
+	MaxAbs  float64
+	MeanAbs float64
+	Changed int // Parameters that aren't exactly equal
+	Total   int
+}
+
+// Compares every set of parameters the two networks would save (weights, and learned activation parameters), e.g.
+// to check a checkpoint is the network it should be, or to see which layer a training run actually moved. The
+// networks need the same shape.
+func Compare(a, b *MPNN) ([]LayerDiff, error) {
+	at, bt := a.tensors(), b.tensors()
+	if len(at) != len(bt) {
+		return nil, fmt.Errorf("networks have %d and %d layers of parameters", len(at), len(bt))
+	}
+	diffs := make([]LayerDiff, len(at))
+	for i := range at {
+		if at[i].name != bt[i].name {
+			return nil, fmt.Errorf("layer %d is %s in one network and %s in the other", i, at[i].name, bt[i].name)
+		}
+		diff, err := compareLayer(at[i].m, bt[i].m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", at[i].name, err)
+		}
+		diff.Name = at[i].name
+		diffs[i] = diff
+	}
+	return diffs, nil
+}
+
+func compareLayer(a, b *mat.Dense) (LayerDiff, error) {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	if ar != br || ac != bc {
+		return LayerDiff{}, fmt.Errorf("shapes %dx%d and %dx%d don't match", ar, ac, br, bc)
+	}
+	d := LayerDiff{Total: ar * ac}
+	for i := 0; i < ar; i++ {
+		for j := 0; j < ac; j++ {
+			delta := math.Abs(a.At(i, j) - b.At(i, j))
+			if a.At(i, j) != b.At(i, j) {
+				d.Changed++
+			}
+			d.MaxAbs = math.Max(d.MaxAbs, delta)
+			d.MeanAbs += delta / float64(d.Total)
+		}
+	}
+	return d, nil
+}
+
+func printDiff(w io.Writer, diffs []LayerDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "layer\tmax |Δ|\tmean |Δ|\tchanged\t")
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%.6g\t%.6g\t%d/%d\t\n", d.Name, d.MaxAbs, d.MeanAbs, d.Changed, d.Total)
+	}
+	return tw.Flush()
+}
+
+// mpnn diff a.mpnn b.mpnn
+func runDiff(args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: mpnn diff <a.mpnn> <b.mpnn>")
+	}
+	a, err := loadMPNN(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := loadMPNN(args[1])
+	if err != nil {
+		return err
+	}
+	diffs, err := Compare(&a, &b)
+	if err != nil {
+		return err
+	}
+	return printDiff(w, diffs)
+}
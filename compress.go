@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Model files are mostly float64 weights, which compress well, so save() compresses them when the path ends in
+// .gz or .zst. Loading doesn't care about the name, the compression is detected from the first few bytes.
+
+type compression int
+
+const (
+	noCompression compression = iota
+	gzipCompression
+	zstdCompression
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func compressionFor(path string) compression {
+	switch filepath.Ext(path) {
+	case ".gz":
+		return gzipCompression
+	case ".zst":
+		return zstdCompression
+	}
+	return noCompression
+}
+
+func compressWriter(w io.Writer, c compression) (io.WriteCloser, error) {
+	switch c {
+	case gzipCompression:
+		return gzip.NewWriter(w), nil
+	case zstdCompression:
+		return zstd.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+// Wraps r in a decompressor if it starts with a gzip or zstd header, otherwise hands r back untouched.
+func decompressReader(r *bufio.Reader) (io.ReadCloser, error) {
+	switch c := sniffCompression(r); c {
+	case gzipCompression:
+		return gzip.NewReader(r)
+	case zstdCompression:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	}
+	return io.NopCloser(r), nil
+}
+
+func sniffCompression(r *bufio.Reader) compression {
+	// Peek returns fewer bytes on short input, which just won't match.
+	head, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzipCompression
+	case bytes.HasPrefix(head, zstdMagic):
+		return zstdCompression
+	}
+	return noCompression
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
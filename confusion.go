@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// Fraction of class t's samples that were guessed as class p, so rows of very different sizes shade the same way.
+func confusionShare(confusion [][]int, t, p int) float64 {
+	total := 0
+	for _, n := range confusion[t] {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(confusion[t][p]) / float64(total)
+}
+
+// Prints the confusion matrix as a table with the true classes down the side and the guesses along the top. Each
+// cell's background gets brighter the bigger the share of its row it holds, correct guesses (the diagonal) are
+// green and mistakes red, so the classes that get mixed up stand out at a glance.
+func printConfusionMatrix(confusion [][]int, labels []string) {
+	if labels == nil {
+		labels = make([]string, len(confusion))
+		for i := range labels {
+			labels[i] = fmt.Sprint(i)
+		}
+	}
+	width := len("true\\guess")
+	for _, l := range labels {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	cell := 4
+	for _, row := range confusion {
+		for _, n := range row {
+			if w := len(fmt.Sprint(n)); w > cell {
+				cell = w
+			}
+		}
+	}
+	for _, l := range labels {
+		if len(l) > cell {
+			cell = len(l)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s", width, "true\\guess")
+	for _, l := range labels {
+		fmt.Fprintf(&b, " %*s", cell, l)
+	}
+	b.WriteString("\n")
+	for t, row := range confusion {
+		fmt.Fprintf(&b, "%-*s", width, labels[t])
+		for p, n := range row {
+			// The 24 steps of the 256-colour greyscale ramp (232-255).
+			shade := 232 + int(confusionShare(confusion, t, p)*23+0.5)
+			fg := "31" // Red
+			if t == p {
+				fg = "32" // Green
+			}
+			if n == 0 {
+				fg = "90" // Grey
+			}
+			fmt.Fprintf(&b, " \x1b[1;%s;48;5;%dm%*d\x1b[0m", fg, shade, cell, n)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Print(b.String())
+}
+
+// Writes the confusion matrix as a PNG heatmap, one square per cell laid out like printConfusionMatrix() (true
+// classes top to bottom, guesses left to right). The darker the blue, the bigger the share of its row the cell
+// holds. There's no text, so keep the class order handy when reading it.
+func writeConfusionPNG(path string, confusion [][]int, cellSize int) error {
+	n := len(confusion)
+	img := image.NewRGBA(image.Rect(0, 0, n*cellSize+1, n*cellSize+1))
+	grid := color.RGBA{200, 200, 200, 255}
+	for t := range confusion {
+		for p := range confusion[t] {
+			f := confusionShare(confusion, t, p)
+			c := color.RGBA{uint8(255 * (1 - f)), uint8(255 - 180*f), 255 - uint8(100*f), 255}
+			for y := t * cellSize; y <= (t+1)*cellSize; y++ {
+				for x := p * cellSize; x <= (p+1)*cellSize; x++ {
+					if y == t*cellSize || y == (t+1)*cellSize || x == p*cellSize || x == (p+1)*cellSize {
+						img.Set(x, y, grid)
+					} else {
+						img.Set(x, y, c)
+					}
+				}
+			}
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return f.Close()
+}
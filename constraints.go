@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Weight constraints, applied by the Trainer after every update. With tied weights only the hidden layer's
+// constraints matter, the output layer just follows.
+func (t *Trainer) constrain() {
+	if t.SpectralNorm > 0 {
+		t.net.hidWeights = t.spectralClip(0, t.net.hidWeights)
+		t.net.outWeights = t.spectralClip(1, t.net.outWeights)
+	}
+	if t.MaxNorm[0] > 0 {
+		maxNormClip(t.net.hidWeights, t.MaxNorm[0])
+	}
+	if t.MaxNorm[1] > 0 {
+		maxNormClip(t.net.outWeights, t.MaxNorm[1])
+	}
+	if t.net.tied {
+		t.net.retie()
+	}
+}
+
+// Keeps a weight matrix's spectral norm (its largest singular value, i.e. the most it can stretch any input)
+// at or below SpectralNorm by scaling the whole matrix down when it gets too big. Bounding how much each layer
+// can amplify its input keeps training stable and makes the network less sensitive to small input changes.
+//
+// The largest singular value is estimated with power iteration. Weights only change a little each step, so the
+// vector from the last step is reused and a single iteration per step is enough to keep the estimate accurate.
+func (t *Trainer) spectralClip(layer int, w *mat.Dense) *mat.Dense {
+	r, c := w.Dims()
+	u := t.spectralU[layer]
+	if u == nil || u.Len() != r {
+		data := make([]float64, r)
+		for i := range data {
+			data[i] = t.rnd.NormFloat64()
+		}
+		u = mat.NewVecDense(r, data)
+	}
+
+	// v = Wᵀu / |Wᵀu|, u = Wv / |Wv|, σ ≈ uᵀWv
+	v := mat.NewVecDense(c, nil)
+	v.MulVec(w.T(), u)
+	if n := mat.Norm(v, 2); n > 0 {
+		v.ScaleVec(1/n, v)
+	}
+	u.MulVec(w, v)
+	sigma := mat.Norm(u, 2)
+	if sigma > 0 {
+		u.ScaleVec(1/sigma, u)
+	}
+	t.spectralU[layer] = u
+
+	if sigma <= t.SpectralNorm || math.IsNaN(sigma) {
+		return w
+	}
+	return scale(t.SpectralNorm/sigma, w).(*mat.Dense)
+}
+
+// Max-norm regularization (Srivastava et al. 2014, used alongside dropout): if the vector of weights coming into
+// a neuron gets longer than limit, it's scaled back down onto the ball of radius limit. This stops individual
+// weights from blowing up even with big learning rates. Each row of a weight matrix holds one neuron's incoming
+// weights.
+func maxNormClip(w *mat.Dense, limit float64) {
+	r, _ := w.Dims()
+	for i := 0; i < r; i++ {
+		row := w.RawRowView(i)
+		n := 0.0
+		for _, v := range row {
+			n += v * v
+		}
+		n = math.Sqrt(n)
+		if n <= limit {
+			continue
+		}
+		for j := range row {
+			row[j] *= limit / n
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Field numbers and enum values from Apple's Core ML spec (Model.proto, FeatureTypes.proto, NeuralNetwork.proto).
+// https://apple.github.io/coremltools/mlmodel/index.html
+const (
+	mlModelSpecVersion  = 1
+	mlModelDescription  = 2
+	mlModelNeuralNet    = 500
+	mlDescInput         = 1
+	mlDescOutput        = 10
+	mlFeatureName       = 1
+	mlFeatureType       = 3
+	mlTypeMultiArray    = 5
+	mlArrayShape        = 1
+	mlArrayDataType     = 2
+	mlArrayDouble       = 65600
+	mlNetLayers         = 1
+	mlLayerName         = 1
+	mlLayerInput        = 2
+	mlLayerOutput       = 3
+	mlLayerActivation   = 130
+	mlLayerInnerProduct = 140
+	mlLayerSoftmax      = 175
+	mlActReLU           = 10
+	mlActTanh           = 30
+	mlActSigmoid        = 40
+	mlDenseInChannels   = 1
+	mlDenseOutChannels  = 2
+	mlDenseHasBias      = 10
+	mlDenseWeights      = 20
+	mlWeightFloats      = 1
+)
+
+// Writes the network as a Core ML .mlmodel file so it can be dropped into an Xcode project and run natively on
+// iOS/macOS. Each of our layers turns into a Core ML inner product layer followed by a layer for its activation.
+// The model takes a "input" array of doubles and returns an "output" array of doubles, but Core ML stores the
+// weights themselves as float32, so predictions can differ from ours in the last few decimal places.
+func (net *MPNN) exportCoreML(path string) error {
+	var desc pbBuffer
+	desc.message(mlDescInput, mlArrayFeature("input", net.in))
+	desc.message(mlDescOutput, mlArrayFeature("output", net.out))
+
+	var nn pbBuffer
+	for _, l := range []struct {
+		name, input, output string
+		w                   *mat.Dense
+		act                 Activation
+	}{
+		{"hidden", "input", "hidden_out", net.hidWeights, net.hidAct},
+		{"output", "hidden_out", "output", net.outWeights, net.outAct},
+	} {
+		// A linear activation doesn't need a layer of its own, the inner product writes straight to the output.
+		if _, ok := l.act.(Linear); ok {
+			nn.message(mlNetLayers, mlDenseLayer(l.name, l.input, l.output, l.w))
+			continue
+		}
+		act, err := mlActivationLayer(l.name+"_"+l.act.name(), l.name+"_in", l.output, l.act)
+		if err != nil {
+			return err
+		}
+		nn.message(mlNetLayers, mlDenseLayer(l.name, l.input, l.name+"_in", l.w))
+		nn.message(mlNetLayers, act)
+	}
+
+	var model pbBuffer
+	model.uint(mlModelSpecVersion, 1)
+	model.message(mlModelDescription, &desc)
+	model.message(mlModelNeuralNet, &nn)
+
+	return os.WriteFile(path, model.b, 0644)
+}
+
+func mlArrayFeature(name string, size int) *pbBuffer {
+	var array pbBuffer
+	array.packedUints(mlArrayShape, []uint64{uint64(size)})
+	array.uint(mlArrayDataType, mlArrayDouble)
+
+	var typ pbBuffer
+	typ.message(mlTypeMultiArray, &array)
+
+	var feature pbBuffer
+	feature.string(mlFeatureName, name)
+	feature.message(mlFeatureType, &typ)
+	return &feature
+}
+
+// Core ML wants inner product weights as [outputChannels][inputChannels], which is already how ours are stored.
+func mlDenseLayer(name, input, output string, w *mat.Dense) *pbBuffer {
+	r, c := w.Dims()
+	values := make([]float64, 0, r*c)
+	for i := 0; i < r; i++ {
+		values = append(values, w.RawRowView(i)...)
+	}
+
+	var weights pbBuffer
+	weights.packedFloats(mlWeightFloats, values)
+
+	var params pbBuffer
+	params.uint(mlDenseInChannels, uint64(c))
+	params.uint(mlDenseOutChannels, uint64(r))
+	params.bool(mlDenseHasBias, false)
+	params.message(mlDenseWeights, &weights)
+
+	layer := mlLayer(name, input, output)
+	layer.message(mlLayerInnerProduct, &params)
+	return layer
+}
+
+// Softmax is a layer type of its own in Core ML, the rest are kinds of activation layer.
+func mlActivationLayer(name, input, output string, a Activation) (*pbBuffer, error) {
+	layer := mlLayer(name, input, output)
+	if _, ok := a.(Softmax); ok {
+		layer.message(mlLayerSoftmax, &pbBuffer{})
+		return layer, nil
+	}
+
+	var act pbBuffer
+	switch a.(type) {
+	case Sigmoid:
+		act.message(mlActSigmoid, &pbBuffer{})
+	case ReLU:
+		act.message(mlActReLU, &pbBuffer{})
+	case Tanh:
+		act.message(mlActTanh, &pbBuffer{})
+	default:
+		return nil, fmt.Errorf("core ml: no equivalent for the %s activation", a.name())
+	}
+	layer.message(mlLayerActivation, &act)
+	return layer, nil
+}
+
+func mlLayer(name, input, output string) *pbBuffer {
+	var layer pbBuffer
+	layer.string(mlLayerName, name)
+	layer.string(mlLayerInput, input)
+	layer.string(mlLayerOutput, output)
+	return &layer
+}
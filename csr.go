@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// A matrix in compressed sparse row (CSR) form: only the non-zero values are kept, row by row, with the column
+// each one came from. Row i's values are Val[RowPtr[i]:RowPtr[i+1]]. Multiplying by a vector then only costs as
+// much as there are non-zeros, so a heavily pruned weight matrix is both smaller and faster than the dense one.
+type CSR struct {
+	Rows, Cols int
+	RowPtr     []int
+	ColIdx     []int
+	Val        []float64
+}
+
+func csrFromDense(m mat.Matrix) *CSR {
+	r, c := m.Dims()
+	s := &CSR{Rows: r, Cols: c, RowPtr: make([]int, 1, r+1)}
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if v := m.At(i, j); v != 0 {
+				s.ColIdx = append(s.ColIdx, j)
+				s.Val = append(s.Val, v)
+			}
+		}
+		s.RowPtr = append(s.RowPtr, len(s.Val))
+	}
+	return s
+}
+
+// m ⋅ x
+func (s *CSR) mulVec(x []float64) []float64 {
+	out := make([]float64, s.Rows)
+	for i := range out {
+		for k := s.RowPtr[i]; k < s.RowPtr[i+1]; k++ {
+			out[i] += s.Val[k] * x[s.ColIdx[k]]
+		}
+	}
+	return out
+}
+
+// Roughly how much memory the matrix takes, against 8·Rows·Cols bytes for a dense one.
+func (s *CSR) bytes() int {
+	return 8*len(s.Val) + 8*len(s.ColIdx) + 8*len(s.RowPtr)
+}
+
+// A read-only copy of a (pruned) network with its weights in CSR form, for serving predictions. Training still
+// happens on the MPNN, which can be pruned again and converted as often as needed.
+type SparseMPNN struct {
+	in, out        int
+	hidWeights     *CSR
+	outWeights     *CSR
+	hidAct, outAct Activation
+}
+
+func (net *MPNN) toSparse() *SparseMPNN {
+	return &SparseMPNN{
+		in:         net.in,
+		out:        net.out,
+		hidWeights: csrFromDense(net.hidWeights),
+		outWeights: csrFromDense(net.outWeights),
+		hidAct:     net.hidAct,
+		outAct:     net.outAct,
+	}
+}
+
+func (s *SparseMPNN) Predict(input []float64) ([]float64, error) {
+	if len(input) != s.in {
+		return nil, fmt.Errorf("input has %d values, network expects %d", len(input), s.in)
+	}
+	hidden := s.hidAct.activate(column(s.hidWeights.mulVec(input)))
+	out := s.outAct.activate(column(s.outWeights.mulVec(mat.Col(nil, 0, hidden))))
+	return mat.Col(nil, 0, out), nil
+}
+
+func column(v []float64) *mat.Dense {
+	return mat.NewDense(len(v), 1, v)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Writes every weight in the network out as labeled CSV files so they can be audited by hand or pulled into a
+// spreadsheet. Each connection is named after the two neurons it joins, e.g. "i2.h4" is the weight from input
+// neuron 2 to hidden neuron 4, and "h4.o1" is the weight from hidden neuron 4 to output neuron 1.
+// One file is written per weight matrix. There are no bias files since the network doesn't use biases.
+func (net *MPNN) exportCSV(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeWeightsCSV(filepath.Join(dir, "hidden_weights.csv"), net.hidWeights, "i", "h"); err != nil {
+		return err
+	}
+	return writeWeightsCSV(filepath.Join(dir, "output_weights.csv"), net.outWeights, "h", "o")
+}
+
+// Weight matrices store inputs as columns and outputs as rows, so w[row][col] is the weight from neuron
+// <from><col> to neuron <to><row>.
+func writeWeightsCSV(path string, w mat.Matrix, from, to string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out := csv.NewWriter(f)
+	if err := out.Write([]string{"connection", "from", "to", "weight"}); err != nil {
+		return err
+	}
+
+	r, c := w.Dims()
+	for j := 0; j < c; j++ {
+		for i := 0; i < r; i++ {
+			src := fmt.Sprintf("%s%d", from, j)
+			dst := fmt.Sprintf("%s%d", to, i)
+			// 'g' with -1 precision writes the shortest string that parses back to the exact same float.
+			val := strconv.FormatFloat(w.At(i, j), 'g', -1, 64)
+			if err := out.Write([]string{src + "." + dst, src, dst, val}); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
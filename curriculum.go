@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// Curriculum learning: rather than throwing the whole dataset at the network from the start, the first epochs
+// only use the easiest samples, and harder ones are let in bit by bit until every sample is used. Noisy or
+// mislabeled samples tend to look "hard", so they mostly show up once the network already knows the basics.
+type Curriculum struct {
+	// Scores how hard a sample is, lower is easier. If nil, the untrained network's loss on the sample is used.
+	Difficulty func(s Sample) float64
+
+	Start  float64 // Fraction of the (easiest) samples used in the first epoch, e.g. 0.2
+	Epochs int     // How many epochs it takes to grow to the full dataset
+}
+
+// Sorts the samples from easiest to hardest.
+func (c *Curriculum) order(net *MPNN, data []Sample) []Sample {
+	score := c.Difficulty
+	if score == nil {
+		score = net.sampleLoss
+	}
+	scores := make([]float64, len(data))
+	for i, s := range data {
+		scores[i] = score(s)
+	}
+
+	idx := make([]int, len(data))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] < scores[idx[b]] })
+
+	sorted := make([]Sample, len(data))
+	for i, j := range idx {
+		sorted[i] = data[j]
+	}
+	return sorted
+}
+
+// The samples the network gets to see this epoch, from samples already sorted by order(). The fraction grows
+// linearly from Start to 1 over Epochs.
+func (c *Curriculum) pool(sorted []Sample, epoch int) []Sample {
+	frac := 1.0
+	if c.Epochs > 0 && epoch < c.Epochs {
+		frac = c.Start + (1-c.Start)*float64(epoch)/float64(c.Epochs)
+	}
+	n := int(math.Ceil(frac * float64(len(sorted))))
+	if n < 1 {
+		n = 1
+	}
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	return sorted[:n]
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// A full-screen view of training for the terminal, redrawn after every epoch: loss and accuracy charts, the
+// learning rate, throughput, an ETA, and the last few log lines. It only uses ANSI escape codes, so it works over
+// SSH without anything installed on either end. Add it to Trainer.Callbacks and call Close() once Fit() returns to
+// get the normal screen back:
+//
+//	dash := initDashboard(os.Stdout)
+//	defer dash.Close()
+//	t.Callbacks = append(t.Callbacks, dash)
+//
+// Loss and accuracy are measured on Trainer.Validation, so the charts stay empty without it. Leave
+// Trainer.Verbose off, Logf() is the way to get lines onto the screen.
+type Dashboard struct {
+	Width  int // Columns for the charts
+	Height int // Rows for each chart
+	Lines  int // Log lines to keep
+
+	w                       io.Writer
+	started                 bool
+	trainLoss, valLoss, acc []float64
+	log                     []string
+}
+
+func initDashboard(w io.Writer) *Dashboard {
+	return &Dashboard{Width: 60, Height: 8, Lines: 6, w: w}
+}
+
+// Adds a line to the log panel, shown from the next redraw.
+func (d *Dashboard) Logf(format string, args ...any) {
+	d.log = append(d.log, fmt.Sprintf(format, args...))
+	if len(d.log) > d.Lines {
+		d.log = d.log[len(d.log)-d.Lines:]
+	}
+}
+
+func (d *Dashboard) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	if !d.started {
+		// Switch to the terminal's alternate screen (like less or vim do) and hide the cursor.
+		fmt.Fprint(d.w, "\x1b[?1049h\x1b[?25l")
+		d.started = true
+	}
+	if t.Validation != nil {
+		d.trainLoss = append(d.trainLoss, stats.TrainLoss)
+		d.valLoss = append(d.valLoss, stats.ValLoss)
+		d.acc = append(d.acc, t.net.accuracy(t.Validation))
+	}
+	d.Logf("%s", stats)
+	d.draw(t, stats, time.Since(t.fitStart))
+	return false
+}
+
+// Puts the terminal back the way it was.
+func (d *Dashboard) Close() {
+	if d.started {
+		fmt.Fprint(d.w, "\x1b[?25h\x1b[?1049l")
+		d.started = false
+	}
+}
+
+func (d *Dashboard) draw(t *Trainer, stats EpochStats, elapsed time.Duration) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J") // Home and clear
+
+	done := stats.Epoch + 1
+	if t.Budget > 0 {
+		// Training stops when the time is up rather than after t.epochs, so the total (and an ETA from it)
+		// would be meaningless. Show how much of the budget is used instead.
+		used, budget := int(elapsed/time.Millisecond), int(t.Budget/time.Millisecond)
+		fmt.Fprintf(&b, "\x1b[1mepoch %d\x1b[0m  %s\n", done, progressBar(used, budget, d.Width-20))
+	} else {
+		fmt.Fprintf(&b, "\x1b[1mepoch %d/%d\x1b[0m  %s\n", done, t.epochs, progressBar(done, t.epochs, d.Width-20))
+	}
+	fmt.Fprintf(&b, "rate %.3g  ", t.rate())
+	if stats.Duration > 0 {
+		fmt.Fprintf(&b, "%.0f samples/s  ", stats.SamplesPerSec())
+	}
+	fmt.Fprintf(&b, "elapsed %s", elapsed.Round(time.Second))
+	if t.Budget > 0 {
+		fmt.Fprintf(&b, " of %s", t.Budget.Round(time.Second))
+	} else if left := t.epochs - done; left > 0 && done > 0 {
+		fmt.Fprintf(&b, "  ETA %s", (elapsed / time.Duration(done) * time.Duration(left)).Round(time.Second))
+	}
+	b.WriteString("\n\n")
+
+	if len(d.valLoss) > 0 {
+		fmt.Fprintf(&b, "loss  \x1b[36mtrain %.5f\x1b[0m  \x1b[33mval %.5f\x1b[0m\n", last(d.trainLoss), last(d.valLoss))
+		b.WriteString(chart(d.Width, d.Height, series{d.trainLoss, "\x1b[36m"}, series{d.valLoss, "\x1b[33m"}))
+		fmt.Fprintf(&b, "\naccuracy  \x1b[32m%.2f%%\x1b[0m\n", 100*last(d.acc))
+		b.WriteString(chart(d.Width, d.Height, series{d.acc, "\x1b[32m"}))
+	} else {
+		b.WriteString("(set Trainer.Validation for loss and accuracy charts)\n")
+	}
+
+	b.WriteString("\n")
+	for _, line := range d.log {
+		b.WriteString("\x1b[2m" + line + "\x1b[0m\n")
+	}
+	io.WriteString(d.w, b.String())
+}
+
+func progressBar(done, total, width int) string {
+	if total < 1 || width < 1 {
+		return ""
+	}
+	filled := done * width / total
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+func last(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
+
+type series struct {
+	values []float64
+	color  string
+}
+
+// Plots each series as a line of dots, the later ones drawn over the earlier ones. A series longer than the
+// chart is wide gets squeezed, each column showing the point that falls there. The axis is labelled with the
+// range of all the values.
+func chart(width, height int, all ...series) string {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, s := range all {
+		for _, v := range s.values {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+		}
+	}
+	if math.IsInf(min, 1) {
+		min, max = 0, 1
+	}
+	if max == min {
+		max = min + 1
+	}
+
+	cells := make([][]string, height)
+	for r := range cells {
+		cells[r] = make([]string, width)
+		for c := range cells[r] {
+			cells[r][c] = " "
+		}
+	}
+	for _, s := range all {
+		n := len(s.values)
+		for c := 0; c < width && c < n; c++ {
+			i := c
+			if n > width && width > 1 {
+				i = c * (n - 1) / (width - 1)
+			}
+			if math.IsNaN(s.values[i]) || math.IsInf(s.values[i], 0) {
+				continue
+			}
+			r := int(math.Round((max - s.values[i]) / (max - min) * float64(height-1)))
+			cells[r][c] = s.color + "•\x1b[0m"
+		}
+	}
+
+	var b strings.Builder
+	for r, row := range cells {
+		label := "          "
+		switch r {
+		case 0:
+			label = fmt.Sprintf("%9.4g ", max)
+		case height - 1:
+			label = fmt.Sprintf("%9.4g ", min)
+		}
+		b.WriteString(label + "│" + strings.Join(row, "") + "\n")
+	}
+	return b.String()
+}
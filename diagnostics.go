@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// A neuron whose slope is below this is saturated: it's pinned where its activation is flat and barely passes any
+// gradient back. Sigmoid's slope is at most 0.25, and drops below 0.01 once its output is under ~0.01 or over
+// ~0.99. A ReLU's slope is 0 whenever its input is negative.
+const saturatedSlope = 0.01
+
+type NeuronStats struct {
+	Mean       float64 // Average activation over the dataset
+	Saturation float64 // Fraction of samples where the neuron was saturated
+	Dead       bool    // Saturated on every sample, so it never learns anything
+}
+
+type LayerReport struct {
+	Name    string
+	Neurons []NeuronStats
+	Dead    int
+}
+
+// Runs the dataset through the network and reports how every neuron behaves, to help figure out why a network
+// isn't learning. Lots of saturated or dead neurons usually means the weights are too big (lower the learning
+// rate, or use a different initialization), and a layer whose neurons all have the same mean isn't telling the
+// samples apart at all.
+func (net *MPNN) activationReport(data []Sample) []LayerReport {
+	hidden := newLayerStats(net.hidden)
+	output := newLayerStats(net.out)
+	for _, s := range data {
+		c := net.forward(s.Input)
+		hidden.add(net.hidAct, c.inLayerWeightsIn, c.inLayerWeightsOut)
+		output.add(net.outAct, c.hidLayerWeightsIn, c.hidLayerWeightsOut)
+	}
+	return []LayerReport{hidden.report("hidden", len(data)), output.report("output", len(data))}
+}
+
+type layerStats struct {
+	sum       []float64
+	saturated []int
+}
+
+func newLayerStats(size int) *layerStats {
+	return &layerStats{sum: make([]float64, size), saturated: make([]int, size)}
+}
+
+func (l *layerStats) add(act Activation, z, a mat.Matrix) {
+	slope := activationSlope(act, z, a)
+	for i := range l.sum {
+		l.sum[i] += a.At(i, 0)
+		if slope[i] < saturatedSlope {
+			l.saturated[i]++
+		}
+	}
+}
+
+// How much each neuron's output moves when its own weighted sum does, ∂a_i/∂z_i. Worked out by backpropagating
+// a unit gradient for one neuron at a time, so it also works for softmax, where neurons affect each other, and
+// maxout, where each neuron has several weighted sums (their slopes are added up).
+func activationSlope(act Activation, z, a mat.Matrix) []float64 {
+	n, _ := a.Dims()
+	k := pieces(act)
+	slope := make([]float64, n)
+	unit := mat.NewDense(n, 1, nil)
+	for i := range slope {
+		unit.Set(i, 0, 1)
+		back := act.backprop(z, a, unit)
+		for p := i * k; p < (i+1)*k; p++ {
+			slope[i] += math.Abs(back.At(p, 0))
+		}
+		unit.Set(i, 0, 0)
+	}
+	return slope
+}
+
+func (l *layerStats) report(name string, samples int) LayerReport {
+	r := LayerReport{Name: name, Neurons: make([]NeuronStats, len(l.sum))}
+	if samples == 0 {
+		return r
+	}
+	for i := range l.sum {
+		n := NeuronStats{
+			Mean:       l.sum[i] / float64(samples),
+			Saturation: float64(l.saturated[i]) / float64(samples),
+			Dead:       l.saturated[i] == samples,
+		}
+		if n.Dead {
+			r.Dead++
+		}
+		r.Neurons[i] = n
+	}
+	return r
+}
+
+func printActivationReport(layers []LayerReport) {
+	for _, l := range layers {
+		fmt.Printf("[%s layer] %d/%d dead\n", l.Name, l.Dead, len(l.Neurons))
+		for i, n := range l.Neurons {
+			dead := ""
+			if n.Dead {
+				dead = "  DEAD"
+			}
+			fmt.Printf("  %s%-4d mean %.4f  saturated %5.1f%%%s\n", l.Name[:1], i, n.Mean, 100*n.Saturation, dead)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+
+	"Users/392wa/MPNN/mpnnpb"
+)
+
+// The gRPC code in mpnnpb is generated from coordinator.proto, so rerun this after changing it (needs protoc,
+// protoc-gen-go and protoc-gen-go-grpc on the PATH).
+//go:generate protoc --go_out=. --go_opt=module=Users/392wa/MPNN --go-grpc_out=. --go-grpc_opt=module=Users/392wa/MPNN coordinator.proto
+
+// Synchronous data-parallel training across machines. One process runs a Coordinator holding the network, and
+// every machine runs runWorker() on its own shard of the data. For every step each worker works out the average
+// gradient of one batch of its shard, the coordinator waits for all of them, averages the gradients (weighted by
+// batch size, so it's the gradient of all the batches together), takes one step with its Trainer, and sends the
+// new weights back. Every worker so sees the same weights at every step, and training behaves like a single
+// Trainer with a batch as big as all the workers' batches put together.
+//
+// The workers talk to the coordinator with gRPC over TLS (see ClusterConfig), so they can be anywhere, and
+// coordinator.proto lets them be written in other languages too.
+//
+// Shards don't have to be the same size. A worker that runs out of batches leaves, and the rest carry on without
+// it. So do they when a worker's connection drops, or it doesn't push in time (see ClusterConfig): the
+// coordinator drops it, and turns away anything it sends afterwards.
+type Coordinator struct {
+	t        *Trainer
+	listener net.Listener
+	server   *grpc.Server
+	timeout  time.Duration
+
+	mu       sync.Mutex
+	expected uint64                        // Workers that have to join before the first step
+	nextID   uint64                        // Also how many have joined
+	workers  map[uint64]*coordinatorWorker // Those still training, every one has to push before a step is taken
+	step     *coordinatorStep
+	samples  int
+	hidGrad  *mat.Dense
+	outGrad  *mat.Dense
+	actGrad  activationGrads
+	done     bool
+	finished chan struct{}
+}
+
+type coordinatorWorker struct {
+	conn   any // Tells which connection it joined on, see connTracker
+	pushed bool
+}
+
+// The step workers are pushing their gradients for.
+type coordinatorStep struct {
+	taken   chan struct{}        // Closed once the step has been taken
+	weights *mpnnpb.WeightUpdate // The weights after it
+}
+
+// How the coordinator and its workers secure their connections. The coordinator's TLS config needs its
+// certificate, the workers' has to trust it (RootCAs, or nil for a certificate from a public CA). Every call
+// carries Token, and the coordinator turns away any that don't have the same one, so only workers that know it
+// can read or change the network. An empty Token turns that check off.
+//
+// PushTimeout is only for the coordinator: a worker that hasn't pushed its gradient that long after the first one
+// of a step came in is dropped, so one that has hung doesn't hold everyone up. 0 waits however long it takes, but
+// workers whose connection drops are still dropped straight away.
+type ClusterConfig struct {
+	TLS         *tls.Config
+	Token       string
+	PushTimeout time.Duration
+}
+
+// The network's parameters after a step.
+type WeightUpdate struct {
+	Hid, Out  []float64
+	ActParams [2][]float64
+}
+
+// Starts coordinating on addr (e.g. ":7070"), training t's network. The first step waits for workers workers to
+// join, others can join later. The Trainer's learning rate, Decay and weight constraints apply to every step.
+// What the workers' Trainers do (dropout, augmentation, their own Workers goroutines) is up to them, see
+// runWorker().
+func startCoordinator(t *Trainer, addr string, workers int, cluster ClusterConfig) (*Coordinator, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("need at least one worker, got %d", workers)
+	}
+	if cluster.TLS == nil || (len(cluster.TLS.Certificates) == 0 && cluster.TLS.GetCertificate == nil) {
+		return nil, errors.New("the coordinator needs a TLS certificate")
+	}
+	c := &Coordinator{t: t, timeout: cluster.PushTimeout, expected: uint64(workers),
+		workers: make(map[uint64]*coordinatorWorker), finished: make(chan struct{})}
+	c.reset()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c.listener = ln
+	opts := []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(cluster.TLS.Clone())),
+		grpc.StatsHandler(connTracker{c}),
+		// Pings idle connections, so one whose worker has vanished without closing it is noticed too.
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: time.Minute, Timeout: 20 * time.Second}),
+	}
+	if cluster.Token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(checkToken(cluster.Token)))
+	}
+	c.server = grpc.NewServer(opts...)
+	mpnnpb.RegisterCoordinatorServer(c.server, &coordinatorRPC{c: c})
+	go c.server.Serve(ln) // Only returns once Wait() stops it
+	return c, nil
+}
+
+// Where the coordinator is listening.
+func (c *Coordinator) Addr() string { return c.listener.Addr().String() }
+
+// Blocks until every worker has left, then stops listening. The Trainer's network is the trained one.
+func (c *Coordinator) Wait() {
+	<-c.finished
+	// Lets the last worker's Leave call finish before the connections close.
+	c.server.GracefulStop()
+}
+
+func (c *Coordinator) reset() {
+	c.step = &coordinatorStep{taken: make(chan struct{})}
+	c.samples = 0
+	c.hidGrad = zeros(c.t.net.hidWeights)
+	c.outGrad = zeros(c.t.net.outWeights)
+	c.actGrad = activationGrads{}
+}
+
+// Takes the step once every worker has pushed. Called with mu held.
+func (c *Coordinator) maybeStep() {
+	if c.samples == 0 || c.nextID < c.expected {
+		return
+	}
+	for _, w := range c.workers {
+		if !w.pushed {
+			return
+		}
+	}
+	f := 1 / float64(c.samples)
+	c.hidGrad.Scale(f, c.hidGrad)
+	c.outGrad.Scale(f, c.outGrad)
+	c.actGrad.scale(f)
+	c.t.applyGradients(nil, c.hidGrad, c.outGrad, c.actGrad, c.samples)
+	for _, w := range c.workers {
+		w.pushed = false
+	}
+	c.step.weights = c.t.net.weightUpdate().proto()
+	close(c.step.taken)
+	c.reset()
+}
+
+// Stops waiting for a worker. Its gradient for this step, if it pushed one, still counts. Called with mu held,
+// followed by maybeStep() and maybeFinish() once all the dropping is done.
+func (c *Coordinator) drop(id uint64, why string) {
+	if _, ok := c.workers[id]; ok {
+		c.t.log().Warn("dropping worker", "worker", id, "reason", why)
+		delete(c.workers, id)
+	}
+}
+
+// Once every worker has left (or been dropped), Wait() returns. Called with mu held, after maybeStep() so whatever
+// the last workers pushed before they went still gets its step.
+func (c *Coordinator) maybeFinish() {
+	if c.done || len(c.workers) > 0 || c.nextID < c.expected {
+		return
+	}
+	c.done = true
+	close(c.finished)
+}
+
+// A copy of the network's parameters.
+func (net *MPNN) weightUpdate() WeightUpdate {
+	var w WeightUpdate
+	w.Hid = rowMajor(net.hidWeights)
+	w.Out = rowMajor(net.outWeights)
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := act.(paramActivation); ok {
+			w.ActParams[l] = append([]float64(nil), p.params()...)
+		}
+	}
+	return w
+}
+
+// The methods the gRPC server serves, see coordinator.proto.
+type coordinatorRPC struct {
+	mpnnpb.UnimplementedCoordinatorServer
+	c *Coordinator
+}
+
+// Hands a new worker the network, as a .mpnn model file, and its ID.
+func (r *coordinatorRPC) Join(ctx context.Context, _ *mpnnpb.Empty) (*mpnnpb.JoinReply, error) {
+	c := r.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done {
+		return nil, status.Error(codes.FailedPrecondition, "training is over")
+	}
+	var b bytes.Buffer
+	if err := c.t.net.writeTo(&b); err != nil {
+		return nil, err
+	}
+	c.nextID++
+	c.workers[c.nextID] = &coordinatorWorker{conn: ctx.Value(connKey{})}
+	return &mpnnpb.JoinReply{Model: b.Bytes(), WorkerId: c.nextID}, nil
+}
+
+// Adds a worker's gradient to this step's, and replies with the weights once the step has been taken.
+func (r *coordinatorRPC) Push(ctx context.Context, p *mpnnpb.GradientPush) (*mpnnpb.WeightUpdate, error) {
+	c := r.c
+	c.mu.Lock()
+	w, ok := c.workers[p.WorkerId]
+	if !ok {
+		c.mu.Unlock()
+		return nil, status.Errorf(codes.NotFound, "no worker %d, it has left or was dropped", p.WorkerId)
+	}
+	if w.pushed {
+		c.mu.Unlock()
+		return nil, status.Errorf(codes.FailedPrecondition, "worker %d already pushed for this step", p.WorkerId)
+	}
+	if p.Samples < 1 {
+		c.mu.Unlock()
+		return nil, status.Error(codes.InvalidArgument, "gradient of an empty batch")
+	}
+	hr, hc := c.hidGrad.Dims()
+	or, oc := c.outGrad.Dims()
+	actGrad := activationGrads{p.HidActGrad, p.OutActGrad}
+	if len(p.HidGrad) != hr*hc || len(p.OutGrad) != or*oc || !c.t.net.fitsActivations(actGrad) {
+		c.mu.Unlock()
+		return nil, status.Error(codes.InvalidArgument, "gradient doesn't match the network's shape")
+	}
+
+	// The first gradient of a step starts the clock for the others.
+	step := c.step
+	if c.samples == 0 && c.timeout > 0 {
+		time.AfterFunc(c.timeout, func() { c.timedOut(step) })
+	}
+	// Gradients arrive as batch averages, so they're weighted by batch size to get the overall average.
+	n := float64(p.Samples)
+	c.hidGrad.Add(c.hidGrad, scale(n, mat.NewDense(hr, hc, p.HidGrad)))
+	c.outGrad.Add(c.outGrad, scale(n, mat.NewDense(or, oc, p.OutGrad)))
+	actGrad.scale(n)
+	c.actGrad.add(actGrad)
+	c.samples += int(p.Samples)
+	w.pushed = true
+	c.maybeStep()
+	c.mu.Unlock()
+
+	select {
+	case <-step.taken:
+		return step.weights, nil
+	case <-ctx.Done():
+		// The worker hung up (or its connection broke) before the step was taken.
+		c.mu.Lock()
+		c.drop(p.WorkerId, "gave up waiting for the step")
+		c.maybeStep()
+		c.maybeFinish()
+		c.mu.Unlock()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// Drops the workers that haven't pushed for step, if it still hasn't been taken.
+func (c *Coordinator) timedOut(step *coordinatorStep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.step != step {
+		return
+	}
+	// Nor is there any more waiting for workers that never joined.
+	c.expected = 0
+	for id, w := range c.workers {
+		if !w.pushed {
+			c.drop(id, "no gradient within the push timeout")
+		}
+	}
+	c.maybeStep()
+	c.maybeFinish()
+}
+
+// A worker that's out of data stops counting towards steps.
+func (r *coordinatorRPC) Leave(_ context.Context, req *mpnnpb.LeaveRequest) (*mpnnpb.Empty, error) {
+	c := r.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.workers[req.WorkerId]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no worker %d, it has left or was dropped", req.WorkerId)
+	}
+	delete(c.workers, req.WorkerId)
+	c.maybeStep()
+	c.maybeFinish()
+	return &mpnnpb.Empty{}, nil
+}
+
+// Notices workers' connections closing. Every connection gets its own key in its context, which the calls made
+// over it inherit, so Join can note which one a worker is on.
+type connTracker struct{ c *Coordinator }
+
+type connKey struct{}
+
+func (connTracker) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connKey{}, new(byte))
+}
+
+func (h connTracker) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if _, ok := s.(*stats.ConnEnd); !ok {
+		return
+	}
+	c, conn := h.c, ctx.Value(connKey{})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, w := range c.workers {
+		if w.conn == conn {
+			c.drop(id, "lost its connection")
+		}
+	}
+	c.maybeStep()
+	c.maybeFinish()
+}
+
+func (connTracker) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+func (connTracker) HandleRPC(context.Context, stats.RPCStats)                       {}
+
+// Trains on shard for epochs epochs as one of the coordinator's workers at addr, returning once done. configure,
+// if not nil, sets up the worker's Trainer (BatchSize, Workers, MixupAlpha...) before training starts, the
+// network itself comes from the coordinator.
+func runWorker(addr string, cluster ClusterConfig, shard []Sample, epochs int, configure func(t *Trainer)) (err error) {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cluster.TLS))}
+	if cluster.Token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(cluster.Token)))
+	}
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := mpnnpb.NewCoordinatorClient(conn)
+	ctx := context.Background()
+
+	reply, err := client.Join(ctx, &mpnnpb.Empty{})
+	if err != nil {
+		return err
+	}
+	// The coordinator counts this worker from here on, so leave even after a failure rather than keep the other
+	// workers waiting until it's dropped.
+	id := reply.WorkerId
+	defer func() {
+		if _, leaveErr := client.Leave(ctx, &mpnnpb.LeaveRequest{WorkerId: id}); err == nil {
+			err = leaveErr
+		}
+	}()
+
+	net, err := readMPNN(bytes.NewReader(reply.Model))
+	if err != nil {
+		return err
+	}
+	if err := net.checkSamples(shard); err != nil {
+		return err
+	}
+	t := initTrainer(&net)
+	if configure != nil {
+		configure(t)
+	}
+
+	for e := 0; e < epochs; e++ {
+		for _, batch := range t.epochBatches(shard, e) {
+			hidGrad, outGrad, actGrad := t.gradients(t.net, batch)
+			push := &mpnnpb.GradientPush{
+				WorkerId:   id,
+				Samples:    int64(len(batch)),
+				HidGrad:    rowMajor(hidGrad),
+				OutGrad:    rowMajor(outGrad),
+				HidActGrad: actGrad[0],
+				OutActGrad: actGrad[1],
+			}
+			update, err := client.Push(ctx, push)
+			if err != nil {
+				return err
+			}
+			w := weightUpdateFromProto(update)
+			if !net.fitsWeights(w) {
+				return errors.New("coordinator sent weights that don't match the network's shape")
+			}
+			net.setWeights(w)
+		}
+	}
+	return nil
+}
+
+// A copy of m's values, row after row.
+func rowMajor(m *mat.Dense) []float64 {
+	return mat.DenseCopyOf(m).RawMatrix().Data
+}
+
+func (net *MPNN) setWeights(w WeightUpdate) {
+	net.hidWeights = mat.NewDense(net.hidWeights.RawMatrix().Rows, net.in, w.Hid)
+	net.outWeights = mat.NewDense(net.outWeights.RawMatrix().Rows, net.hidden, w.Out)
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := act.(paramActivation); ok {
+			copy(p.params(), w.ActParams[l])
+		}
+	}
+}
+
+// Whether w has the right number of values for the network, so setWeights() can take it.
+func (net *MPNN) fitsWeights(w WeightUpdate) bool {
+	hr, hc := net.hidWeights.Dims()
+	or, oc := net.outWeights.Dims()
+	return len(w.Hid) == hr*hc && len(w.Out) == or*oc && net.fitsActivations(w.ActParams)
+}
+
+// Whether each layer's values (nil for none) are as many as its activation has parameters.
+func (net *MPNN) fitsActivations(values [2][]float64) bool {
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if values[l] == nil {
+			continue
+		}
+		if p, ok := act.(paramActivation); !ok || len(p.params()) != len(values[l]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w WeightUpdate) proto() *mpnnpb.WeightUpdate {
+	return &mpnnpb.WeightUpdate{Hid: w.Hid, Out: w.Out, HidActParams: w.ActParams[0], OutActParams: w.ActParams[1]}
+}
+
+func weightUpdateFromProto(u *mpnnpb.WeightUpdate) WeightUpdate {
+	return WeightUpdate{Hid: u.Hid, Out: u.Out, ActParams: [2][]float64{u.HidActParams, u.OutActParams}}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Flips the sign of gradients going backwards through it, scaled by Lambda, and passes values going forwards
+// through untouched. Whatever sits after it gets trained to get better at its job, and whatever sits before it to
+// make that job harder.
+type GradientReversal struct {
+	Lambda float64
+}
+
+func (g GradientReversal) backprop(grad mat.Matrix) mat.Matrix {
+	return scale(-g.Lambda, grad)
+}
+
+// Domain-adversarial training (Ganin et al. 2016), for when the network will be used on data that looks different
+// from what it's trained on (another sensor, another hospital, another year) and there are no labels for the new
+// data, only inputs.
+//
+// A domain classifier reads the hidden layer and learns to tell training samples (the source domain) from Target
+// ones, while a GradientReversal between them trains the hidden layer to make that impossible. The network ends up
+// with hidden features that look the same for both domains, so what the output layer learns on the source data
+// carries over to the target data.
+//
+// Reversal.Lambda is how hard the hidden layer is pushed. Ganin et al. start it at 0, while the features are still
+// meaningless, and raise it towards 1 as training goes; a Callback can do that by changing it between epochs.
+type DomainAdaptation struct {
+	Reversal GradientReversal
+
+	// Inputs from the domain the network will be used on. Targets aren't needed, and are ignored.
+	Target []Sample
+
+	weights *mat.Dense // The domain classifier, logistic regression on the hidden layer
+}
+
+func initDomainAdaptation(target []Sample, lambda float64) *DomainAdaptation {
+	return &DomainAdaptation{Reversal: GradientReversal{Lambda: lambda}, Target: target}
+}
+
+func (d *DomainAdaptation) check(net *MPNN) error {
+	if len(d.Target) == 0 {
+		return fmt.Errorf("domain adaptation needs target domain inputs")
+	}
+	for i, s := range d.Target {
+		if s.Sparse != nil {
+			if err := s.Sparse.check(net.in); err != nil {
+				return fmt.Errorf("target sample %d: %w", i, err)
+			}
+		} else if len(s.Input) != net.in {
+			return fmt.Errorf("target sample %d has %d inputs, network expects %d", i, len(s.Input), net.in)
+		}
+	}
+	return nil
+}
+
+// The hidden weights' gradient coming back through the reversal, for a batch of source samples and as many
+// random target ones. The classifier learns from the same batch, taking its step at rate. Its loss is the cross
+// entropy of guessing the domain, averaged over both halves.
+func (d *DomainAdaptation) gradients(t *Trainer, batch []Sample, rate float64) *mat.Dense {
+	net := t.net
+	if d.weights == nil || d.weights.RawMatrix().Cols != net.hidden {
+		d.weights = mat.NewDense(1, net.hidden, uniformInit(1, net.hidden))
+	}
+	hidGrad, clsGrad := zeros(net.hidWeights), zeros(d.weights)
+	for _, s := range batch {
+		d.accumulate(net, s, 0, hidGrad, clsGrad)
+		d.accumulate(net, d.Target[t.rnd.Intn(len(d.Target))], 1, hidGrad, clsGrad)
+	}
+	n := float64(2 * len(batch))
+	hidGrad.Scale(1/n, hidGrad)
+	clsGrad.Scale(1/n, clsGrad)
+	d.weights.Sub(d.weights, scale(rate, clsGrad))
+	return hidGrad
+}
+
+// Adds one sample's gradients, domain being 0 for source and 1 for target.
+func (d *DomainAdaptation) accumulate(net *MPNN, s Sample, domain float64, hidGrad, clsGrad *mat.Dense) {
+	c := net.forwardSample(s, nil)
+	p := d.classify(mat.Col(nil, 0, c.inLayerWeightsOut))
+	// With a sigmoid and cross entropy, the gradient on the weighted sum is just the prediction's error.
+	delta := mat.NewDense(1, 1, []float64{p - domain})
+	clsGrad.Add(clsGrad, dot(delta, c.inLayerWeightsOut.T()))
+
+	featureGrad := d.Reversal.backprop(dot(d.weights.T(), delta))
+	hiddenDelta := net.hidAct.backprop(c.inLayerWeightsIn, c.inLayerWeightsOut, featureGrad)
+	if c.sparseIn != nil {
+		hidGrad.Add(hidGrad, c.sparseIn.outer(hiddenDelta))
+	} else {
+		hidGrad.Add(hidGrad, dot(hiddenDelta, c.inLayer.T()))
+	}
+}
+
+// How likely the domain classifier thinks an input is to come from the target domain. Close to 0.5 for inputs
+// of both domains is what training is after.
+func (d *DomainAdaptation) Predict(net *MPNN, input []float64) (float64, error) {
+	if d.weights == nil {
+		return 0, fmt.Errorf("domain classifier hasn't been trained")
+	}
+	if len(input) != net.in {
+		return 0, fmt.Errorf("input has %d values, network expects %d", len(input), net.in)
+	}
+	return d.classify(mat.Col(nil, 0, net.forward(input).inLayerWeightsOut)), nil
+}
+
+func (d *DomainAdaptation) classify(hidden []float64) float64 {
+	return sigmoid(0, 0, floats.Dot(d.weights.RawRowView(0), hidden))
+}
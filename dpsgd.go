@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Differentially private SGD (Abadi et al. 2016). Every sample's gradient is clipped to an L2 norm of at most
+// Clip, so no single sample can move the weights by much, and Gaussian noise with a standard deviation of
+// NoiseMultiplier·Clip is added to the batch's summed gradient to hide what's left of each one's influence.
+// Together that bounds how much the trained weights can give away about any one training sample, which is what
+// (ε, δ)-differential privacy measures: Epsilon() is the ε spent so far, at a δ of Delta.
+//
+// The accountant is the Rényi DP one for the sampled Gaussian mechanism (Mironov et al. 2019), which assumes
+// every sample lands in each batch independently with probability BatchSize/DatasetSize. Fit() deals the
+// shuffled data out in batches instead, which is how DP-SGD is nearly always run in practice, but strictly the
+// guarantee is for the Poisson sampled version. ε grows with every step, so the usual approach is to pick the
+// noise and number of epochs to land on a target ε, and stop there.
+type DPSGD struct {
+	Clip            float64
+	NoiseMultiplier float64
+	Delta           float64 // Usually well below 1/DatasetSize
+
+	// How many samples batches are drawn from, set by Fit(). Set it by hand for PartialFit().
+	DatasetSize int
+
+	rdp   []float64 // Privacy spent at each of rdpOrders, summed over every step
+	cache map[float64][]float64
+}
+
+// The Rényi orders ε is tracked at. Each step's cost is worked out for all of them, and the final ε uses
+// whichever gives the tightest bound.
+var rdpOrders = func() []float64 {
+	var orders []float64
+	for a := 2; a <= 64; a++ {
+		orders = append(orders, float64(a))
+	}
+	return append(orders, 80, 96, 128, 192, 256)
+}()
+
+func initDPSGD(clip, noise, delta float64) *DPSGD {
+	return &DPSGD{Clip: clip, NoiseMultiplier: noise, Delta: delta}
+}
+
+// The batch's gradient with every sample's contribution clipped and noise added, and the step recorded with the
+// accountant.
+func (d *DPSGD) gradients(t *Trainer, batch []Sample) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	net := t.net
+	hidGrad, outGrad = zeros(net.hidWeights), zeros(net.outWeights)
+	t.eachGradient(net, batch, func(h, o *mat.Dense, a activationGrads) {
+		// The norm is over every parameter at once, weights and activation parameters alike.
+		norm := mat.Norm(h, 2) * mat.Norm(h, 2)
+		norm += mat.Norm(o, 2) * mat.Norm(o, 2)
+		for _, g := range a {
+			for _, v := range g {
+				norm += v * v
+			}
+		}
+		norm = math.Sqrt(norm)
+		if norm > d.Clip {
+			f := d.Clip / norm
+			h.Scale(f, h)
+			o.Scale(f, o)
+			a.scale(f)
+		}
+		hidGrad.Add(hidGrad, h)
+		outGrad.Add(outGrad, o)
+		actGrad.add(a)
+	})
+
+	sd := d.NoiseMultiplier * d.Clip
+	noise := func(_, _ int, v float64) float64 { return v + sd*t.rnd.NormFloat64() }
+	hidGrad.Apply(noise, hidGrad)
+	outGrad.Apply(noise, outGrad)
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := act.(paramActivation); ok {
+			if actGrad[l] == nil {
+				actGrad[l] = make([]float64, len(p.params()))
+			}
+			for i := range actGrad[l] {
+				actGrad[l][i] = noise(0, 0, actGrad[l][i])
+			}
+		}
+	}
+
+	n := float64(len(batch))
+	hidGrad.Scale(1/n, hidGrad)
+	outGrad.Scale(1/n, outGrad)
+	actGrad.scale(1 / n)
+
+	d.account(float64(len(batch)) / float64(d.DatasetSize))
+	return hidGrad, outGrad, actGrad
+}
+
+// Adds one step at sampling rate q to the privacy spent.
+func (d *DPSGD) account(q float64) {
+	if d.DatasetSize < 1 || q > 1 {
+		q = 1
+	}
+	if d.cache == nil {
+		d.cache = map[float64][]float64{}
+	}
+	step, ok := d.cache[q]
+	if !ok {
+		step = make([]float64, len(rdpOrders))
+		for i, a := range rdpOrders {
+			step[i] = sampledGaussianRDP(q, d.NoiseMultiplier, int(a))
+		}
+		d.cache[q] = step
+	}
+	if d.rdp == nil {
+		d.rdp = make([]float64, len(rdpOrders))
+	}
+	for i, v := range step {
+		d.rdp[i] += v
+	}
+}
+
+// The ε of (ε, Delta)-differential privacy spent so far, 0 before the first step. Converted from Rényi DP with
+// ε = rdp(α) + log(1/δ)/(α-1), at the best order α.
+func (d *DPSGD) Epsilon() float64 {
+	if d.rdp == nil {
+		return 0
+	}
+	best := math.Inf(1)
+	for i, a := range rdpOrders {
+		best = math.Min(best, d.rdp[i]+math.Log(1/d.Delta)/(a-1))
+	}
+	return best
+}
+
+// The Rényi DP of order alpha of one step of the Gaussian mechanism with noise multiplier sigma, applied to a
+// batch that includes each sample with probability q. For integer orders it has a closed form (Mironov et al.
+// 2019, section 3.3):
+//
+//	A = Σ_{i=0}^{α} C(α, i) (1-q)^(α-i) q^i exp((i² - i) / 2σ²),   RDP = log(A) / (α-1)
+//
+// The terms are added up in log space, since exp() of them overflows for small sigma and large alpha.
+func sampledGaussianRDP(q, sigma float64, alpha int) float64 {
+	if sigma == 0 {
+		return math.Inf(1)
+	}
+	if q == 1 {
+		// No subsampling, just the Gaussian mechanism.
+		return float64(alpha) / (2 * sigma * sigma)
+	}
+	logA := math.Inf(-1)
+	for i := 0; i <= alpha; i++ {
+		lgA, _ := math.Lgamma(float64(alpha + 1))
+		lgI, _ := math.Lgamma(float64(i + 1))
+		lgR, _ := math.Lgamma(float64(alpha - i + 1))
+		fi := float64(i)
+		term := lgA - lgI - lgR + fi*math.Log(q) + float64(alpha-i)*math.Log1p(-q) + (fi*fi-fi)/(2*sigma*sigma)
+		logA = logAddExp(logA, term)
+	}
+	return logA / float64(alpha-1)
+}
+
+// log(eᵃ + eᵇ) without overflowing.
+func logAddExp(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/rand"
+)
+
+// One step of experience.
+type Transition struct {
+	State  []float64
+	Action int
+	Reward float64
+	Next   []float64
+	Done   bool // Next is where the episode ended, so nothing comes after it
+}
+
+// Deep Q-learning (Mnih et al. 2015): the network learns Q(s, a), the total discounted reward to expect from
+// taking action a in state s and acting well afterwards, one output per action. Acting well is then just picking
+// the action with the largest output.
+//
+// The network learns from temporal-difference targets, r + Gamma·max Q(s', ·): what a step actually earned plus
+// what the network thinks the next state is worth. Two things keep that from chasing its own tail: steps are
+// remembered and trained on in random batches from Replay, rather than in the order they happened, and the max
+// comes from Target, a copy of the network that lags behind it.
+//
+// Rewards are unbounded sums, so give the network a Linear output layer.
+type DQN struct {
+	Gamma float64 // How much later rewards count, 0.99 from initDQN()
+
+	// Exploration: a random action with probability ε instead of the best one, ε falling linearly from Epsilon
+	// to MinEpsilon over the first ExploreSteps steps.
+	Epsilon, MinEpsilon float64
+	ExploreSteps        int
+
+	BatchSize int // Remembered transitions per training step
+	WarmUp    int // Steps to take before training starts, so the first batches aren't all the same few steps
+	MaxSteps  int // Cuts episodes short after this many steps, 0 for no limit
+
+	Replay *ExperienceReplay // The last 10000 transitions, uniformly sampled, from initDQN()
+	Target *TargetNetwork    // Synced every 100 steps from initDQN()
+
+	// Applies the training steps, one per environment step, so its Optimizer, Momentum and so on are used. Its
+	// BatchSize isn't.
+	Trainer *Trainer
+	Logger  Logger
+
+	net   *MPNN
+	steps int
+	rnd   *rand.Rand
+}
+
+func initDQN(net *MPNN) *DQN {
+	return &DQN{
+		Gamma:        0.99,
+		Epsilon:      1,
+		MinEpsilon:   0.05,
+		ExploreSteps: 1000,
+		BatchSize:    32,
+		WarmUp:       100,
+		MaxSteps:     500,
+		Replay:       initExperienceReplay(10000),
+		Target:       initTargetNetwork(net, 100),
+		Trainer:      initTrainer(net),
+		net:          net,
+		rnd:          newRand(),
+	}
+}
+
+func (d *DQN) log() Logger {
+	if d.Logger == nil {
+		return defaultLogger
+	}
+	return d.Logger
+}
+
+// The best action in a state, by the network's estimates.
+func (d *DQN) Act(state []float64) (int, error) {
+	q, err := d.net.Predict(state)
+	if err != nil {
+		return 0, err
+	}
+	return argmaxSlice(q), nil
+}
+
+func (d *DQN) epsilon() float64 {
+	if d.steps >= d.ExploreSteps {
+		return d.MinEpsilon
+	}
+	return d.Epsilon + (d.MinEpsilon-d.Epsilon)*float64(d.steps)/float64(d.ExploreSteps)
+}
+
+// ε-greedy: the best action most of the time, a random one otherwise.
+func (d *DQN) explore(state []float64) (int, error) {
+	if d.rnd.Float64() < d.epsilon() {
+		return d.rnd.Intn(d.net.out), nil
+	}
+	return d.Act(state)
+}
+
+// One training step on a batch from Replay: the squared error between each transition's Q-value and its TD
+// target, weighted by its importance weight. The TD errors become the transitions' new priorities.
+func (d *DQN) train() error {
+	batch, indices, weights, err := d.Replay.sample(d.BatchSize)
+	if err != nil {
+		return err
+	}
+	caches := make([]forwardCache, len(batch))
+	grads := make([][]float64, len(batch))
+	errors := make([]float64, len(batch))
+	for i, t := range batch {
+		target := t.Reward
+		if !t.Done {
+			next, err := d.Target.Predict(t.Next)
+			if err != nil {
+				return err
+			}
+			target += d.Gamma * next[argmaxSlice(next)]
+		}
+		caches[i] = d.net.forward(t.State)
+		errors[i] = caches[i].hidLayerWeightsOut.At(t.Action, 0) - target
+		// Only the Q-value of the action taken has a target, the other outputs get no gradient.
+		grads[i] = make([]float64, d.net.out)
+		grads[i][t.Action] = weights[i] * errors[i]
+	}
+	h, o, a := d.net.outputGradients(caches, grads, float64(len(batch)))
+	d.Trainer.applyGradients(nil, h, o, a, len(batch))
+	d.Replay.updatePriorities(indices, errors)
+	d.Target.step()
+	return nil
+}
+
+// Plays episodes in the environment, learning as it goes, and returns each episode's total reward.
+func (d *DQN) Run(env Environment, episodes int) ([]float64, error) {
+	if env.Actions() != d.net.out {
+		return nil, fmt.Errorf("environment has %d actions, network has %d outputs", env.Actions(), d.net.out)
+	}
+	if d.BatchSize < 1 {
+		return nil, fmt.Errorf("batch size has to be at least 1")
+	}
+	returns := make([]float64, 0, episodes)
+	for ep := 0; ep < episodes; ep++ {
+		state := env.Reset()
+		total := 0.0
+		for t := 0; d.MaxSteps == 0 || t < d.MaxSteps; t++ {
+			action, err := d.explore(state)
+			if err != nil {
+				return returns, err
+			}
+			next, reward, done := env.Step(action)
+			d.Replay.add(Transition{State: state, Action: action, Reward: reward, Next: next, Done: done})
+			total += reward
+			state = next
+			d.steps++
+
+			if d.steps >= d.WarmUp && d.Replay.Len() >= d.BatchSize {
+				if err := d.train(); err != nil {
+					return returns, err
+				}
+			}
+			if done {
+				break
+			}
+		}
+		returns = append(returns, total)
+		d.log().Debug("episode", "episode", ep+1, "return", total, "epsilon", d.epsilon(), "steps", d.steps)
+	}
+	return returns, nil
+}
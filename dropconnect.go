@@ -0,0 +1,42 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// DropConnect (Wan et al. 2013): for each training step, a random DropConnect fraction of the weights is zeroed
+// out, so the network can't lean too heavily on any single connection. Where dropout switches off whole neurons,
+// DropConnect switches off individual weights.
+//
+// The surviving weights are scaled up by 1/keep during training so the average signal reaching each neuron stays
+// the same. That way nothing needs to change at inference time, where the plain (unmasked) weights are used.
+func (t *Trainer) dropConnectGradients(batch []Sample) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	keep := 1 - t.DropConnect
+	hidMask := t.dropMask(t.net.hidWeights, keep)
+	outMask := t.dropMask(t.net.outWeights, keep)
+
+	dropped := *t.net
+	dropped.hidWeights = mult(t.net.hidWeights, hidMask).(*mat.Dense)
+	dropped.outWeights = mult(t.net.outWeights, outMask).(*mat.Dense)
+	hidGrad, outGrad, actGrad = t.gradients(&dropped, batch)
+
+	// Dropped weights had no effect on the output, so they get no gradient. The rest get scaled the same way
+	// their weight was.
+	hidGrad.MulElem(hidGrad, hidMask)
+	outGrad.MulElem(outGrad, outMask)
+	return hidGrad, outGrad, actGrad
+}
+
+// A matrix of 0s (dropped) and 1/keep (kept), the same shape as the weights w.
+func (t *Trainer) dropMask(w mat.Matrix, keep float64) *mat.Dense {
+	mask := zeros(w)
+	r, c := w.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if t.rnd.Float64() < keep {
+				mask.Set(i, j, 1/keep)
+			}
+		}
+	}
+	return mask
+}
@@ -0,0 +1,46 @@
+package main
+
+// An exponential moving average of the weights over training (Polyak averaging). After every step the average
+// moves a fraction 1-Decay of the way towards the new weights, so it lags behind them, smoothing out the jitter
+// of the last few hundred steps. The averaged network often does better on held out data than the weights
+// training ends on, especially with a learning rate that's still large at the end.
+//
+// Early on the average would mostly be the random starting weights, so the decay is ramped up from 0.1 to Decay
+// over the first steps, as min(Decay, (1+n)/(10+n)) for step n.
+type EMA struct {
+	Decay float64 // 0.999 is typical, closer to 1 averages over more steps
+
+	shadow  []float64
+	net     *MPNN // Shape of the network the average is of
+	updates int
+}
+
+func initEMA(decay float64) *EMA {
+	return &EMA{Decay: decay}
+}
+
+func (e *EMA) update(net *MPNN) {
+	params := net.paramVector()
+	if len(e.shadow) != len(params) {
+		e.shadow, e.net, e.updates = params, net, 0
+		return
+	}
+	d := e.Decay
+	if warm := float64(1+e.updates) / float64(10+e.updates); warm < d {
+		d = warm
+	}
+	for i, p := range params {
+		e.shadow[i] = d*e.shadow[i] + (1-d)*p
+	}
+	e.updates++
+}
+
+// A network with the averaged weights, to evaluate or save like any other. Nil before the first training step.
+func (e *EMA) Model() *MPNN {
+	if e.shadow == nil {
+		return nil
+	}
+	m := e.net.clone()
+	m.setParamVector(e.shadow)
+	return &m
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Turns raw rows of data (e.g. straight from a CSV file, one string per column) into network inputs. Numeric
+// columns are parsed as is, and categorical columns ("red", "green", "blue") are one-hot encoded into one input
+// per category. The categories are learned from the training rows and saved with the Pipeline, so inference encodes
+// rows exactly the way training did.
+//
+// A category that never showed up in training encodes as all zeros, and a blank numeric cell becomes NaN (fill
+// those in with an Imputer before the numbers reach the network).
+type CategoricalEncoder struct {
+	Columns int              `json:"columns"` // Number of raw columns in a row
+	Vocab   map[int][]string `json:"vocab"`   // Categories of each categorical column, in encoding order
+}
+
+// Learns the categories of the given columns from the training rows. If categorical is nil, any column with a
+// value that isn't a number is treated as categorical.
+func fitCategoricalEncoder(rows [][]string, categorical []int) (*CategoricalEncoder, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to fit the encoder on")
+	}
+	e := &CategoricalEncoder{Columns: len(rows[0]), Vocab: make(map[int][]string)}
+	for i, row := range rows {
+		if len(row) != e.Columns {
+			return nil, fmt.Errorf("row %d: row has %d columns, expected %d", i, len(row), e.Columns)
+		}
+	}
+	if categorical == nil {
+		categorical = detectCategorical(rows)
+	}
+
+	for _, c := range categorical {
+		if c < 0 || c >= e.Columns {
+			return nil, fmt.Errorf("categorical column %d out of range (rows have %d columns)", c, e.Columns)
+		}
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			if v := strings.TrimSpace(row[c]); v != "" {
+				seen[v] = true
+			}
+		}
+		var vocab []string
+		for v := range seen {
+			vocab = append(vocab, v)
+		}
+		sort.Strings(vocab)
+		e.Vocab[c] = vocab
+	}
+	return e, nil
+}
+
+// Columns with at least one non-empty value that doesn't parse as a number. The rows must all be the same length.
+func detectCategorical(rows [][]string) []int {
+	var cols []int
+	for c := range rows[0] {
+		for _, row := range rows {
+			v := strings.TrimSpace(row[c])
+			if _, err := strconv.ParseFloat(v, 64); v != "" && err != nil {
+				cols = append(cols, c)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+// How many network inputs an encoded row has.
+func (e *CategoricalEncoder) width() int {
+	n := e.Columns
+	for _, vocab := range e.Vocab {
+		n += len(vocab) - 1
+	}
+	return n
+}
+
+func (e *CategoricalEncoder) encode(row []string) ([]float64, error) {
+	if len(row) != e.Columns {
+		return nil, fmt.Errorf("row has %d columns, expected %d", len(row), e.Columns)
+	}
+	out := make([]float64, 0, e.width())
+	for c, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if vocab, ok := e.Vocab[c]; ok {
+			onehot := make([]float64, len(vocab))
+			if i := sort.SearchStrings(vocab, cell); i < len(vocab) && vocab[i] == cell {
+				onehot[i] = 1
+			}
+			out = append(out, onehot...)
+			continue
+		}
+		if cell == "" {
+			out = append(out, math.NaN())
+			continue
+		}
+		v, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %q isn't a number", c, cell)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Encodes a whole dataset, pairing each row with its target.
+func (e *CategoricalEncoder) samples(rows [][]string, targets [][]float64) ([]Sample, error) {
+	if len(rows) != len(targets) {
+		return nil, fmt.Errorf("%d rows but %d targets", len(rows), len(targets))
+	}
+	data := make([]Sample, len(rows))
+	for i, row := range rows {
+		input, err := e.encode(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		data[i] = Sample{Input: input, Target: targets[i]}
+	}
+	return data, nil
+}
+
+func (e *CategoricalEncoder) encodeJSON() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+func decodeCategoricalEncoder(data string) (*CategoricalEncoder, error) {
+	var e CategoricalEncoder
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, fmt.Errorf("reading categorical encoder: %w", err)
+	}
+	return &e, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+)
+
+// A deep ensemble (Lakshminarayanan et al. 2017): several networks with the same shape, each started from its own
+// random weights and trained on the same data. Averaging them predicts better than any one of them, and where
+// they disagree is a good sign the ensemble is out of its depth.
+type Ensemble struct {
+	members []*MPNN
+}
+
+func initEnsemble(sizes []int, learn float64, n int) *Ensemble {
+	e := &Ensemble{}
+	for i := 0; i < n; i++ {
+		net := initMPNN(sizes, learn)
+		e.members = append(e.members, &net)
+	}
+	return e
+}
+
+// Trains every member on the data, each seeing it in its own random order.
+func (e *Ensemble) Fit(data []Sample, epochs int) error {
+	for _, net := range e.members {
+		if err := initTrainer(net).Fit(data, epochs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type EnsemblePrediction struct {
+	Mean     []float64 // Average output of the members
+	Variance []float64 // How much the members disagree on each output
+
+	// Entropy of the mean prediction (outputs normalized to sum to 1): high when the ensemble can't pick a
+	// class, whether that's because the input is ambiguous or because the members disagree.
+	Entropy float64
+
+	// The part of Entropy that comes from the members disagreeing (the mean prediction's entropy minus the
+	// members' average entropy). Ambiguous inputs that every member finds ambiguous don't raise it, inputs unlike
+	// anything in the training data do.
+	Disagreement float64
+}
+
+func (e *Ensemble) PredictWithUncertainty(input []float64) EnsemblePrediction {
+	var p EnsemblePrediction
+	if len(e.members) == 0 {
+		return p
+	}
+	outs := make([][]float64, len(e.members))
+	memberEntropy := 0.0
+	for m, net := range e.members {
+		out := forwardProp(input, *net)
+		outs[m] = make([]float64, net.out)
+		for i := range outs[m] {
+			outs[m][i] = out.At(i, 0)
+		}
+		memberEntropy += entropy(outs[m])
+	}
+	memberEntropy /= float64(len(e.members))
+
+	n := len(outs[0])
+	p.Mean = make([]float64, n)
+	p.Variance = make([]float64, n)
+	for _, out := range outs {
+		for i, v := range out {
+			p.Mean[i] += v / float64(len(outs))
+		}
+	}
+	if len(outs) > 1 {
+		for _, out := range outs {
+			for i, v := range out {
+				d := v - p.Mean[i]
+				p.Variance[i] += d * d / float64(len(outs)-1)
+			}
+		}
+	}
+	p.Entropy = entropy(p.Mean)
+	p.Disagreement = math.Max(0, p.Entropy-memberEntropy)
+	return p
+}
+
+// Shannon entropy (in nats) of the outputs, after normalizing them to sum to 1.
+func entropy(out []float64) float64 {
+	total := 0.0
+	for _, v := range out {
+		total += v
+	}
+	if total <= 0 {
+		return 0
+	}
+	h := 0.0
+	for _, v := range out {
+		if p := v / total; p > 0 {
+			h -= p * math.Log(p)
+		}
+	}
+	return h
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/exp/rand"
+)
+
+// Something an agent acts in, a step at a time, like an OpenAI Gym environment: it sees an observation, picks one
+// of Actions() actions, and gets a reward and the next observation back, until the episode is done. Reset()
+// starts a new episode.
+type Environment interface {
+	Reset() (observation []float64)
+	Step(action int) (observation []float64, reward float64, done bool)
+	Actions() int
+}
+
+// Plays one episode with a fixed policy, e.g. a trained agent's Act(), and returns its total reward. maxSteps
+// cuts it short, 0 for no limit.
+func runEpisode(env Environment, policy func(observation []float64) (int, error), maxSteps int) (float64, error) {
+	obs := env.Reset()
+	total := 0.0
+	for t := 0; maxSteps == 0 || t < maxSteps; t++ {
+		action, err := policy(obs)
+		if err != nil {
+			return total, err
+		}
+		next, reward, done := env.Step(action)
+		total += reward
+		obs = next
+		if done {
+			break
+		}
+	}
+	return total, nil
+}
+
+// Walk from the top left corner of a Width × Height grid to the bottom right one. Every step costs 0.01 and
+// reaching the goal pays 1, so the shortest path is best. Walls can't be walked into, and neither can the edges.
+// The observation is the position one-hot encoded, a value per cell.
+//
+// Actions are up, right, down and left.
+type GridWorld struct {
+	Width, Height int
+	Walls         map[[2]int]bool // {x, y} cells that are blocked
+
+	x, y int
+}
+
+func (g *GridWorld) Actions() int { return 4 }
+
+func (g *GridWorld) Reset() []float64 {
+	g.x, g.y = 0, 0
+	return g.observe()
+}
+
+func (g *GridWorld) Step(action int) ([]float64, float64, bool) {
+	x, y := g.x, g.y
+	switch action {
+	case 0:
+		y--
+	case 1:
+		x++
+	case 2:
+		y++
+	case 3:
+		x--
+	}
+	if x >= 0 && x < g.Width && y >= 0 && y < g.Height && !g.Walls[[2]int{x, y}] {
+		g.x, g.y = x, y
+	}
+	if g.x == g.Width-1 && g.y == g.Height-1 {
+		return g.observe(), 1, true
+	}
+	return g.observe(), -0.01, false
+}
+
+func (g *GridWorld) observe() []float64 {
+	obs := make([]float64, g.Width*g.Height)
+	obs[g.y*g.Width+g.x] = 1
+	return obs
+}
+
+// Balance a pole on a cart by pushing the cart left or right (actions 0 and 1), the classic control problem
+// (Barto, Sutton & Anderson 1983, with the same physics as Gym's CartPole). Every step the pole stays up pays 1.
+// The episode ends when the pole tips past 12°, the cart leaves the track, or after MaxSteps steps (0 for 200).
+//
+// The observation is the cart's position and velocity, and the pole's angle and angular velocity, roughly scaled
+// to [-1, 1].
+type CartPole struct {
+	MaxSteps int
+
+	x, v, theta, omega float64
+	steps              int
+	rnd                *rand.Rand
+}
+
+func (c *CartPole) Actions() int { return 2 }
+
+func (c *CartPole) Reset() []float64 {
+	if c.rnd == nil {
+		c.rnd = newRand()
+	}
+	// A little off balance, so no two episodes are the same.
+	c.x, c.v, c.theta, c.omega = c.jitter(), c.jitter(), c.jitter(), c.jitter()
+	c.steps = 0
+	return c.observe()
+}
+
+func (c *CartPole) jitter() float64 { return (c.rnd.Float64() - 0.5) / 10 }
+
+func (c *CartPole) Step(action int) ([]float64, float64, bool) {
+	const (
+		gravity, cartMass, poleMass = 9.8, 1.0, 0.1
+		halfLength, force, dt       = 0.5, 10.0, 0.02
+	)
+	f := -force
+	if action == 1 {
+		f = force
+	}
+	total := cartMass + poleMass
+	cos, sin := math.Cos(c.theta), math.Sin(c.theta)
+	temp := (f + poleMass*halfLength*c.omega*c.omega*sin) / total
+	alpha := (gravity*sin - cos*temp) / (halfLength * (4.0/3 - poleMass*cos*cos/total))
+	accel := temp - poleMass*halfLength*alpha*cos/total
+
+	c.x += dt * c.v
+	c.v += dt * accel
+	c.theta += dt * c.omega
+	c.omega += dt * alpha
+	c.steps++
+
+	maxSteps := c.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = 200
+	}
+	fallen := math.Abs(c.theta) > 12*math.Pi/180 || math.Abs(c.x) > 2.4
+	return c.observe(), 1, fallen || c.steps >= maxSteps
+}
+
+func (c *CartPole) observe() []float64 {
+	return []float64{c.x / 2.4, c.v / 3, c.theta / (12 * math.Pi / 180), c.omega / 3}
+}
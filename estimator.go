@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Anything that can learn from samples and then make predictions, so tooling like crossValidate() works with a
+// single network and an ensemble alike.
+type Estimator interface {
+	Fit(data []Sample, epochs int) error
+	Predict(input []float64) ([]float64, error)
+
+	// How well the estimator does on the samples, higher is better.
+	Score(data []Sample) (float64, error)
+}
+
+var (
+	_ Estimator = (*MPNN)(nil)
+	_ Estimator = (*Ensemble)(nil)
+)
+
+// Trains with a default Trainer, use initTrainer() directly for anything more involved.
+func (net *MPNN) Fit(data []Sample, epochs int) error {
+	return initTrainer(net).Fit(data, epochs)
+}
+
+// The negated mean cost over the samples, so that it goes up as the network gets better.
+func (net *MPNN) Score(data []Sample) (float64, error) {
+	return negMeanLoss(net.Predict, net.lossFn(), data)
+}
+
+// The average of every member's output.
+func (e *Ensemble) Predict(input []float64) ([]float64, error) {
+	if len(e.members) == 0 {
+		return nil, fmt.Errorf("ensemble has no members")
+	}
+	if n := e.members[0].in; len(input) != n {
+		return nil, fmt.Errorf("input has %d values, ensemble expects %d", len(input), n)
+	}
+	return e.PredictWithUncertainty(input).Mean, nil
+}
+
+func (e *Ensemble) Score(data []Sample) (float64, error) {
+	return negMeanLoss(e.Predict, e.lossFn(), data)
+}
+
+// The members' loss, which they all share since they're built alike.
+func (e *Ensemble) lossFn() Loss {
+	if len(e.members) == 0 {
+		return SquaredError{}
+	}
+	return e.members[0].lossFn()
+}
+
+// The loss of each prediction, averaged over the samples and negated.
+func negMeanLoss(predict func([]float64) ([]float64, error), loss Loss, data []Sample) (float64, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("no samples to score")
+	}
+	total := 0.0
+	for i, s := range data {
+		out, err := predict(s.Input)
+		if err != nil {
+			return 0, fmt.Errorf("sample %d: %w", i, err)
+		}
+		if len(out) != len(s.Target) {
+			return 0, fmt.Errorf("sample %d has %d targets, estimator gives %d outputs", i, len(s.Target), len(out))
+		}
+		total += loss.loss(out, s.Target)
+	}
+	return -total / float64(len(data)), nil
+}
+
+// k-fold cross-validation: splits the data into folds, and for each one trains a fresh estimator from build() on
+// the rest and scores it on that fold. The data is split in the order given, so shuffle it first if it's sorted.
+func crossValidate(build func() Estimator, data []Sample, folds, epochs int) ([]float64, error) {
+	if folds < 2 || folds > len(data) {
+		return nil, fmt.Errorf("can't split %d samples into %d folds", len(data), folds)
+	}
+	scores := make([]float64, folds)
+	for k := range scores {
+		start, end := k*len(data)/folds, (k+1)*len(data)/folds
+		train := append(append([]Sample(nil), data[:start]...), data[end:]...)
+
+		est := build()
+		if err := est.Fit(train, epochs); err != nil {
+			return nil, fmt.Errorf("fold %d: %w", k, err)
+		}
+		score, err := est.Score(data[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", k, err)
+		}
+		scores[k] = score
+	}
+	return scores, nil
+}
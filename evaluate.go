@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Anything that makes predictions, so MPNN, Ensemble and SparseMPNN can all be evaluated the same way.
+type Predictor interface {
+	Predict(input []float64) ([]float64, error)
+}
+
+// A source of samples that's read a batch at a time, so it never has to fit in memory all at once.
+type Dataset interface {
+	// Returns up to n more samples, and io.EOF once there are none left.
+	Next(n int) ([]Sample, error)
+}
+
+// A Dataset over samples that are already in memory.
+type sliceDataset struct {
+	samples []Sample
+}
+
+func datasetOf(samples []Sample) Dataset {
+	return &sliceDataset{samples: samples}
+}
+
+func (d *sliceDataset) Next(n int) ([]Sample, error) {
+	if len(d.samples) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.samples) {
+		n = len(d.samples)
+	}
+	batch := d.samples[:n]
+	d.samples = d.samples[n:]
+	return batch, nil
+}
+
+// A Dataset read row by row from CSV, with the first `inputs` columns of each row the input and the rest the
+// target. No header row.
+type csvDataset struct {
+	r      *csv.Reader
+	inputs int
+	row    int
+}
+
+func newCSVDataset(r io.Reader, inputs int) Dataset {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	return &csvDataset{r: cr, inputs: inputs}
+}
+
+func (d *csvDataset) Next(n int) ([]Sample, error) {
+	var batch []Sample
+	for len(batch) < n {
+		rec, err := d.r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.row++
+		if len(rec) <= d.inputs {
+			return nil, fmt.Errorf("row %d has %d columns, expected more than %d", d.row, len(rec), d.inputs)
+		}
+		values := make([]float64, len(rec))
+		for i, cell := range rec {
+			if values[i], err = strconv.ParseFloat(cell, 64); err != nil {
+				return nil, fmt.Errorf("row %d column %d: %w", d.row, i, err)
+			}
+		}
+		batch = append(batch, Sample{Input: values[:d.inputs], Target: values[d.inputs:]})
+	}
+	if len(batch) == 0 {
+		return nil, io.EOF
+	}
+	return batch, nil
+}
+
+// Everything Evaluate() adds up over a dataset.
+type Evaluation struct {
+	Samples  int
+	Loss     float64 // Average cost per sample, by the model's own Loss
+	Accuracy float64 // Fraction where the largest output matched the target's (one-hot) class
+
+	// Confusion[t][p] counts the samples of class t predicted as class p.
+	Confusion [][]int
+}
+
+// The loss a model was trained on, squared error for anything that doesn't say.
+func lossOf(model Predictor) Loss {
+	if m, ok := model.(interface{ lossFn() Loss }); ok {
+		return m.lossFn()
+	}
+	return SquaredError{}
+}
+
+// Scores a model on a dataset, batchSize samples at a time. Only the running totals are kept, so the dataset can
+// be far bigger than memory (e.g. a multi-gigabyte CSV file read through newCSVDataset()).
+func Evaluate(model Predictor, data Dataset, batchSize int) (Evaluation, error) {
+	var e Evaluation
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	loss, correct := 0.0, 0
+	cost := lossOf(model)
+	for {
+		batch, err := data.Next(batchSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return e, err
+		}
+		for _, s := range batch {
+			out, err := model.Predict(s.Input)
+			if err != nil {
+				return e, fmt.Errorf("sample %d: %w", e.Samples, err)
+			}
+			if len(out) != len(s.Target) {
+				return e, fmt.Errorf("sample %d has %d targets, model gives %d outputs", e.Samples, len(s.Target), len(out))
+			}
+			if e.Confusion == nil {
+				e.Confusion = make([][]int, len(out))
+				for i := range e.Confusion {
+					e.Confusion[i] = make([]int, len(out))
+				}
+			}
+
+			loss += cost.loss(out, s.Target)
+			truth, guess := argmaxSlice(s.Target), argmaxSlice(out)
+			e.Confusion[truth][guess]++
+			if truth == guess {
+				correct++
+			}
+			e.Samples++
+		}
+	}
+	if e.Samples > 0 {
+		e.Loss = loss / float64(e.Samples)
+		e.Accuracy = float64(correct) / float64(e.Samples)
+	}
+	return e, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// Elastic weight consolidation (Kirkpatrick et al. 2017), for training one network on several tasks in a row
+// without it forgetting the earlier ones.
+//
+// After finishing a task, consolidate() measures how important each weight was for it using the diagonal of the
+// Fisher information (the average squared gradient over the task's data), and remembers the weights as they are.
+// While training on the next task the Trainer adds a penalty of Lambda/2 * Σ F·(w - w*)² to the cost, which acts
+// like a spring pulling the important weights back to where the old task needed them, while leaving the
+// unimportant ones free to learn the new task.
+type EWC struct {
+	Lambda float64 // How stiff the springs are
+
+	hidFisher, outFisher *mat.Dense
+	hidAnchor, outAnchor *mat.Dense
+}
+
+func initEWC(lambda float64) *EWC {
+	return &EWC{Lambda: lambda}
+}
+
+// Records the importance of every weight for the task the network was just trained on. Calling it again after
+// another task adds that task's importance on top, and anchors the weights at their new values.
+func (e *EWC) consolidate(net *MPNN, data []Sample) {
+	hidFisher := zeros(net.hidWeights)
+	outFisher := zeros(net.outWeights)
+	for _, s := range data {
+		h, o, _ := net.gradients(net.forwardSample(s, nil), s.Target)
+		hidFisher.Add(hidFisher, mult(h, h))
+		outFisher.Add(outFisher, mult(o, o))
+	}
+	if len(data) > 0 {
+		hidFisher.Scale(1/float64(len(data)), hidFisher)
+		outFisher.Scale(1/float64(len(data)), outFisher)
+	}
+
+	if e.hidFisher != nil {
+		hidFisher.Add(hidFisher, e.hidFisher)
+		outFisher.Add(outFisher, e.outFisher)
+	}
+	e.hidFisher, e.outFisher = hidFisher, outFisher
+	e.hidAnchor = mat.DenseCopyOf(net.hidWeights)
+	e.outAnchor = mat.DenseCopyOf(net.outWeights)
+}
+
+// The gradient of the penalty, Lambda·F·(w - w*), which gets added to the regular gradient.
+// Zero until the first task has been consolidated.
+func (e *EWC) gradients(net *MPNN) (hidGrad, outGrad *mat.Dense) {
+	hidGrad = zeros(net.hidWeights)
+	outGrad = zeros(net.outWeights)
+	if e.hidFisher == nil {
+		return hidGrad, outGrad
+	}
+	hidGrad.MulElem(e.hidFisher, sub(net.hidWeights, e.hidAnchor))
+	hidGrad.Scale(e.Lambda, hidGrad)
+	outGrad.MulElem(e.outFisher, sub(net.outWeights, e.outAnchor))
+	outGrad.Scale(e.Lambda, outGrad)
+	return hidGrad, outGrad
+}
+
+// How much the penalty adds to the cost right now, to see how hard the springs are pulling.
+func (e *EWC) penalty(net *MPNN) float64 {
+	if e.hidFisher == nil {
+		return 0
+	}
+	total := 0.0
+	for _, p := range [][3]*mat.Dense{
+		{e.hidFisher, net.hidWeights, e.hidAnchor},
+		{e.outFisher, net.outWeights, e.outAnchor},
+	} {
+		r, c := p[0].Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				d := p[1].At(i, j) - p[2].At(i, j)
+				total += p[0].At(i, j) * d * d
+			}
+		}
+	}
+	return e.Lambda / 2 * total
+}
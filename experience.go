@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/rand"
+)
+
+// The last capacity transitions an agent went through, for reinforcement learners to train on in random batches
+// rather than in the order things happened (neighbouring steps are so alike that training on them in order
+// overfits to whatever the agent is doing right now).
+//
+// With Alpha above 0 it's prioritized experience replay (Schaul et al. 2016): transitions are drawn with
+// probability proportional to priority^Alpha, the priority being how wrong the learner was about them last time
+// (see updatePriorities()), so it spends its time on what it hasn't learned yet. That skews the batches, which
+// the importance weights from sample() undo, fully at Beta = 1. The skew matters most once training is
+// converging, so Beta usually starts lower and rises to 1 over BetaSteps samplings. New transitions get the
+// highest priority seen so far, so each one is trained on at least once or so.
+type ExperienceReplay struct {
+	Alpha, Beta float64
+	BetaSteps   int // sample() calls over which Beta rises linearly to 1, 0 leaves it where it is
+
+	items    []Transition
+	tree     []float64 // Sum tree of the priorities^Alpha: leaves at capacity+i, each node the sum of its children
+	capacity int
+	next     int
+	maxPrio  float64
+	draws    int // sample() calls so far, for BetaSteps
+	rnd      *rand.Rand
+}
+
+// Keeps transitions from being drawn never again when their error drops to 0.
+const minPriority = 1e-6
+
+// Uniform sampling.
+func initExperienceReplay(capacity int) *ExperienceReplay {
+	return initPrioritizedReplay(capacity, 0, 0)
+}
+
+// Alpha = 0.6 and Beta = 0.4 rising to 1 over training (set BetaSteps to about the number of training steps)
+// are what Schaul et al. used.
+func initPrioritizedReplay(capacity int, alpha, beta float64) *ExperienceReplay {
+	return &ExperienceReplay{
+		Alpha:    alpha,
+		Beta:     beta,
+		tree:     make([]float64, 2*capacity),
+		capacity: capacity,
+		maxPrio:  1,
+		rnd:      newRand(),
+	}
+}
+
+func (b *ExperienceReplay) Len() int { return len(b.items) }
+
+func (b *ExperienceReplay) add(t Transition) {
+	i := b.next
+	if len(b.items) < b.capacity {
+		b.items = append(b.items, t)
+	} else {
+		b.items[i] = t
+	}
+	b.next = (b.next + 1) % b.capacity
+	b.setPriority(i, b.maxPrio)
+}
+
+func (b *ExperienceReplay) setPriority(i int, p float64) {
+	node := b.capacity + i
+	b.tree[node] = math.Pow(p, b.Alpha)
+	for node /= 2; node >= 1; node /= 2 {
+		b.tree[node] = b.tree[2*node] + b.tree[2*node+1]
+	}
+}
+
+// Draws n transitions (with replacement), along with where they're stored, for updatePriorities(), and their
+// importance weights, (N·P(i))^-Beta scaled so the largest is 1. With uniform sampling every weight is 1.
+func (b *ExperienceReplay) sample(n int) (batch []Transition, indices []int, weights []float64, err error) {
+	if len(b.items) == 0 {
+		return nil, nil, nil, fmt.Errorf("no transitions to replay")
+	}
+	batch, indices, weights = make([]Transition, n), make([]int, n), make([]float64, n)
+	total := b.tree[1]
+	maxWeight := 0.0
+	for k := range batch {
+		// Walk down from the root towards the leaf whose share of the total the random point falls in.
+		u, node := b.rnd.Float64()*total, 1
+		for node < b.capacity {
+			if left := b.tree[2*node]; u < left || b.tree[2*node+1] == 0 {
+				node = 2 * node
+			} else {
+				u -= left
+				node = 2*node + 1
+			}
+		}
+		i := node - b.capacity
+		if i >= len(b.items) { // Only rounding at the very end of the range can get here
+			i = len(b.items) - 1
+		}
+		batch[k], indices[k] = b.items[i], i
+		weights[k] = math.Pow(float64(len(b.items))*b.tree[b.capacity+i]/total, -b.Beta)
+		maxWeight = math.Max(maxWeight, weights[k])
+	}
+	for k := range weights {
+		weights[k] /= maxWeight
+	}
+	b.annealBeta()
+	return batch, indices, weights, nil
+}
+
+// Moves Beta one step of the way to 1. Each step covers an equal share of what's left, so Beta can be changed
+// in between and still reaches 1 on time.
+func (b *ExperienceReplay) annealBeta() {
+	if b.draws < b.BetaSteps {
+		b.Beta += (1 - b.Beta) / float64(b.BetaSteps-b.draws)
+		b.draws++
+	}
+}
+
+// Sets the priorities of sampled transitions to how far off the learner was on them, e.g. their TD errors.
+func (b *ExperienceReplay) updatePriorities(indices []int, errors []float64) {
+	for k, i := range indices {
+		p := math.Abs(errors[k]) + minPriority
+		b.maxPrio = math.Max(b.maxPrio, p)
+		b.setPriority(i, p)
+	}
+}
+
+// A slowly moving copy of a network, for learners whose targets come from the network they're training: with
+// the targets worked out on the copy, they hold still long enough for the network to actually move towards them.
+// Every steps it copies the online network outright; with Every at 0 it instead moves Tau of the way towards it
+// after each step (Polyak averaging, as in DDPG).
+type TargetNetwork struct {
+	Every int
+	Tau   float64
+
+	net    MPNN
+	online *MPNN
+	steps  int
+}
+
+func initTargetNetwork(online *MPNN, every int) *TargetNetwork {
+	return &TargetNetwork{Every: every, net: online.clone(), online: online}
+}
+
+// Called after each training step of the online network.
+func (t *TargetNetwork) step() {
+	t.steps++
+	switch {
+	case t.Every > 0:
+		if t.steps%t.Every == 0 {
+			t.sync()
+		}
+	case t.Tau > 0:
+		target, online := t.net.paramVector(), t.online.paramVector()
+		for i := range target {
+			target[i] += t.Tau * (online[i] - target[i])
+		}
+		t.net.setParamVector(target)
+	}
+}
+
+// Copies the online network as it is now.
+func (t *TargetNetwork) sync() {
+	t.net = t.online.clone()
+}
+
+func (t *TargetNetwork) Predict(input []float64) ([]float64, error) {
+	return t.net.Predict(input)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Federated averaging (McMahan et al. 2017, "FedAvg"): clients train on data that never leaves them, and only
+// share how their training changed the weights. Each round the server picks PerRound of the registered clients at
+// random, sends them the current network, and each one trains its copy for LocalEpochs epochs on its own data and
+// sends back the difference. The server moves the network by the average of those differences, weighted by how
+// many samples each client trained on, and starts the next round.
+//
+// Clients talk to the server with Go's net/rpc over TCP, which isn't encrypted, so keep it on a private network. A
+// client that doesn't report back within RoundTimeout is left out of that round's average, so one that has gone
+// away doesn't hold everyone up. The data stays put, but weight differences can still give away a surprising
+// amount about it, so this alone isn't a privacy guarantee.
+type FedServer struct {
+	Rounds       int
+	PerRound     int // Clients picked each round, and how many have to register before the first one
+	LocalEpochs  int
+	RoundTimeout time.Duration // 0 waits for every picked client, however long that takes
+
+	net      *MPNN
+	listener net.Listener
+	rnd      *rand.Rand
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	clients  int
+	round    int          // The round in progress, starting at 1, 0 before the first
+	selected map[int]bool // Clients picked for the round, removed as they report
+	deltas   []FedUpdate
+	done     bool
+	finished chan struct{}
+}
+
+// The model a newly registered client starts from, as a .mpnn model file, and its ID.
+type FedRegistration struct {
+	ID    int
+	Model []byte
+}
+
+// What a client is asked to do next.
+type FedTask struct {
+	Done    bool // Training is over
+	Round   int
+	Epochs  int
+	Weights WeightUpdate
+}
+
+// A client's result for a round: its trained weights minus the ones it was sent.
+type FedUpdate struct {
+	ID, Round int
+	Samples   int
+	Delta     WeightUpdate
+}
+
+func initFedServer(net *MPNN, rounds, perRound, localEpochs int) *FedServer {
+	s := &FedServer{Rounds: rounds, PerRound: perRound, LocalEpochs: localEpochs, net: net, rnd: newRand(),
+		finished: make(chan struct{})}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Starts listening for clients on addr and running rounds in the background.
+func (s *FedServer) Start(addr string) error {
+	if s.PerRound < 1 || s.Rounds < 1 {
+		return fmt.Errorf("need at least one round and one client per round")
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Fed", &fedRPC{s}); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+	go s.run()
+	return nil
+}
+
+func (s *FedServer) Addr() string { return s.listener.Addr().String() }
+
+// Blocks until every round is done. The server's network is the trained one.
+func (s *FedServer) Wait() {
+	<-s.finished
+	s.listener.Close()
+}
+
+func (s *FedServer) run() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.clients < s.PerRound {
+		s.cond.Wait()
+	}
+	for r := 1; r <= s.Rounds; r++ {
+		s.round, s.deltas = r, nil
+		s.selected = map[int]bool{}
+		for _, id := range s.rnd.Perm(s.clients)[:s.PerRound] {
+			s.selected[id] = true
+		}
+		s.cond.Broadcast()
+
+		deadline := time.Time{}
+		if s.RoundTimeout > 0 {
+			deadline = time.Now().Add(s.RoundTimeout)
+			time.AfterFunc(s.RoundTimeout, func() {
+				s.mu.Lock()
+				s.cond.Broadcast()
+				s.mu.Unlock()
+			})
+		}
+		for len(s.selected) > 0 && (deadline.IsZero() || time.Now().Before(deadline)) {
+			s.cond.Wait()
+		}
+		s.average()
+	}
+	s.done = true
+	s.cond.Broadcast()
+	close(s.finished)
+}
+
+// Moves the network by the sample-weighted average of this round's deltas. Called with mu held.
+func (s *FedServer) average() {
+	total := 0
+	for _, d := range s.deltas {
+		total += d.Samples
+	}
+	if total == 0 {
+		return
+	}
+	hr, hc := s.net.hidWeights.Dims()
+	or, oc := s.net.outWeights.Dims()
+	hid, out := s.net.hidWeights, s.net.outWeights
+	for _, d := range s.deltas {
+		w := float64(d.Samples) / float64(total)
+		hid = add(hid, scale(w, mat.NewDense(hr, hc, d.Delta.Hid))).(*mat.Dense)
+		out = add(out, scale(w, mat.NewDense(or, oc, d.Delta.Out))).(*mat.Dense)
+		for l, act := range []Activation{s.net.hidAct, s.net.outAct} {
+			if p, ok := act.(paramActivation); ok {
+				params := p.params()
+				for i, v := range d.Delta.ActParams[l] {
+					params[i] += w * v
+				}
+			}
+		}
+	}
+	s.net.hidWeights, s.net.outWeights = hid, out
+	if s.net.tied {
+		s.net.retie()
+	}
+}
+
+// The methods net/rpc serves.
+type fedRPC struct{ s *FedServer }
+
+func (r *fedRPC) Register(_ struct{}, reg *FedRegistration) error {
+	s := r.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b bytes.Buffer
+	if err := s.net.writeTo(&b); err != nil {
+		return err
+	}
+	reg.ID, reg.Model = s.clients, b.Bytes()
+	s.clients++
+	s.cond.Broadcast()
+	return nil
+}
+
+// Blocks until the client is picked for a round after lastRound, or training is over.
+func (r *fedRPC) Next(req [2]int, task *FedTask) error {
+	s := r.s
+	id, lastRound := req[0], req[1]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.done && !(s.round > lastRound && s.selected[id]) {
+		s.cond.Wait()
+	}
+	if s.done {
+		task.Done = true
+		return nil
+	}
+	task.Round, task.Epochs, task.Weights = s.round, s.LocalEpochs, s.net.weightUpdate()
+	return nil
+}
+
+func (r *fedRPC) Report(u FedUpdate, _ *struct{}) error {
+	s := r.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u.Round != s.round || !s.selected[u.ID] {
+		return errors.New("update is too late for its round")
+	}
+	delete(s.selected, u.ID)
+	s.deltas = append(s.deltas, u)
+	s.cond.Broadcast()
+	return nil
+}
+
+// Joins the federated server at addr as a client and trains on data whenever picked, until the server's rounds
+// are over. configure, if not nil, sets up the client's Trainer (BatchSize, Validation...).
+func runFedClient(addr string, data []Sample, configure func(t *Trainer)) error {
+	client, err := rpc.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var reg FedRegistration
+	if err := client.Call("Fed.Register", struct{}{}, &reg); err != nil {
+		return err
+	}
+	net, err := readMPNN(bytes.NewReader(reg.Model))
+	if err != nil {
+		return err
+	}
+	if err := net.checkSamples(data); err != nil {
+		return err
+	}
+	t := initTrainer(&net)
+	if configure != nil {
+		configure(t)
+	}
+
+	round := 0
+	for {
+		var task FedTask
+		if err := client.Call("Fed.Next", [2]int{reg.ID, round}, &task); err != nil {
+			return err
+		}
+		if task.Done {
+			return nil
+		}
+		round = task.Round
+		net.setWeights(task.Weights)
+		if err := t.Fit(data, task.Epochs); err != nil {
+			return err
+		}
+
+		delta := net.weightUpdate()
+		subtract(delta.Hid, task.Weights.Hid)
+		subtract(delta.Out, task.Weights.Out)
+		for l := range delta.ActParams {
+			subtract(delta.ActParams[l], task.Weights.ActParams[l])
+		}
+		u := FedUpdate{ID: reg.ID, Round: round, Samples: len(data), Delta: delta}
+		if err := client.Call("Fed.Report", u, &struct{}{}); err != nil {
+			// Too slow for the round, which went ahead without this client. Try again next time.
+			t.log().Warn("federated update rejected", "round", round, "err", err)
+		}
+	}
+}
+
+func subtract(a, b []float64) {
+	for i := range a {
+		a[i] -= b[i]
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// How far off a model's forecasts are, averaged over every forecast value.
+type ForecastErrors struct {
+	Samples int
+	MAE     float64 // Mean absolute error, in the series' own units
+
+	// Mean absolute percentage error, in percent. Targets of exactly 0 are left out, since there's no percentage
+	// of nothing, and it blows up near 0 anyway, so prefer SMAPE or MASE for series that get close.
+	MAPE float64
+
+	// Symmetric MAPE: the error as a percentage of the average size of the target and forecast, from 0 to 200.
+	SMAPE float64
+
+	// Mean absolute scaled error (Hyndman & Koehler 2006): MAE divided by the MAE of the naive forecast (the value
+	// Season steps earlier) on the training series. Below 1 beats the naive forecast, which a forecaster has to do
+	// to be worth anything.
+	MASE float64
+}
+
+// Scores a model's forecasts on windowed samples. scale is what MASE divides by, see naiveScale(); 0 leaves MASE
+// at 0.
+func forecastErrors(predict func([]float64) ([]float64, error), data []Sample, scale float64) (ForecastErrors, error) {
+	var e ForecastErrors
+	if len(data) == 0 {
+		return e, fmt.Errorf("no samples to score")
+	}
+	values, percents := 0, 0
+	for i, s := range data {
+		out, err := predict(s.Input)
+		if err != nil {
+			return e, fmt.Errorf("sample %d: %w", i, err)
+		}
+		if len(out) != len(s.Target) {
+			return e, fmt.Errorf("sample %d has %d targets, model gives %d outputs", i, len(s.Target), len(out))
+		}
+		for j, y := range s.Target {
+			diff := math.Abs(y - out[j])
+			e.MAE += diff
+			if y != 0 {
+				e.MAPE += diff / math.Abs(y)
+				percents++
+			}
+			if size := math.Abs(y) + math.Abs(out[j]); size > 0 {
+				e.SMAPE += 2 * diff / size
+			}
+			values++
+		}
+	}
+	e.Samples = len(data)
+	e.MAE /= float64(values)
+	e.SMAPE *= 100 / float64(values)
+	if percents > 0 {
+		e.MAPE *= 100 / float64(percents)
+	}
+	if scale > 0 {
+		e.MASE = e.MAE / scale
+	}
+	return e, nil
+}
+
+// The mean absolute error of forecasting each step of the series as the step season earlier, over the columns
+// being forecast (nil for all). 0 when the series is too short or never changes.
+func naiveScale(series [][]float64, targets []int, season int) float64 {
+	if season < 1 {
+		season = 1
+	}
+	total, n := 0.0, 0
+	for t := season; t < len(series); t++ {
+		for c := range series[t] {
+			if targets != nil && !containsInt(targets, c) {
+				continue
+			}
+			total += math.Abs(series[t][c] - series[t-season][c])
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / float64(n)
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+// Rolling-origin evaluation: trains on the series up to some point in time (the origin), forecasts what comes
+// after it, then moves the origin forward and does it again. A random train/test split would let the model train
+// on the future and be tested on the past, with neighbouring windows sharing most of their values between the
+// two, so its score would say little about how it forecasts for real.
+type Backtest struct {
+	Windows Windows
+
+	InitialTrain int // Steps of the series the first fold trains on
+	Step         int // Steps the origin moves between folds, 0 for the Windows' Horizon
+	MaxTrain     int // Trains on at most this many of the latest steps, 0 to always train on everything so far
+	Season       int // The naive forecast MASE compares against, 0 for 1 (the last value)
+}
+
+// Runs every fold that fits in the series, training a fresh estimator from build() on each, and scores the
+// forecasts made from each origin up to the next one. Folds come back in time order.
+func (b Backtest) Run(build func() Estimator, series [][]float64, epochs int) ([]ForecastErrors, error) {
+	w := b.Windows
+	if err := w.check(series); err != nil {
+		return nil, err
+	}
+	step := b.Step
+	if step == 0 {
+		step = w.Horizon
+	}
+	if step < 1 || b.InitialTrain < w.Lookback+w.Horizon {
+		return nil, fmt.Errorf("initial training span of %d steps is too short for a lookback of %d and horizon of %d",
+			b.InitialTrain, w.Lookback, w.Horizon)
+	}
+
+	var folds []ForecastErrors
+	for origin := b.InitialTrain; origin+w.Horizon <= len(series); origin += step {
+		start := 0
+		if b.MaxTrain > 0 && origin > b.MaxTrain {
+			start = origin - b.MaxTrain
+		}
+		train, err := w.samples(series[start:origin])
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", len(folds), err)
+		}
+		// Forecasts from each time step between this origin and the next, as long as their horizon fits.
+		var test []Sample
+		for o := origin; o < origin+step && o+w.Horizon <= len(series); o++ {
+			test = append(test, w.window(series, o-w.Lookback))
+		}
+
+		model := build()
+		if err := model.Fit(train, epochs); err != nil {
+			return nil, fmt.Errorf("fold %d: %w", len(folds), err)
+		}
+		e, err := forecastErrors(model.Predict, test, naiveScale(series[start:origin], w.Targets, b.Season))
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", len(folds), err)
+		}
+		folds = append(folds, e)
+	}
+	if len(folds) == 0 {
+		return nil, fmt.Errorf("series of %d steps leaves nothing to forecast after the initial %d", len(series), b.InitialTrain)
+	}
+	return folds, nil
+}
+
+// The folds' errors averaged, each fold weighted by how many forecasts it made.
+func meanForecastErrors(folds []ForecastErrors) ForecastErrors {
+	var m ForecastErrors
+	for _, f := range folds {
+		w := float64(f.Samples)
+		m.MAE += w * f.MAE
+		m.MAPE += w * f.MAPE
+		m.SMAPE += w * f.SMAPE
+		m.MASE += w * f.MASE
+		m.Samples += f.Samples
+	}
+	if m.Samples > 0 {
+		n := float64(m.Samples)
+		m.MAE, m.MAPE, m.SMAPE, m.MASE = m.MAE/n, m.MAPE/n, m.SMAPE/n, m.MASE/n
+	}
+	return m
+}
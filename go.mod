@@ -5,3 +5,16 @@ go 1.19
 require gonum.org/v1/gonum v0.11.0
 
 require golang.org/x/exp v0.0.0-20191002040644-a1355ae1e2c3
+
+require github.com/klauspost/compress v1.16.7
+
+require google.golang.org/protobuf v1.33.0
+
+require google.golang.org/grpc v1.64.1
+
+require (
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
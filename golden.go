@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// A golden file pins down what a model predicts for a fixed set of inputs. Record one with a model that's known to
+// be right, then after touching the math (activations, forward pass, model loading...) check that the model still
+// gives the same answers. Floating point results can shift slightly when operations get reordered, so the check
+// allows some tolerance rather than demanding identical bits.
+type goldenFile struct {
+	Inputs  [][]float64 `json:"inputs"`
+	Outputs [][]float64 `json:"outputs"`
+}
+
+// Runs every input through the model and writes the inputs and predictions to path as JSON.
+func recordGolden(path string, model Predictor, inputs [][]float64) error {
+	g := goldenFile{Inputs: inputs, Outputs: make([][]float64, len(inputs))}
+	for i, input := range inputs {
+		out, err := model.Predict(input)
+		if err != nil {
+			return fmt.Errorf("golden input %d: %w", i, err)
+		}
+		g.Outputs[i] = out
+	}
+	b, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Runs the inputs stored in the golden file at path through the model, and returns an error describing the first
+// prediction that's more than tol away from the recorded one. Nil means the model still behaves the same.
+func checkGolden(path string, model Predictor, tol float64) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var g goldenFile
+	if err := json.Unmarshal(b, &g); err != nil {
+		return fmt.Errorf("reading golden file %s: %w", path, err)
+	}
+	if len(g.Inputs) != len(g.Outputs) {
+		return fmt.Errorf("golden file %s has %d inputs but %d outputs", path, len(g.Inputs), len(g.Outputs))
+	}
+	for i, input := range g.Inputs {
+		out, err := model.Predict(input)
+		if err != nil {
+			return fmt.Errorf("golden input %d: %w", i, err)
+		}
+		if len(out) != len(g.Outputs[i]) {
+			return fmt.Errorf("golden input %d: got %d outputs, recorded %d", i, len(out), len(g.Outputs[i]))
+		}
+		for j, want := range g.Outputs[i] {
+			if diff := math.Abs(out[j] - want); !(diff <= tol) {
+				return fmt.Errorf("golden input %d, output %d: got %v, recorded %v (off by %g, tolerance %g)", i, j, out[j], want, diff, tol)
+			}
+		}
+	}
+	return nil
+}
+
+// For tests: records the golden file if it doesn't exist yet (or update is set, after an intended change in
+// behavior), and checks against it otherwise.
+func assertGolden(path string, model Predictor, inputs [][]float64, tol float64, update bool) error {
+	if _, err := os.Stat(path); update || os.IsNotExist(err) {
+		return recordGolden(path, model, inputs)
+	}
+	return checkGolden(path, model, tol)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Shared tokens for our gRPC services (see ClusterConfig). The client sends "authorization: Bearer <token>" with
+// every call, and the server turns away any call that doesn't carry exactly that.
+
+// Adds the token to every call, as grpc.WithPerRPCCredentials wants.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// The token is as good as a password, so it never goes over a connection that isn't encrypted.
+func (bearerToken) RequireTransportSecurity() bool { return true }
+
+// Checks the token before handing a call to its handler.
+func checkToken(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		auth := md.Get("authorization")
+		// Only the exact "Bearer " scheme counts, a bare token is turned away like a wrong one.
+		if len(auth) != 1 || !strings.HasPrefix(auth[0], "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[0][len("Bearer "):]), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or wrong token")
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// One of several outputs sharing the hidden layer, each predicting something different: a class with softmax and
+// cross entropy alongside a value with a linear output and squared error, say.
+type Head struct {
+	Size   int
+	Act    Activation // nil for Sigmoid
+	Loss   Loss       // nil for half the squared error
+	Weight float64    // How much this head's loss counts towards the total, 0 for 1
+}
+
+// Multi-task outputs: the output layer's neurons split into consecutive heads, each with its own activation and
+// loss, sharing everything before that. Training on several related tasks at once makes the hidden layer learn
+// features that serve all of them, which often helps each one, especially the ones with the least to go on.
+//
+// Heads is both the network's output activation and its loss, so everything that trains or evaluates the network
+// works on it unchanged. Outputs come out joined end to end, see PredictHeads() to get them apart.
+type Heads []Head
+
+// Splits the network's outputs into heads, which have to add up to its number of outputs. Replaces the output
+// activation and the loss.
+func (net *MPNN) setHeads(heads []Head) error {
+	total := 0
+	for i, h := range heads {
+		if h.Size < 1 {
+			return fmt.Errorf("head %d has no outputs", i)
+		}
+		if h.Act == nil {
+			heads[i].Act = Sigmoid{}
+		}
+		if _, ok := heads[i].Act.(paramActivation); ok || pieces(heads[i].Act) != 1 {
+			return fmt.Errorf("head %d: %s can't be used in a head", i, heads[i].Act.name())
+		}
+		if h.Loss == nil {
+			heads[i].Loss = SquaredError{}
+		}
+		if h.Weight == 0 {
+			heads[i].Weight = 1
+		}
+		total += h.Size
+	}
+	if total != net.out {
+		return fmt.Errorf("heads have %d outputs between them, network has %d", total, net.out)
+	}
+	net.outAct, net.loss = Heads(heads), Heads(heads)
+	return nil
+}
+
+// Predict(), with the outputs split up by head. Only for networks with heads.
+func (net *MPNN) PredictHeads(input []float64) ([][]float64, error) {
+	heads, ok := net.outAct.(Heads)
+	if !ok {
+		return nil, fmt.Errorf("network has no heads")
+	}
+	out, err := net.Predict(input)
+	if err != nil {
+		return nil, err
+	}
+	split := make([][]float64, len(heads))
+	for i, h := range heads {
+		split[i], out = out[:h.Size], out[h.Size:]
+	}
+	return split, nil
+}
+
+func (Heads) name() string { return "heads" }
+
+func (h Heads) activate(z mat.Matrix) mat.Matrix {
+	return h.eachHead(z, func(head Head, z ...*mat.Dense) mat.Matrix { return head.Act.activate(z[0]) })
+}
+
+func (h Heads) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	return h.eachHead(z, func(head Head, m ...*mat.Dense) mat.Matrix {
+		return head.Act.backprop(m[0], m[1], m[2])
+	}, a, grad)
+}
+
+// Runs f on each head's rows of the matrices, and stacks the results back up.
+func (h Heads) eachHead(z mat.Matrix, f func(head Head, m ...*mat.Dense) mat.Matrix, more ...mat.Matrix) mat.Matrix {
+	r, c := z.Dims()
+	all := []*mat.Dense{mat.DenseCopyOf(z)}
+	for _, m := range more {
+		all = append(all, mat.DenseCopyOf(m))
+	}
+	out := mat.NewDense(r, c, nil)
+	row := 0
+	for _, head := range h {
+		parts := make([]*mat.Dense, len(all))
+		for i, m := range all {
+			parts[i] = m.Slice(row, row+head.Size, 0, c).(*mat.Dense)
+		}
+		out.Slice(row, row+head.Size, 0, c).(*mat.Dense).Copy(f(head, parts...))
+		row += head.Size
+	}
+	return out
+}
+
+// Each head's loss on its outputs, weighted and added up.
+func (h Heads) loss(out, target []float64) float64 {
+	total := 0.0
+	for _, head := range h {
+		total += head.Weight * head.Loss.loss(out[:head.Size], target[:head.Size])
+		out, target = out[head.Size:], target[head.Size:]
+	}
+	return total
+}
+
+func (h Heads) grad(out, target []float64) []float64 {
+	g := make([]float64, 0, len(out))
+	for _, head := range h {
+		for _, v := range head.Loss.grad(out[:head.Size], target[:head.Size]) {
+			g = append(g, head.Weight*v)
+		}
+		out, target = out[head.Size:], target[head.Size:]
+	}
+	return g
+}
+
+func (h Heads) sample(out []float64, rnd *rand.Rand) []float64 {
+	y := make([]float64, 0, len(out))
+	for _, head := range h {
+		y = append(y, head.Loss.sample(out[:head.Size], rnd)...)
+		out = out[head.Size:]
+	}
+	return y
+}
+
+// How heads are stored in model files.
+type headSpec struct {
+	Size       int     `json:"size"`
+	Activation string  `json:"activation"`
+	Loss       string  `json:"loss"`
+	Weight     float64 `json:"weight"`
+}
+
+func (h Heads) encode() string {
+	specs := make([]headSpec, len(h))
+	for i, head := range h {
+		specs[i] = headSpec{head.Size, head.Act.name(), head.Loss.name(), head.Weight}
+	}
+	b, _ := json.Marshal(specs)
+	return string(b)
+}
+
+func decodeHeads(data string) ([]Head, error) {
+	var specs []headSpec
+	if err := json.Unmarshal([]byte(data), &specs); err != nil {
+		return nil, fmt.Errorf("reading heads: %w", err)
+	}
+	heads := make([]Head, len(specs))
+	for i, s := range specs {
+		act, err := activationByName(s.Activation)
+		if err != nil {
+			return nil, fmt.Errorf("head %d: %w", i, err)
+		}
+		loss, err := lossByName(s.Loss)
+		if err != nil {
+			return nil, fmt.Errorf("head %d: %w", i, err)
+		}
+		heads[i] = Head{Size: s.Size, Act: act, Loss: loss, Weight: s.Weight}
+	}
+	return heads, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// Hessian-free optimization (Martens 2010), a second-order method that never forms the Hessian. Each iteration
+// solves the Newton system (H + λI)·p = -g for the step p with conjugate gradient, which only ever needs
+// Hessian-vector products H·v, and those come from finite differences of the gradient:
+//
+//	H·v ≈ (∇L(θ + εv) - ∇L(θ)) / ε
+//
+// so one product costs one extra full-batch gradient. The damping λ keeps the step inside the region where the
+// quadratic model can be trusted, and is adjusted Levenberg-Marquardt style by comparing how much the loss
+// actually went down against how much the model said it would. Negative curvature, which a neural network's
+// Hessian has plenty of, ends the CG solve early.
+//
+// Mostly of interest for studying how this architecture's loss surface behaves, HessianVector() gives the
+// products directly. For just training a small network LBFGS is usually faster.
+type HessianFree struct {
+	CGIterations int     // Most CG steps per iteration, 0 for 50
+	Damping      float64 // λ to start with, 0 for 1
+
+	// Stops early once no component of the gradient is bigger than this. 0 for 1e-6.
+	GradientTolerance float64
+
+	// Logs the loss, damping and CG steps after every iteration, nil for defaultLogger.
+	Logger Logger
+}
+
+func (h *HessianFree) log() Logger {
+	if h.Logger == nil {
+		return defaultLogger
+	}
+	return h.Logger
+}
+
+// The product of the Hessian of the mean cost over data with v, v laid out like paramVector(). grad is the
+// gradient at the network's current parameters, from lossGradient().
+func (net *MPNN) HessianVector(data []Sample, grad, v []float64) []float64 {
+	x := net.paramVector()
+	norm := floats.Norm(v, 2)
+	if norm == 0 {
+		return make([]float64, len(v))
+	}
+	// Small enough for the difference to be close to the derivative, big enough not to drown in rounding.
+	eps := math.Sqrt(2.2e-16) * (1 + floats.Norm(x, 2)) / norm
+	shifted := net.clone()
+	p := make([]float64, len(x))
+	floats.AddScaledTo(p, x, eps, v)
+	shifted.setParamVector(p)
+	_, g := shifted.lossGradient(data)
+	floats.Sub(g, grad)
+	floats.Scale(1/eps, g)
+	return g
+}
+
+// Trains net on data for up to iterations iterations, returning the final loss.
+func (h *HessianFree) Run(net *MPNN, data []Sample, iterations int) (float64, error) {
+	if len(data) == 0 {
+		return 0, errors.New("no samples to train on")
+	}
+	if err := net.checkSamples(data); err != nil {
+		return 0, err
+	}
+	cgIters, lambda, tol := h.CGIterations, h.Damping, h.GradientTolerance
+	if cgIters < 1 {
+		cgIters = 50
+	}
+	if lambda <= 0 {
+		lambda = 1
+	}
+	if tol == 0 {
+		tol = 1e-6
+	}
+
+	loss, grad := net.lossGradient(data)
+	for it := 0; it < iterations && floats.Norm(grad, math.Inf(1)) > tol; it++ {
+		hv := func(v []float64) []float64 {
+			r := net.HessianVector(data, grad, v)
+			floats.AddScaled(r, lambda, v)
+			return r
+		}
+		step, steps := conjugateGradient(hv, grad, cgIters)
+		for steps == 0 && lambda < 1e10 {
+			// Negative curvature along the gradient itself, so there's no step to take yet. Enough damping always
+			// makes H + λI positive definite.
+			lambda *= 4
+			step, steps = conjugateGradient(hv, grad, cgIters)
+		}
+
+		// What the quadratic model predicts the step will do: gᵀp + ½pᵀ(H + λI)p.
+		predicted := floats.Dot(grad, step) + floats.Dot(step, hv(step))/2
+
+		x := net.paramVector()
+		next := make([]float64, len(x))
+		floats.AddTo(next, x, step)
+		net.setParamVector(next)
+		newLoss, newGrad := net.lossGradient(data)
+
+		rho := (newLoss - loss) / predicted
+		switch {
+		case math.IsNaN(rho) || rho < 0.25:
+			lambda *= 1.5
+		case rho > 0.75:
+			lambda *= 2.0 / 3
+		}
+		if newLoss < loss {
+			loss, grad = newLoss, newGrad
+		} else {
+			// Made things worse, so back out of it. The damping just went up, so the next try is more cautious.
+			net.setParamVector(x)
+		}
+		h.log().Debug("iteration", "iteration", it+1, "loss", loss, "damping", lambda, "cg_steps", steps)
+	}
+	return loss, nil
+}
+
+// Approximately solves A·p = -g with conjugate gradient, starting from p = 0, where av(v) gives A·v. Stops after
+// maxIters steps, once the residual is small, or on hitting a direction of negative curvature, where A isn't
+// positive definite and CG stops making sense. Returns p and the number of steps taken, 0 (and p = 0) if the very
+// first direction had negative curvature.
+func conjugateGradient(av func([]float64) []float64, g []float64, maxIters int) ([]float64, int) {
+	p := make([]float64, len(g))
+	r := make([]float64, len(g)) // Residual, -g - A·p
+	floats.ScaleTo(r, -1, g)
+	d := append([]float64(nil), r...)
+	rr := floats.Dot(r, r)
+	stop := 1e-10 * rr
+
+	i := 0
+	for ; i < maxIters && rr > stop; i++ {
+		ad := av(d)
+		curvature := floats.Dot(d, ad)
+		if curvature <= 0 {
+			break
+		}
+		alpha := rr / curvature
+		floats.AddScaled(p, alpha, d)
+		floats.AddScaled(r, -alpha, ad)
+		newRR := floats.Dot(r, r)
+		floats.AddScaledTo(d, r, newRR/rr, d)
+		rr = newRR
+	}
+	return p, i
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// What happened during one epoch of Trainer.Fit().
+type EpochStats struct {
+	Epoch int
+
+	// Average L2 norm of the gradient per batch, for the [hidden, output] weights. Stacked sigmoids are prone to
+	// vanishing gradients (the hidden norm shrinking towards 0 while the output one doesn't) and exploding ones
+	// (norms growing every epoch), and these make both easy to spot.
+	GradNorms [2]float64
+
+	// Average cost over the training data and Trainer.Validation at the end of the epoch. Only worked out when
+	// the Trainer has validation data.
+	TrainLoss, ValLoss float64
+
+	// Samples trained on this epoch, counting augmented and replayed ones.
+	Samples int
+
+	// Time spent training this epoch (not counting the validation pass), and in every epoch the Trainer has run
+	// so far, across Fit() calls. Samples/Duration is the throughput, see SamplesPerSec().
+	Duration, Elapsed time.Duration
+
+	// The privacy budget spent so far when training with Trainer.DP, see DPSGD.Epsilon().
+	Epsilon float64
+}
+
+func (s EpochStats) SamplesPerSec() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Samples) / s.Duration.Seconds()
+}
+
+// Adds up the stats of every batch in the current epoch.
+type epochTracker struct {
+	gradNorms [2]float64
+	batches   int
+	samples   int
+}
+
+func (e *epochTracker) addBatch(hidGrad, outGrad *mat.Dense, samples int) {
+	e.gradNorms[0] += mat.Norm(hidGrad, 2)
+	e.gradNorms[1] += mat.Norm(outGrad, 2)
+	e.batches++
+	e.samples += samples
+}
+
+func (e *epochTracker) finish(epoch int) EpochStats {
+	stats := EpochStats{Epoch: epoch, Samples: e.samples}
+	if e.batches > 0 {
+		for i := range stats.GradNorms {
+			stats.GradNorms[i] = e.gradNorms[i] / float64(e.batches)
+		}
+	}
+	*e = epochTracker{}
+	return stats
+}
+
+// The stats as key, value pairs for a Logger.
+func (s EpochStats) logArgs() []any {
+	args := []any{"epoch", s.Epoch, "grad_hidden", s.GradNorms[0], "grad_output", s.GradNorms[1], "samples", s.Samples,
+		"duration", s.Duration, "samples_per_sec", s.SamplesPerSec(), "elapsed", s.Elapsed}
+	if s.Epsilon != 0 {
+		args = append(args, "epsilon", s.Epsilon)
+	}
+	if s.TrainLoss != 0 || s.ValLoss != 0 {
+		args = append(args, "loss", s.TrainLoss, "val_loss", s.ValLoss)
+	}
+	return args
+}
+
+func (s EpochStats) String() string {
+	line := fmt.Sprintf("epoch %d  grad norm hidden %.3e output %.3e", s.Epoch, s.GradNorms[0], s.GradNorms[1])
+	if s.TrainLoss != 0 || s.ValLoss != 0 {
+		line += fmt.Sprintf("  loss %.5f val %.5f", s.TrainLoss, s.ValLoss)
+	}
+	if s.Epsilon != 0 {
+		line += fmt.Sprintf("  ε %.3f", s.Epsilon)
+	}
+	if s.Duration > 0 {
+		line += fmt.Sprintf("  %s (%.0f samples/s)", s.Duration.Round(time.Microsecond), s.SamplesPerSec())
+	}
+	return line
+}
@@ -0,0 +1,45 @@
+package main
+
+type FeatureImportance struct {
+	Feature      int
+	AccuracyDrop float64 // How much worse accuracy gets with the feature shuffled
+	LossIncrease float64 // How much the loss goes up with the feature shuffled
+}
+
+// Permutation feature importance (Breiman 2001): a global view of which inputs the network relies on. One
+// feature at a time, its values are shuffled between the samples of a (validation) dataset, which breaks its
+// link to the targets while keeping its distribution the same, and the drop in accuracy/rise in loss is measured.
+// Features the network ignores barely change anything. Each feature is shuffled `repeats` times and averaged,
+// since a single shuffle can be lucky.
+func (net *MPNN) permutationImportance(data []Sample, repeats int) []FeatureImportance {
+	rnd := newRand()
+	baseAcc := net.accuracy(data)
+	baseLoss := net.meanLoss(data)
+
+	// Copies of the inputs so the dataset itself is left alone.
+	shuffled := make([]Sample, len(data))
+	for i, s := range data {
+		shuffled[i] = Sample{Input: append([]float64(nil), s.Input...), Target: s.Target}
+	}
+
+	importance := make([]FeatureImportance, net.in)
+	for f := 0; f < net.in; f++ {
+		importance[f].Feature = f
+		for r := 0; r < repeats; r++ {
+			perm := rnd.Perm(len(data))
+			for i := range shuffled {
+				shuffled[i].Input[f] = data[perm[i]].Input[f]
+			}
+			importance[f].AccuracyDrop += baseAcc - net.accuracy(shuffled)
+			importance[f].LossIncrease += net.meanLoss(shuffled) - baseLoss
+		}
+		if repeats > 0 {
+			importance[f].AccuracyDrop /= float64(repeats)
+			importance[f].LossIncrease /= float64(repeats)
+		}
+		for i := range shuffled {
+			shuffled[i].Input[f] = data[i].Input[f]
+		}
+	}
+	return importance
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type ImputeStrategy string
+
+const (
+	ImputeMean     ImputeStrategy = "mean"
+	ImputeMedian   ImputeStrategy = "median"
+	ImputeConstant ImputeStrategy = "constant"
+)
+
+// Fills in missing values (NaN, which is what blank cells encode to) before they reach the network. A single NaN
+// input turns every output NaN, and a NaN in training spreads into the weights and ruins the whole network.
+//
+// The fill value for each input is worked out from the training data only, and saved with the Pipeline so
+// inference fills gaps exactly the same way.
+type Imputer struct {
+	Strategy ImputeStrategy `json:"strategy"`
+	Constant float64        `json:"constant"`
+	Values   []float64      `json:"values"` // Fill value for each input
+}
+
+// Works out the fill values from the training inputs. Inputs that are missing in every row (or every input with
+// ImputeConstant) get constant.
+func fitImputer(inputs [][]float64, strategy ImputeStrategy, constant float64) (*Imputer, error) {
+	im := &Imputer{Strategy: strategy, Constant: constant}
+	if err := im.fit(inputs); err != nil {
+		return nil, err
+	}
+	return im, nil
+}
+
+func (im *Imputer) fit(inputs [][]float64) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no inputs to fit the imputer on")
+	}
+	switch im.Strategy {
+	case ImputeMean, ImputeMedian, ImputeConstant:
+	default:
+		return fmt.Errorf("unknown imputation strategy %q", im.Strategy)
+	}
+	if err := checkWidths(inputs); err != nil {
+		return err
+	}
+
+	im.Values = make([]float64, len(inputs[0]))
+	for f := range im.Values {
+		im.Values[f] = im.Constant
+		if im.Strategy == ImputeConstant {
+			continue
+		}
+		var present []float64
+		for _, in := range inputs {
+			if !math.IsNaN(in[f]) {
+				present = append(present, in[f])
+			}
+		}
+		if len(present) == 0 {
+			continue
+		}
+		if im.Strategy == ImputeMean {
+			total := 0.0
+			for _, v := range present {
+				total += v
+			}
+			im.Values[f] = total / float64(len(present))
+		} else {
+			sort.Float64s(present)
+			mid := len(present) / 2
+			im.Values[f] = present[mid]
+			if len(present)%2 == 0 {
+				im.Values[f] = (present[mid-1] + present[mid]) / 2
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the input with its missing values filled in.
+func (im *Imputer) transform(input []float64) []float64 {
+	out := append([]float64(nil), input...)
+	for i, v := range out {
+		if math.IsNaN(v) && i < len(im.Values) {
+			out[i] = im.Values[i]
+		}
+	}
+	return out
+}
+
+// Fills in the missing inputs of a whole dataset.
+func (im *Imputer) samples(data []Sample) []Sample {
+	out := make([]Sample, len(data))
+	for i, s := range data {
+		out[i] = Sample{Input: im.transform(s.Input), Target: s.Target}
+	}
+	return out
+}
+
+// Every input has to have as many features as the first, or going through them a feature at a time runs off the
+// end of the shorter ones.
+func checkWidths(inputs [][]float64) error {
+	for i, in := range inputs {
+		if len(in) != len(inputs[0]) {
+			return fmt.Errorf("input %d has %d features, expected %d", i, len(in), len(inputs[0]))
+		}
+	}
+	return nil
+}
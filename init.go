@@ -0,0 +1,116 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Picks the starting values for a rows x cols weight matrix, where rows is the size of the layer and cols the
+// size of the layer feeding into it. Returned row by row, ready for mat.NewDense().
+type Initializer func(rows, cols int) []float64
+
+// The default initialization, uniform between ±1/sqrt(cols). See initRandArray().
+func uniformInit(rows, cols int) []float64 {
+	return initRandArray(rows*cols, float64(cols))
+}
+
+// LeCun normal initialization: Gaussian with variance 1/cols, so every neuron's weighted sum starts out with
+// about the same variance as its inputs. What SELU needs to be self-normalizing.
+func lecunInit(rows, cols int) []float64 {
+	rnd := newRand()
+	w := make([]float64, rows*cols)
+	for i := range w {
+		w[i] = rnd.NormFloat64() / math.Sqrt(float64(cols))
+	}
+	return w
+}
+
+// Orthogonal initialization (Saxe et al. 2013): the weight matrix starts out as a random orthogonal matrix
+// scaled by gain. An orthogonal matrix keeps the length of whatever goes through it, so signals and gradients
+// neither shrink nor blow up as they pass through the layers, which helps deep stacks train from the start.
+// Use a gain of 1 for sigmoid/tanh layers and sqrt(2) for ReLU layers.
+//
+// The matrix comes from the QR decomposition of a random Gaussian matrix. Non-square layers get orthonormal
+// rows or columns, whichever there are fewer of.
+func orthogonalInit(gain float64) Initializer {
+	return func(rows, cols int) []float64 {
+		rnd := newRand()
+
+		// QR needs at least as many rows as columns, so work on the transpose of wide matrices.
+		m, n := rows, cols
+		if m < n {
+			m, n = n, m
+		}
+		a := mat.NewDense(m, n, nil)
+		for i := 0; i < m; i++ {
+			for j := 0; j < n; j++ {
+				a.Set(i, j, rnd.NormFloat64())
+			}
+		}
+
+		var qr mat.QR
+		qr.Factorize(a)
+		var q, r mat.Dense
+		qr.QTo(&q)
+		qr.RTo(&r)
+
+		// Without this sign fix, Q isn't uniformly distributed over the orthogonal matrices.
+		w := mat.NewDense(rows, cols, nil)
+		for i := 0; i < m; i++ {
+			for j := 0; j < n; j++ {
+				v := gain * q.At(i, j) * math.Copysign(1, r.At(j, j))
+				if rows < cols {
+					w.Set(j, i, v)
+				} else {
+					w.Set(i, j, v)
+				}
+			}
+		}
+		return w.RawMatrix().Data
+	}
+}
+
+// Layer-sequential unit-variance initialization (Mishkin & Matas 2015). Run after the weights have been
+// initialized (ideally with orthogonalInit()): a batch of real inputs is pushed through the network, and each
+// layer's weights are rescaled in turn until the values coming out of that layer (before the activation) have a
+// variance of about 1. Starting every layer at a sensible scale means the activations start out neither flat nor
+// saturated, however deep the stack.
+func (net *MPNN) lsuv(inputs [][]float64, tol float64, maxIter int) {
+	if len(inputs) == 0 {
+		return
+	}
+	// One input per column, so a whole layer for the whole batch is one matrix product.
+	x := mat.NewDense(net.in, len(inputs), nil)
+	for j, in := range inputs {
+		x.SetCol(j, in)
+	}
+
+	lsuvLayer(net.hidWeights, x, tol, maxIter)
+	hidOut := net.hidAct.activate(dot(net.hidWeights, x))
+	lsuvLayer(net.outWeights, hidOut, tol, maxIter)
+}
+
+func lsuvLayer(w *mat.Dense, x mat.Matrix, tol float64, maxIter int) {
+	for i := 0; i < maxIter; i++ {
+		v := variance(dot(w, x))
+		if v == 0 || math.Abs(v-1) < tol {
+			return
+		}
+		w.Scale(1/math.Sqrt(v), w)
+	}
+}
+
+func variance(m mat.Matrix) float64 {
+	r, c := m.Dims()
+	n := float64(r * c)
+	mean := mat.Sum(m) / n
+	v := 0.0
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			d := m.At(i, j) - mean
+			v += d * d
+		}
+	}
+	return v / n
+}
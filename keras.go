@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Imports a dense MLP prototyped in Keras so it can be served from Go.
+//
+// archPath is the JSON written by model.to_json(), and weightsPath is the model's weight arrays dumped as JSON:
+//
+//	json.dump([w.tolist() for w in model.get_weights()], open("weights.json", "w"))
+//
+// Reading the .h5 files from model.save() directly would mean pulling in an HDF5 library, so the arrays have to be
+// exported first. The Keras model has to match what this network can represent: exactly two Dense layers with
+// activations MPNN knows (see kerasActivation()), and either use_bias=False or biases that are still all zero (MPNN
+// doesn't have biases).
+func importKeras(archPath, weightsPath string, learn float64) (MPNN, error) {
+	var network MPNN
+
+	archJSON, err := os.ReadFile(archPath)
+	if err != nil {
+		return network, err
+	}
+	layers, err := parseKerasLayers(archJSON)
+	if err != nil {
+		return network, err
+	}
+
+	weightsJSON, err := os.ReadFile(weightsPath)
+	if err != nil {
+		return network, err
+	}
+	var arrays []json.RawMessage
+	if err := json.Unmarshal(weightsJSON, &arrays); err != nil {
+		return network, fmt.Errorf("keras: reading weights: %w", err)
+	}
+
+	// get_weights() lists each Dense layer's kernel followed by its bias (if it has one).
+	var kernels []*mat.Dense
+	for _, l := range layers {
+		if len(arrays) == 0 {
+			return network, errors.New("keras: weights file has fewer arrays than the architecture needs")
+		}
+		var kernel [][]float64
+		if err := json.Unmarshal(arrays[0], &kernel); err != nil {
+			return network, fmt.Errorf("keras: layer %q kernel: %w", l.Name, err)
+		}
+		arrays = arrays[1:]
+
+		if l.UseBias {
+			if len(arrays) == 0 {
+				return network, fmt.Errorf("keras: layer %q is missing its bias array", l.Name)
+			}
+			var bias []float64
+			if err := json.Unmarshal(arrays[0], &bias); err != nil {
+				return network, fmt.Errorf("keras: layer %q bias: %w", l.Name, err)
+			}
+			arrays = arrays[1:]
+			for _, b := range bias {
+				if b != 0 {
+					return network, fmt.Errorf("keras: layer %q has non-zero biases, which MPNN can't represent", l.Name)
+				}
+			}
+		}
+
+		k, err := kerasKernel(kernel, l.Units)
+		if err != nil {
+			return network, fmt.Errorf("keras: layer %q: %w", l.Name, err)
+		}
+		kernels = append(kernels, k)
+	}
+	if len(arrays) != 0 {
+		return network, errors.New("keras: weights file has more arrays than the architecture needs")
+	}
+
+	hidden, in := kernels[0].Dims()
+	out, c := kernels[1].Dims()
+	if c != hidden {
+		return network, fmt.Errorf("keras: output layer expects %d inputs but hidden layer has %d units", c, hidden)
+	}
+
+	network = MPNN{
+		in:         in,
+		hidden:     hidden,
+		out:        out,
+		hidWeights: kernels[0],
+		outWeights: kernels[1],
+		learnRate:  learn,
+	}
+	if network.hidAct, err = kerasActivation(layers[0].Activation); err != nil {
+		return network, fmt.Errorf("keras: layer %q: %w", layers[0].Name, err)
+	}
+	if network.outAct, err = kerasActivation(layers[1].Activation); err != nil {
+		return network, fmt.Errorf("keras: layer %q: %w", layers[1].Name, err)
+	}
+	return network, network.checkShapes()
+}
+
+type kerasDense struct {
+	Name       string `json:"name"`
+	Units      int    `json:"units"`
+	Activation string `json:"activation"`
+	UseBias    bool   `json:"use_bias"`
+}
+
+type kerasLayer struct {
+	ClassName string          `json:"class_name"`
+	Config    json.RawMessage `json:"config"`
+}
+
+// Pulls the Dense layers out of a Sequential model's JSON. Keras 2 nests the layers under config.layers, while
+// older versions stored them as the config itself, so both are accepted.
+func parseKerasLayers(data []byte) ([]kerasDense, error) {
+	var model kerasLayer
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("keras: reading architecture: %w", err)
+	}
+	if model.ClassName != "Sequential" {
+		return nil, fmt.Errorf("keras: only Sequential models are supported, got %q", model.ClassName)
+	}
+
+	var all []kerasLayer
+	var nested struct {
+		Layers []kerasLayer `json:"layers"`
+	}
+	if err := json.Unmarshal(model.Config, &nested); err == nil {
+		all = nested.Layers
+	} else if err := json.Unmarshal(model.Config, &all); err != nil {
+		return nil, fmt.Errorf("keras: reading layers: %w", err)
+	}
+
+	var dense []kerasDense
+	for _, l := range all {
+		switch l.ClassName {
+		case "InputLayer":
+			// Only carries the input shape, which the kernel already tells us.
+		case "Dense":
+			d := kerasDense{UseBias: true}
+			if err := json.Unmarshal(l.Config, &d); err != nil {
+				return nil, fmt.Errorf("keras: reading Dense layer: %w", err)
+			}
+			if _, err := kerasActivation(d.Activation); err != nil {
+				return nil, fmt.Errorf("keras: layer %q: %w", d.Name, err)
+			}
+			dense = append(dense, d)
+		default:
+			return nil, fmt.Errorf("keras: unsupported layer type %q", l.ClassName)
+		}
+	}
+	if len(dense) != 2 {
+		return nil, fmt.Errorf("keras: MPNN needs exactly 2 Dense layers (hidden and output), got %d", len(dense))
+	}
+	return dense, nil
+}
+
+// Keras activations that MPNN calls something else.
+var kerasActivationNames = map[string]string{
+	"exponential": "exp",
+	"silu":        "swish",
+}
+
+// The activation a Dense layer's activation string stands for. Activations with parameters of their own (PReLU's
+// slopes, Maxout's pieces) aren't Dense activations in Keras, and there'd be nothing in the weights file to set
+// them from, so they're turned away.
+func kerasActivation(name string) (Activation, error) {
+	if n, ok := kerasActivationNames[name]; ok {
+		name = n
+	}
+	act, err := activationByName(name)
+	if err != nil {
+		return nil, err
+	}
+	switch act.(type) {
+	case paramActivation, Maxout:
+		return nil, fmt.Errorf("activation %q has parameters, which a Keras Dense layer can't provide", name)
+	}
+	return act, nil
+}
+
+// Keras kernels are shaped (inputs, units), which is the transpose of how MPNN lays out its weights.
+func kerasKernel(kernel [][]float64, units int) (*mat.Dense, error) {
+	if len(kernel) == 0 {
+		return nil, errors.New("empty kernel")
+	}
+	if units < 1 {
+		return nil, fmt.Errorf("layer has %d units, needs at least 1", units)
+	}
+	in := len(kernel)
+	w := mat.NewDense(units, in, nil)
+	for i, row := range kernel {
+		if len(row) != units {
+			return nil, fmt.Errorf("kernel row %d has %d values, expected %d units", i, len(row), units)
+		}
+		for j, v := range row {
+			w.Set(j, i, v)
+		}
+	}
+	return w, nil
+}
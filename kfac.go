@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Kronecker-factored approximate curvature (Martens & Grosse 2015), an approximate natural gradient optimizer.
+// The natural gradient preconditions the gradient with the inverse of the Fisher information matrix, which
+// accounts for how much each direction in weight space actually changes the network's predictions, but for all
+// the weights at once that matrix is far too big to invert. K-FAC treats the layers as independent and
+// approximates each layer's block as a Kronecker product A ⊗ G, where A = E[a·aᵀ] is over the layer's inputs and
+// G = E[δ·δᵀ] over the gradients of its weighted sums. The inverse of a Kronecker product is the product of the
+// inverses, and for a weight matrix W that works out to
+//
+//	ΔW = G⁻¹ · ∇W · A⁻¹
+//
+// so the only matrices to invert are as big as a layer is wide, and the shapes line up exactly with the weight
+// matrices (rows = outputs, columns = inputs).
+//
+// The factors are running averages over batches. G uses gradients for targets drawn from the network's own
+// predictive distribution, which is what makes it the Fisher rather than the "empirical Fisher" of the real
+// targets. That distribution is whichever one the network's loss is the negative log-likelihood of: the output
+// plus unit Gaussian noise for squared error, Poisson counts for PoissonNLL, and so on (see Loss.sample()). Both factors get Damping added to
+// their diagonals before inverting, which matters a lot early on and for neurons that barely respond.
+//
+// Natural gradient steps are much bigger than plain ones, so a much smaller learning rate is usually needed.
+// Tied networks and the activations' own parameters get plain gradient descent steps.
+type KFAC struct {
+	Damping float64 // Added to both factors' diagonals before inverting
+	Decay   float64 // How much of the old factors is kept for each new batch
+
+	a, g [2]*mat.SymDense // [hidden, output] layer
+}
+
+func initKFAC() *KFAC {
+	return &KFAC{Damping: 1e-3, Decay: 0.95}
+}
+
+func (k *KFAC) step(t *Trainer, batch []Sample, hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	if t.net.tied {
+		t.net.step(hidGrad, outGrad, actGrad, t.rate())
+		return
+	}
+	if batch != nil {
+		k.observe(t, batch)
+	}
+	if k.a[0] == nil {
+		// Nothing known about the curvature yet.
+		t.net.step(hidGrad, outGrad, actGrad, t.rate())
+		return
+	}
+	hid, err := k.precondition(0, hidGrad)
+	var out *mat.Dense
+	if err == nil {
+		out, err = k.precondition(1, outGrad)
+	}
+	if err != nil {
+		t.log().Warn("K-FAC taking a plain step", "err", err)
+		hid, out = hidGrad, outGrad
+	}
+	t.net.step(hid, out, actGrad, t.rate())
+}
+
+// Adds the batch's factors to the running averages.
+func (k *KFAC) observe(t *Trainer, batch []Sample) {
+	net, loss := t.net, t.net.lossFn()
+	var a, g [2]*mat.SymDense
+	for _, s := range batch {
+		c := net.forwardSample(s, nil)
+		out := mat.Col(nil, 0, c.hidLayerWeightsOut)
+		grad := loss.grad(out, loss.sample(out, t.rnd))
+		_, hiddenDelta, outputDelta := net.deltas(c, mat.NewDense(net.out, 1, grad))
+
+		in := s.Input
+		if c.sparseIn != nil {
+			in = c.sparseIn.dense()
+		}
+		for l, v := range [][2][]float64{
+			{in, mat.Col(nil, 0, hiddenDelta)},
+			{mat.Col(nil, 0, c.hidDropped), mat.Col(nil, 0, outputDelta)},
+		} {
+			a[l] = addOuter(a[l], v[0])
+			g[l] = addOuter(g[l], v[1])
+		}
+	}
+
+	n := float64(len(batch))
+	for l := range a {
+		a[l].ScaleSym(1/n, a[l])
+		g[l].ScaleSym(1/n, g[l])
+		if k.a[l] == nil {
+			k.a[l], k.g[l] = a[l], g[l]
+			continue
+		}
+		for _, f := range [][2]*mat.SymDense{{k.a[l], a[l]}, {k.g[l], g[l]}} {
+			f[0].ScaleSym(k.Decay, f[0])
+			f[1].ScaleSym(1-k.Decay, f[1])
+			f[0].AddSym(f[0], f[1])
+		}
+	}
+}
+
+// s + v·vᵀ, starting from zero when s is nil.
+func addOuter(s *mat.SymDense, v []float64) *mat.SymDense {
+	if s == nil {
+		s = mat.NewSymDense(len(v), nil)
+	}
+	s.SymRankOne(s, 1, mat.NewVecDense(len(v), v))
+	return s
+}
+
+// G⁻¹ · grad · A⁻¹ for a layer, with damping.
+func (k *KFAC) precondition(layer int, grad *mat.Dense) (*mat.Dense, error) {
+	var gChol, aChol mat.Cholesky
+	if err := damped(&gChol, k.g[layer], k.Damping); err != nil {
+		return nil, err
+	}
+	if err := damped(&aChol, k.a[layer], k.Damping); err != nil {
+		return nil, err
+	}
+	var left, right mat.Dense
+	if err := gChol.SolveTo(&left, grad); err != nil {
+		return nil, err
+	}
+	// A is symmetric, so X·A⁻¹ = (A⁻¹·Xᵀ)ᵀ.
+	if err := aChol.SolveTo(&right, left.T()); err != nil {
+		return nil, err
+	}
+	return mat.DenseCopyOf(right.T()), nil
+}
+
+func damped(chol *mat.Cholesky, s *mat.SymDense, damping float64) error {
+	n := s.SymmetricDim()
+	d := mat.NewSymDense(n, nil)
+	d.CopySym(s)
+	for i := 0; i < n; i++ {
+		d.SetSym(i, i, d.At(i, i)+damping)
+	}
+	if !chol.Factorize(d) {
+		return errors.New("curvature factor isn't positive definite")
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// Full-batch training with L-BFGS (Liu & Nocedal 1989). Where gradient descent only looks at the slope, L-BFGS
+// estimates the curvature from how the gradient changed over the last Memory steps, and uses it to take steps
+// of about the right length in about the right direction, like Newton's method without ever forming the
+// Hessian. A line search then finds a step length along that direction that satisfies the strong Wolfe
+// conditions: the loss goes down by a fair amount, and the slope has flattened out. On a small dataset, where
+// the gradient of all of it is cheap, it typically gets to a lower loss in tens of iterations than SGD does in
+// thousands of epochs.
+//
+// Each iteration looks at every sample, usually a few times over for the line search, so it isn't for big
+// datasets. The Trainer's extras (dropout, regularizers, constraints) aren't involved, it's the plain mean cost
+// being minimized.
+type LBFGS struct {
+	Memory int // Past steps the curvature estimate is built from, 0 for 10
+
+	// Stops early once no component of the gradient is bigger than this. 0 for 1e-6.
+	GradientTolerance float64
+
+	// Logs the loss after every iteration, nil for defaultLogger.
+	Logger Logger
+}
+
+func (l *LBFGS) log() Logger {
+	if l.Logger == nil {
+		return defaultLogger
+	}
+	return l.Logger
+}
+
+// Trains net on data for up to iterations iterations, returning the final loss.
+func (l *LBFGS) Run(net *MPNN, data []Sample, iterations int) (float64, error) {
+	if len(data) == 0 {
+		return 0, errors.New("no samples to train on")
+	}
+	if err := net.checkSamples(data); err != nil {
+		return 0, err
+	}
+	memory, tol := l.Memory, l.GradientTolerance
+	if memory < 1 {
+		memory = 10
+	}
+	if tol == 0 {
+		tol = 1e-6
+	}
+
+	scratch := net.clone()
+	eval := func(x []float64) (float64, []float64) {
+		scratch.setParamVector(x)
+		return scratch.lossGradient(data)
+	}
+	x := net.paramVector()
+	loss, grad := eval(x)
+	var s, y [][]float64 // The last few steps, and how the gradient changed over each
+	dir := make([]float64, len(x))
+
+	for it := 0; it < iterations && floats.Norm(grad, math.Inf(1)) > tol; it++ {
+		lbfgsDirection(dir, grad, s, y)
+		slope := floats.Dot(dir, grad)
+		if slope >= 0 {
+			// The curvature estimate has gone bad, start over from steepest descent.
+			s, y = nil, nil
+			lbfgsDirection(dir, grad, s, y)
+			slope = floats.Dot(dir, grad)
+		}
+
+		// The first step has no curvature to go on, so it's scaled to a sensible length. After that a step of 1
+		// is the one the estimate thinks is right, and usually is.
+		step := 1.0
+		if s == nil {
+			step = math.Min(1, 1/floats.Norm(grad, 2))
+		}
+		step, newX, newLoss, newGrad := wolfeSearch(eval, x, dir, loss, slope, step)
+		if step == 0 {
+			l.log().Warn("L-BFGS line search found nowhere lower, stopping", "iteration", it+1, "loss", loss)
+			break
+		}
+
+		sk, yk := make([]float64, len(x)), make([]float64, len(x))
+		floats.SubTo(sk, newX, x)
+		floats.SubTo(yk, newGrad, grad)
+		// The strong Wolfe conditions guarantee positive curvature in exact arithmetic, but not always in floating
+		// point, and a pair without it would spoil the estimate.
+		if floats.Dot(sk, yk) > 1e-10 {
+			s, y = append(s, sk), append(y, yk)
+			if len(s) > memory {
+				s, y = s[1:], y[1:]
+			}
+		}
+		x, loss, grad = newX, newLoss, newGrad
+		l.log().Debug("iteration", "iteration", it+1, "loss", loss, "step", step)
+	}
+
+	net.setParamVector(x)
+	return loss, nil
+}
+
+// The two-loop recursion: dir = -H·grad, with H the inverse Hessian estimate from the step and gradient change
+// pairs in s and y (oldest first).
+func lbfgsDirection(dir, grad []float64, s, y [][]float64) {
+	copy(dir, grad)
+	alpha := make([]float64, len(s))
+	for i := len(s) - 1; i >= 0; i-- {
+		alpha[i] = floats.Dot(s[i], dir) / floats.Dot(y[i], s[i])
+		floats.AddScaled(dir, -alpha[i], y[i])
+	}
+	if k := len(s) - 1; k >= 0 {
+		// Scales the starting estimate to the most recent curvature, which is what lets a step of 1 work.
+		floats.Scale(floats.Dot(s[k], y[k])/floats.Dot(y[k], y[k]), dir)
+	}
+	for i := range s {
+		beta := floats.Dot(y[i], dir) / floats.Dot(y[i], s[i])
+		floats.AddScaled(dir, alpha[i]-beta, s[i])
+	}
+	floats.Scale(-1, dir)
+}
+
+// Finds a step along dir from x satisfying the strong Wolfe conditions (Nocedal & Wright, algorithms 3.5 and
+// 3.6): bracket a step by growing it until the loss stops going down or the slope turns, then narrow the bracket
+// down. loss and slope are at x itself. Returns 0 for the step if nothing good enough turned up.
+func wolfeSearch(eval func([]float64) (float64, []float64), x, dir []float64, loss, slope, step float64) (float64, []float64, float64, []float64) {
+	const (
+		c1       = 1e-4 // Sufficient decrease
+		c2       = 0.9  // Curvature, the usual value for quasi-Newton methods
+		maxEvals = 20
+	)
+	at := func(a float64) ([]float64, float64, []float64, float64) {
+		p := make([]float64, len(x))
+		floats.AddScaledTo(p, x, a, dir)
+		f, g := eval(p)
+		return p, f, g, floats.Dot(g, dir)
+	}
+
+	// Narrows the bracket between lo and hi (either way round) down to a step that satisfies both conditions. lo
+	// is the best step so far, with loss fLo and slope dLo, and hi has loss fHi.
+	zoom := func(lo, hi, fLo, dLo, fHi float64, evals int) (float64, []float64, float64, []float64) {
+		for ; evals < maxEvals; evals++ {
+			// The minimum of the quadratic through lo's loss and slope and hi's loss, unless it falls too close to
+			// either end, when bisecting is safer.
+			width := hi - lo
+			a := lo - dLo*width*width/(2*(fHi-fLo-dLo*width))
+			if t := (a - lo) / width; !(t > 0.1 && t < 0.9) {
+				a = lo + width/2
+			}
+			p, f, g, d := at(a)
+			if f > loss+c1*a*slope || f >= fLo {
+				hi, fHi = a, f
+				continue
+			}
+			if math.Abs(d) <= -c2*slope {
+				return a, p, f, g
+			}
+			if d*(hi-lo) >= 0 {
+				hi, fHi = lo, fLo
+			}
+			lo, fLo, dLo = a, f, d
+		}
+		if lo != 0 {
+			// Out of evaluations, but lo still satisfies sufficient decrease.
+			p, f, g, _ := at(lo)
+			return lo, p, f, g
+		}
+		return 0, x, loss, nil
+	}
+
+	// Saturated sigmoids have a flat loss surface far out, which satisfies both conditions without being anywhere
+	// good, so the step isn't allowed to grow without bound looking for the bracket.
+	maxStep := 8 * step
+	prev, fPrev, dPrev := 0.0, loss, slope
+	for evals := 0; evals < maxEvals; evals++ {
+		p, f, g, d := at(step)
+		if math.IsNaN(f) || f > loss+c1*step*slope || (evals > 0 && f >= fPrev) {
+			return zoom(prev, step, fPrev, dPrev, f, evals+1)
+		}
+		if math.Abs(d) <= -c2*slope {
+			return step, p, f, g
+		}
+		if d >= 0 {
+			return zoom(step, prev, f, d, fPrev, evals+1)
+		}
+		if step >= maxStep {
+			// Still going downhill, which is good enough.
+			return step, p, f, g
+		}
+		prev, fPrev, dPrev = step, f, d
+		step *= 2
+	}
+	return 0, x, loss, nil
+}
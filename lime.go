@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// A local explanation of one prediction: the output is approximately
+// Intercept + Σ Weight · (1 if the feature is present, 0 if it's replaced by the baseline).
+type Explanation struct {
+	Intercept float64
+	Features  []FeatureWeight // Biggest contributions first
+	Fit       float64         // Weighted R² of the surrogate, how far the explanation can be trusted
+}
+
+type FeatureWeight struct {
+	Feature int
+	Weight  float64
+}
+
+// LIME-style local explanation (Ribeiro et al. 2016) of why the network gave the output it did for one input.
+//
+// The input is perturbed many times by replacing random subsets of its features with the baseline (e.g. zeros,
+// or the training set's mean), and the network scores every perturbed copy. Perturbations closer to the original
+// input get more weight, and a linear model is fit to the scores to approximate the network near this input.
+// Only the `features` features with the largest effect are kept, so the explanation stays readable.
+func (net *MPNN) explainLIME(input []float64, output, samples, features int, baseline []float64) Explanation {
+	d := len(input)
+	if features > d {
+		features = d
+	}
+	rnd := newRand()
+	width := 0.75 * math.Sqrt(float64(d))
+
+	// Row i of masks says which features were kept in perturbation i. The first one is the input as is.
+	masks := mat.NewDense(samples, d, nil)
+	scores := make([]float64, samples)
+	weights := make([]float64, samples)
+	perturbed := make([]float64, d)
+	for i := 0; i < samples; i++ {
+		off := 0
+		for j := range perturbed {
+			if i == 0 || rnd.Float64() < 0.5 {
+				masks.Set(i, j, 1)
+				perturbed[j] = input[j]
+			} else {
+				off++
+				perturbed[j] = baseline[j]
+			}
+		}
+		scores[i] = forwardProp(perturbed, *net).At(output, 0)
+		// Exponential kernel on the distance between the mask and the original (all ones).
+		weights[i] = math.Exp(-float64(off) / (width * width))
+	}
+
+	// Pick the most important features from a fit on all of them, then refit on just those.
+	all := make([]int, d)
+	for j := range all {
+		all[j] = j
+	}
+	coef, _, _ := weightedLeastSquares(masks, all, scores, weights)
+	sort.Slice(all, func(a, b int) bool { return math.Abs(coef[all[a]]) > math.Abs(coef[all[b]]) })
+	chosen := all[:features]
+
+	coef, intercept, fit := weightedLeastSquares(masks, chosen, scores, weights)
+	e := Explanation{Intercept: intercept, Fit: fit}
+	for k, j := range chosen {
+		e.Features = append(e.Features, FeatureWeight{Feature: j, Weight: coef[k]})
+	}
+	sort.Slice(e.Features, func(a, b int) bool {
+		return math.Abs(e.Features[a].Weight) > math.Abs(e.Features[b].Weight)
+	})
+	return e
+}
+
+// Fits y ≈ intercept + x[cols]·coef, weighting each row's squared error. A little ridge penalty keeps the
+// solve stable when features are perfectly correlated across the perturbations. Also returns the weighted R².
+func weightedLeastSquares(x *mat.Dense, cols []int, y, w []float64) (coef []float64, intercept, r2 float64) {
+	n, k := len(y), len(cols)+1
+
+	// Scaling rows by sqrt(w) turns weighted least squares into ordinary least squares.
+	a := mat.NewDense(n, k, nil)
+	b := mat.NewVecDense(n, nil)
+	for i := 0; i < n; i++ {
+		sw := math.Sqrt(w[i])
+		a.Set(i, 0, sw)
+		for c, j := range cols {
+			a.Set(i, c+1, sw*x.At(i, j))
+		}
+		b.SetVec(i, sw*y[i])
+	}
+
+	var ata mat.SymDense
+	ata.SymOuterK(1, a.T())
+	for i := 1; i < k; i++ {
+		ata.SetSym(i, i, ata.At(i, i)+1e-3)
+	}
+	var atb mat.VecDense
+	atb.MulVec(a.T(), b)
+
+	var chol mat.Cholesky
+	beta := mat.NewVecDense(k, nil)
+	if chol.Factorize(&ata) {
+		chol.SolveVecTo(beta, &atb)
+	}
+
+	// Weighted R²
+	mean, total := 0.0, 0.0
+	for i := range y {
+		mean += w[i] * y[i]
+		total += w[i]
+	}
+	mean /= total
+	var ssRes, ssTot float64
+	for i := range y {
+		pred := beta.AtVec(0)
+		for c, j := range cols {
+			pred += beta.AtVec(c+1) * x.At(i, j)
+		}
+		ssRes += w[i] * (y[i] - pred) * (y[i] - pred)
+		ssTot += w[i] * (y[i] - mean) * (y[i] - mean)
+	}
+	if ssTot > 0 {
+		r2 = 1 - ssRes/ssTot
+	}
+
+	coef = make([]float64, len(cols))
+	for c := range cols {
+		coef[c] = beta.AtVec(c + 1)
+	}
+	return coef, beta.AtVec(0), r2
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where the Trainer and its callbacks, and the MetricServer, send what they have to say. Messages come with
+// alternating key, value pairs for the details, e.g. Info("epoch", "epoch", 3, "loss", 0.25), so log pipelines
+// can pick fields out without parsing text.
+//
+// The methods are the same as those of *slog.Logger (log/slog, Go 1.21), so one of those can be dropped straight
+// in, as can anything wrapping zap, zerolog, or whatever the embedding service already uses.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+type LogLevel int
+
+// Spaced like slog's levels.
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+	switch {
+	case l < LevelInfo:
+		return "DEBUG"
+	case l < LevelWarn:
+		return "INFO"
+	case l < LevelError:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// A Logger writing one line per message in logfmt, the same layout as slog's TextHandler:
+//
+//	time=2024-05-01T12:00:00.000Z level=INFO msg=epoch epoch=3 loss=0.25
+//
+// Messages below Level are dropped. Safe to share between goroutines.
+type TextLogger struct {
+	Level LogLevel
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func initTextLogger(w io.Writer, level LogLevel) *TextLogger {
+	return &TextLogger{Level: level, w: w}
+}
+
+// What's used when no Logger is given: info and up, to stderr.
+var defaultLogger Logger = initTextLogger(os.Stderr, LevelInfo)
+
+// Throws everything away.
+type discardLogger struct{}
+
+func (discardLogger) Debug(string, ...any) {}
+func (discardLogger) Info(string, ...any)  {}
+func (discardLogger) Warn(string, ...any)  {}
+func (discardLogger) Error(string, ...any) {}
+
+func (l *TextLogger) Debug(msg string, args ...any) { l.log(LevelDebug, msg, args) }
+func (l *TextLogger) Info(msg string, args ...any)  { l.log(LevelInfo, msg, args) }
+func (l *TextLogger) Warn(msg string, args ...any)  { l.log(LevelWarn, msg, args) }
+func (l *TextLogger) Error(msg string, args ...any) { l.log(LevelError, msg, args) }
+
+func (l *TextLogger) log(level LogLevel, msg string, args []any) {
+	if level < l.Level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=" + time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteString(" level=" + level.String())
+	b.WriteString(" msg=" + logValue(msg))
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			// A key without a value, slog calls it !BADKEY too.
+			b.WriteString(" !BADKEY=" + logValue(fmt.Sprint(args[i])))
+			break
+		}
+		b.WriteString(" " + fmt.Sprint(args[i]) + "=" + logValue(formatLogArg(args[i+1])))
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
+
+func formatLogArg(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(v)
+}
+
+// Quotes values that would otherwise be ambiguous to split on.
+func logValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// The cost training minimizes, comparing the network's output for a sample with its target. It's part of the
+// network (and saved with it) rather than the Trainer, so that everything that reports a loss (Fit()'s stats,
+// Evaluate(), Score()) measures the one the network was trained on. nil is half the squared error.
+type Loss interface {
+	name() string
+	loss(out, target []float64) float64
+
+	// The gradient of loss() with respect to out.
+	grad(out, target []float64) []float64
+
+	// Targets drawn from the distribution the loss is the negative log-likelihood of, given out. The gradients
+	// for these rather than the real targets are what the Fisher is made of (see KFAC).
+	sample(out []float64, rnd *rand.Rand) []float64
+}
+
+type (
+	// Half the squared error, summed over the outputs. The default.
+	SquaredError struct{}
+
+	// The negative log-likelihood of the targets under a Poisson distribution with the outputs as rates, for
+	// counts (orders per day, clicks per hour...). Squared error on raw counts lets the few big ones dominate
+	// and happily predicts negative amounts; this weighs errors the way count noise actually behaves, growing
+	// with the count. The outputs have to be positive, so pair it with the Exp output activation (a log link).
+	PoissonNLL struct{}
+
+	// Like PoissonNLL, but for counts that vary more than a Poisson distribution allows (overdispersed), which
+	// real counts usually do. The variance is μ + μ²/R, so smaller R allows more spread, and as R grows it turns
+	// into Poisson. R is fixed, not learned.
+	NegativeBinomialNLL struct{ R float64 }
+
+	// -Σ y·log p: the negative log-likelihood of the target class when the outputs are class probabilities, so
+	// pair it with the Softmax output activation. Targets are one-hot, or any other probabilities adding up to 1
+	// (smoothed labels, say). Through Softmax the output layer's delta is simply p - y, which doesn't vanish
+	// when a sigmoid would saturate, so confidently wrong outputs get corrected quickly.
+	CrossEntropy struct{}
+)
+
+// The network's loss, or SquaredError if it hasn't been given one.
+func (net *MPNN) lossFn() Loss {
+	if net.loss == nil {
+		return SquaredError{}
+	}
+	return net.loss
+}
+
+// Sets the cost the network is trained and evaluated on.
+func (net *MPNN) setLoss(l Loss) {
+	net.loss = l
+}
+
+func lossByName(name string) (Loss, error) {
+	if strings.HasPrefix(name, "negbinomial") {
+		r, err := strconv.ParseFloat(strings.TrimPrefix(name, "negbinomial"), 64)
+		if err != nil || !(r > 0) {
+			return nil, fmt.Errorf("bad negative binomial loss %q", name)
+		}
+		return NegativeBinomialNLL{R: r}, nil
+	}
+	for _, l := range []Loss{SquaredError{}, PoissonNLL{}, CrossEntropy{}} {
+		if l.name() == name {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown loss %q", name)
+}
+
+func (SquaredError) name() string { return "squared_error" }
+func (SquaredError) loss(out, target []float64) float64 {
+	l := 0.0
+	for i, t := range target {
+		d := t - out[i]
+		l += d * d / 2
+	}
+	return l
+}
+func (SquaredError) grad(out, target []float64) []float64 {
+	g := make([]float64, len(out))
+	for i, t := range target {
+		g[i] = out[i] - t
+	}
+	return g
+}
+
+// Half the squared error is the negative log-likelihood of a Gaussian with unit variance (give or take a
+// constant), centred on the output.
+func (SquaredError) sample(out []float64, rnd *rand.Rand) []float64 {
+	y := make([]float64, len(out))
+	for i, mu := range out {
+		y[i] = mu + rnd.NormFloat64()
+	}
+	return y
+}
+
+// Keeps log() and division away from a rate of exactly zero, which a log link only reaches by underflowing.
+const minRate = 1e-300
+
+// μ - y·log μ + log y!, per output.
+func (PoissonNLL) name() string { return "poisson" }
+func (PoissonNLL) loss(out, target []float64) float64 {
+	l := 0.0
+	for i, y := range target {
+		mu := math.Max(out[i], minRate)
+		lgY, _ := math.Lgamma(y + 1)
+		l += mu - y*math.Log(mu) + lgY
+	}
+	return l
+}
+func (PoissonNLL) grad(out, target []float64) []float64 {
+	g := make([]float64, len(out))
+	for i, y := range target {
+		g[i] = 1 - y/math.Max(out[i], minRate)
+	}
+	return g
+}
+func (PoissonNLL) sample(out []float64, rnd *rand.Rand) []float64 {
+	y := make([]float64, len(out))
+	for i, mu := range out {
+		y[i] = distuv.Poisson{Lambda: math.Max(mu, minRate), Src: rnd}.Rand()
+	}
+	return y
+}
+
+// -log of Γ(y+R) / (Γ(R)·y!) · (R/(R+μ))^R · (μ/(R+μ))^y, per output.
+func (n NegativeBinomialNLL) name() string {
+	return "negbinomial" + strconv.FormatFloat(n.R, 'g', -1, 64)
+}
+func (n NegativeBinomialNLL) loss(out, target []float64) float64 {
+	l := 0.0
+	for i, y := range target {
+		mu := math.Max(out[i], minRate)
+		lgYR, _ := math.Lgamma(y + n.R)
+		lgR, _ := math.Lgamma(n.R)
+		lgY, _ := math.Lgamma(y + 1)
+		l -= lgYR - lgR - lgY + n.R*math.Log(n.R/(n.R+mu)) + y*math.Log(mu/(n.R+mu))
+	}
+	return l
+}
+func (n NegativeBinomialNLL) grad(out, target []float64) []float64 {
+	g := make([]float64, len(out))
+	for i, y := range target {
+		mu := math.Max(out[i], minRate)
+		g[i] = (n.R+y)/(n.R+mu) - y/mu
+	}
+	return g
+}
+
+// A negative binomial count is a Poisson count whose rate is itself drawn from a gamma distribution with mean μ.
+func (n NegativeBinomialNLL) sample(out []float64, rnd *rand.Rand) []float64 {
+	y := make([]float64, len(out))
+	for i, mu := range out {
+		rate := distuv.Gamma{Alpha: n.R, Beta: n.R / math.Max(mu, minRate), Src: rnd}.Rand()
+		y[i] = distuv.Poisson{Lambda: math.Max(rate, minRate), Src: rnd}.Rand()
+	}
+	return y
+}
+
+// Keeps log() and division away from a probability of exactly zero, which softmax only gives by underflowing.
+const minProb = 1e-300
+
+func (CrossEntropy) name() string { return "cross_entropy" }
+func (CrossEntropy) loss(out, target []float64) float64 {
+	l := 0.0
+	for i, y := range target {
+		if y != 0 {
+			l -= y * math.Log(math.Max(out[i], minProb))
+		}
+	}
+	return l
+}
+func (CrossEntropy) grad(out, target []float64) []float64 {
+	g := make([]float64, len(out))
+	for i, y := range target {
+		g[i] = -y / math.Max(out[i], minProb)
+	}
+	return g
+}
+
+// One class drawn with the outputs as its probabilities, one-hot.
+func (CrossEntropy) sample(out []float64, rnd *rand.Rand) []float64 {
+	total := 0.0
+	for _, p := range out {
+		total += p
+	}
+	y := make([]float64, len(out))
+	r := rnd.Float64() * total
+	for i, p := range out {
+		if r -= p; r < 0 || i == len(out)-1 {
+			y[i] = 1
+			break
+		}
+	}
+	return y
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCrossEntropy(t *testing.T) {
+	l, err := lossByName("cross_entropy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.(CrossEntropy); !ok {
+		t.Fatalf("cross_entropy is a %T", l)
+	}
+
+	out := []float64{0.7, 0.2, 0.1}
+	target := []float64{0, 1, 0}
+	if got, want := l.loss(out, target), -math.Log(0.2); math.Abs(got-want) > 1e-12 {
+		t.Errorf("loss = %v, want %v", got, want)
+	}
+
+	// grad() against the loss's slope.
+	const h = 1e-6
+	g := l.grad(out, target)
+	for i := range out {
+		up := append([]float64(nil), out...)
+		down := append([]float64(nil), out...)
+		up[i] += h
+		down[i] -= h
+		if slope := (l.loss(up, target) - l.loss(down, target)) / (2 * h); math.Abs(g[i]-slope) > 1e-4 {
+			t.Errorf("grad[%d] = %v, slope is %v", i, g[i], slope)
+		}
+	}
+
+	// Back through Softmax, the output layer's delta is p - y.
+	z := mat.NewDense(3, 1, []float64{1, -0.5, 2})
+	p := Softmax{}.activate(z)
+	delta := Softmax{}.backprop(z, p, mat.NewDense(3, 1, l.grad(mat.Col(nil, 0, p), target)))
+	for i := range target {
+		if want := p.At(i, 0) - target[i]; math.Abs(delta.At(i, 0)-want) > 1e-12 {
+			t.Errorf("delta[%d] = %v, want %v", i, delta.At(i, 0), want)
+		}
+	}
+}
+
+// The softmax and cross entropy head heads.go describes survives being saved.
+func TestCrossEntropyHead(t *testing.T) {
+	heads := Heads{
+		{Size: 3, Act: Softmax{}, Loss: CrossEntropy{}, Weight: 1},
+		{Size: 1, Act: Linear{}, Loss: SquaredError{}, Weight: 0.5},
+	}
+	decoded, err := decodeHeads(heads.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded[0].Loss.(CrossEntropy); !ok {
+		t.Errorf("first head's loss came back as %T", decoded[0].Loss)
+	}
+}
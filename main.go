@@ -2,8 +2,9 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"math"
-	"time"
+	"os"
 
 	"golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/mat"
@@ -20,6 +21,12 @@ type MPNN struct { // 3 Layer Neural Network
 	hidWeights *mat.Dense // Matrix for input layer -> hidden layer weights
 	outWeights *mat.Dense // Matrix for hidden layer -> input layer weights
 	learnRate  float64    // Scales how quickly SGD should work [Too small = Learns slow -- Too big = Doesn't minimize cost function]
+	dropout    float64    // Fraction of hidden neurons randomly switched off for each training sample (0 = no dropout)
+	schema     *Schema    // What the inputs mean, nil if they haven't been described
+	hidAct     Activation // Nonlinearity of the hidden layer
+	outAct     Activation // Nonlinearity of the output layer
+	tied       bool       // Output weights are the transpose of the hidden weights, see tieWeights()
+	loss       Loss       // What training minimizes, nil for half the squared error
 }
 
 func initRandArray(size int, fromSize float64) []float64 {
@@ -29,7 +36,7 @@ func initRandArray(size int, fromSize float64) []float64 {
 	dist := distuv.Uniform{
 		Min: -1 / math.Sqrt(fromSize),
 		Max: 1 / math.Sqrt(fromSize),
-		Src: rand.NewSource(uint64(time.Now().UnixNano())),
+		Src: newRand(),
 	}
 
 	// Unscaled random
@@ -46,12 +53,19 @@ func initRandArray(size int, fromSize float64) []float64 {
 }
 
 func initMPNN(sizes []int, learn float64) (network MPNN) {
+	return initMPNNWith(sizes, learn, uniformInit)
+}
+
+// Same as initMPNN(), but with a choice of how the starting weights are picked.
+func initMPNNWith(sizes []int, learn float64, init Initializer) (network MPNN) {
 
 	network = MPNN{
 		in:        sizes[0],
 		hidden:    sizes[1],
 		out:       sizes[2],
 		learnRate: learn,
+		hidAct:    Sigmoid{},
+		outAct:    Sigmoid{},
 	}
 
 	// Create weight matrix in between each neuron layer.
@@ -61,10 +75,10 @@ func initMPNN(sizes []int, learn float64) (network MPNN) {
 
 	network.hidWeights = mat.NewDense(
 		network.hidden, network.in,
-		initRandArray(network.hidden*network.in, float64(network.in)))
+		init(network.hidden, network.in))
 	network.outWeights = mat.NewDense(
 		network.out, network.hidden,
-		initRandArray(network.hidden*network.out, float64(network.hidden)))
+		init(network.out, network.hidden))
 
 	return network
 }
@@ -72,18 +86,125 @@ func initMPNN(sizes []int, learn float64) (network MPNN) {
 // This is where the network "predicts" and we get our output.
 // Forward propagation is the algorithm that takes in the input, and calculates the output of each
 // consecutive layer using the weights until reaching the output layer.
-// σ(W ⋅ A)
+// σ(W ⋅ A), where σ is each layer's activation (sigmoid unless setActivations() says otherwise)
 func forwardProp(input []float64, network MPNN) mat.Matrix {
+	return network.forward(input).hidLayerWeightsOut
+}
+
+// The values each layer produced during a forward pass, which backpropagation needs to work out the gradients.
+type forwardCache struct {
+	inLayer            *mat.Dense
+	sparseIn           *SparseVector // Set instead of inLayer when the input was sparse
+	inLayerWeightsIn   mat.Matrix    // Hidden layer weighted sums, before the activation
+	inLayerWeightsOut  mat.Matrix    // Hidden layer activations
+	hidDropped         mat.Matrix    // Hidden layer activations after dropout, what the output layer actually saw
+	dropMask           mat.Matrix    // Scaled dropout mask for the hidden layer, nil when nothing was dropped
+	hidLayerWeightsIn  mat.Matrix    // Output layer weighted sums
+	hidLayerWeightsOut mat.Matrix    // Output layer activations
+}
+
+// Does the actual forward propagation for forwardProp(), keeping the intermediary values around for training.
+func (net *MPNN) forward(input []float64) forwardCache {
+	return net.forwardDropout(input, nil)
+}
+
+// Same as forward(), but if the network uses dropout and rnd is given, a random net.dropout fraction of the
+// hidden neurons are switched off. The rest are scaled up by 1/keep so the output layer sees the same average
+// signal, which means nothing has to be rescaled when predicting without dropout.
+func (net *MPNN) forwardDropout(input []float64, rnd *rand.Rand) forwardCache {
 	inLayer := mat.NewDense(len(input), 1, input)
+	return net.forwardFrom(forwardCache{inLayer: inLayer}, dot(net.hidWeights, inLayer), rnd)
+}
+
+// The rest of the forward pass, once the hidden layer's weighted sums have been worked out from the input.
+func (net *MPNN) forwardFrom(c forwardCache, inLayerWeightsIn mat.Matrix, rnd *rand.Rand) forwardCache {
+	inLayerWeightsOut := net.hidAct.activate(inLayerWeightsIn)
+	c.inLayerWeightsIn = inLayerWeightsIn
+	c.inLayerWeightsOut = inLayerWeightsOut
+	c.hidDropped = inLayerWeightsOut
+
+	if rnd != nil && net.dropout > 0 {
+		keep := 1 - net.dropout
+		mask := mat.NewDense(net.hidden, 1, nil)
+		for i := 0; i < net.hidden; i++ {
+			if rnd.Float64() < keep {
+				mask.Set(i, 0, 1/keep)
+			}
+		}
+		c.dropMask = mask
+		c.hidDropped = mult(inLayerWeightsOut, mask)
+	}
+
+	c.hidLayerWeightsIn = dot(net.outWeights, c.hidDropped)
+	c.hidLayerWeightsOut = net.outAct.activate(c.hidLayerWeightsIn)
+	return c
+}
+
+// Works out how much each weight contributed to the error, i.e. the gradient of the cost (½ the squared error)
+// with respect to every weight. Gradient descent then moves each weight a little bit against its gradient.
+// actGrad holds the gradients of any parameters the activations learn themselves, see paramActivation.
+func (net *MPNN) gradients(c forwardCache, target []float64) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	// Find error
+	// How the cost changes with each output: for squared error that's just the difference between the predicted
+	// output and the target data.
+	return net.backward(c, net.outputGrad(c, target))
+}
 
-	inLayerWeightsIn := dot(network.hidWeights, inLayer)
-	inLayerWeightsOut := apply(sigmoid, inLayerWeightsIn)
+// The gradient of the network's loss with respect to its output, the starting point of backpropagation.
+func (net *MPNN) outputGrad(c forwardCache, target []float64) mat.Matrix {
+	out := mat.Col(nil, 0, c.hidLayerWeightsOut)
+	return mat.NewDense(len(out), 1, net.lossFn().grad(out, target))
+}
 
-	hidLayerWeightsIn := dot(network.outWeights, inLayerWeightsOut)
-	hidLayerWeightsOut := apply(sigmoid, hidLayerWeightsIn)
+// Backpropagation: takes the gradient of some cost with respect to the network's output, and works backwards
+// through the layers to get its gradient with respect to every weight.
+func (net *MPNN) backward(c forwardCache, outputGrad mat.Matrix) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	hiddenError, hiddenDelta, outputDelta := net.deltas(c, outputGrad)
+	outGrad = dot(outputDelta, c.hidDropped.T()).(*mat.Dense)
+	if c.sparseIn != nil {
+		hidGrad = c.sparseIn.outer(hiddenDelta)
+	} else {
+		hidGrad = dot(hiddenDelta, c.inLayer.T()).(*mat.Dense)
+	}
+	if p, ok := net.hidAct.(paramActivation); ok {
+		actGrad[0] = p.paramGrad(c.inLayerWeightsIn, hiddenError)
+	}
+	if p, ok := net.outAct.(paramActivation); ok {
+		actGrad[1] = p.paramGrad(c.hidLayerWeightsIn, outputGrad)
+	}
+	return hidGrad, outGrad, actGrad
+}
 
-	return hidLayerWeightsOut
+// The gradients of the cost with respect to each layer's weighted sums (its deltas), and with respect to the
+// hidden layer's output.
+func (net *MPNN) deltas(c forwardCache, outputGrad mat.Matrix) (hiddenError, hiddenDelta, outputDelta mat.Matrix) {
+	outputDelta = net.outAct.backprop(c.hidLayerWeightsIn, c.hidLayerWeightsOut, outputGrad)
+	hiddenError = dot(net.outWeights.T(), outputDelta) // Calculus to find hidden layer error from the output error
+	if c.dropMask != nil {
+		hiddenError = mult(hiddenError, c.dropMask) // Dropped neurons didn't affect anything
+	}
+	hiddenDelta = net.hidAct.backprop(c.inLayerWeightsIn, c.inLayerWeightsOut, hiddenError)
+	return hiddenError, hiddenDelta, outputDelta
+}
 
+// Takes a gradient descent step, moving every weight (and activation parameter) against its gradient scaled by the
+// learning rate.
+func (net *MPNN) step(hidGrad, outGrad mat.Matrix, actGrad activationGrads, rate float64) {
+	if net.tied {
+		net.hidWeights = sub(net.hidWeights, scale(rate, add(hidGrad, outGrad.T()))).(*mat.Dense)
+		net.retie()
+	} else {
+		net.outWeights = sub(net.outWeights, scale(rate, outGrad)).(*mat.Dense)
+		net.hidWeights = sub(net.hidWeights, scale(rate, hidGrad)).(*mat.Dense)
+	}
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := act.(paramActivation); ok && actGrad[l] != nil {
+			params := p.params()
+			for i, g := range actGrad[l] {
+				params[i] -= rate * g
+			}
+		}
+	}
 }
 
 // This is where the network updates the weights based on gradient descent. (Training)
@@ -91,39 +212,14 @@ func (net *MPNN) backProp(input []float64, target []float64) {
 
 	// Forward Propagation
 	// Can't use fowardProp() because intermediary values are needed
-	inLayer := mat.NewDense(len(input), 1, input)
-
-	inLayerWeightsIn := dot(net.hidWeights, inLayer)
-	inLayerWeightsOut := apply(sigmoid, inLayerWeightsIn)
-
-	hidLayerWeightsIn := dot(net.outWeights, inLayerWeightsOut)
-	hidLayerWeightsOut := apply(sigmoid, hidLayerWeightsIn)
-
-	// Find error
-	// Difference between predicted output and actual value
-	actual := mat.NewDense(len(target), 1, target)      // Target data
-	outputError := sub(actual, hidLayerWeightsOut)      // How far the predicted output is from the target data
-	hiddenError := dot(net.outWeights.T(), outputError) // Calculus to find hidden layer error from the output error
+	c := net.forward(input)
 
 	// Back Propagation
 	// Adjust each weight a little bit by the error of the next layer, going from the output back towards the input.
-
-	// Adjust the output layer weights [hidden -> output] by the output error
-	//This neat little bit of calculus calculates the needed change in weights and adjusts the weights using that.
-	net.outWeights = add(net.outWeights,
-		scale(net.learnRate,
-			dot(mult(outputError, sigmoidDerivative(hidLayerWeightsOut)),
-				inLayerWeightsOut.T()))).(*mat.Dense)
-
-	// Adjust hidden layer weights [input -> hidden] by the hidden error
-	net.hidWeights = add(net.hidWeights,
-		scale(net.learnRate,
-			dot(mult(hiddenError, sigmoidDerivative(inLayerWeightsOut)),
-				inLayer.T()))).(*mat.Dense)
-
-	// ***Haven't gotten to it yet, but all you would have to do now is load it up with some training data and save the weight's
-	// values for future use (so you don't have to train every time you run the program)!
-
+	// The output layer weights [hidden -> output] are adjusted by the output error, and the hidden layer weights
+	// [input -> hidden] by the hidden error.
+	hidGrad, outGrad, actGrad := net.gradients(c, target)
+	net.step(hidGrad, outGrad, actGrad, net.learnRate)
 }
 
 // Since matricies and vectors are interfaces and not types, functions on them don't return values,
@@ -191,6 +287,12 @@ func apply(fn func(i, j int, f float64) float64, m mat.Matrix) mat.Matrix {
 	return out
 }
 
+// An all-zero matrix the same shape as m.
+func zeros(m mat.Matrix) *mat.Dense {
+	r, c := m.Dims()
+	return mat.NewDense(r, c, nil)
+}
+
 func printMatrix(m mat.Matrix) {
 	r, c := m.Dims()
 	for i := 0; i < r; i++ {
@@ -205,17 +307,32 @@ func printMatrix(m mat.Matrix) {
 	fmt.Println()
 }
 
+// Subcommands, run as e.g. `mpnn diff a.mpnn b.mpnn`.
+var commands = map[string]func(args []string, w io.Writer) error{
+	"diff":   runDiff,
+	"export": runExport,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		run, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		if err := run(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var net MPNN = initMPNN([]int{10, 20, 5}, 0.01)
 
 	randInput := initRandArray(net.in, 1)
 	guess := forwardProp(randInput, net)
 
-	fmt.Println("[Input Layer -> Hidden Layer Matrix]")
-	printMatrix(net.hidWeights)
-
-	fmt.Println("[Hidden Layer-> Output Layer Matrix]")
-	printMatrix(net.outWeights)
+	printWeightStats(net.Stats())
 
 	fmt.Println("[Guess Matrix]")
 	printMatrix(guess)
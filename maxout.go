@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Maxout units (Goodfellow et al. 2013): each neuron has K sets of incoming weights instead of one, and outputs
+// the largest of its K weighted sums. With enough pieces that can approximate any convex function, it's linear
+// wherever it's evaluated so it never saturates like a sigmoid, and it was designed to get the most out of
+// dropout.
+//
+// A maxout layer needs K rows of weights per neuron (neuron i uses rows i·K to i·K+K-1), so it's built with
+// initMaxout() rather than swapped in with setActivations().
+type Maxout struct {
+	K int // Pieces per neuron
+}
+
+// A network whose hidden layer is made of maxout units with k pieces each.
+func initMaxout(sizes []int, learn float64, k int) MPNN {
+	network := initMPNN(sizes, learn)
+	network.hidAct = Maxout{K: k}
+	network.hidWeights = mat.NewDense(network.hidden*k, network.in, uniformInit(network.hidden*k, network.in))
+	return network
+}
+
+func (Maxout) name() string { return "maxout" }
+func (m Maxout) activate(z mat.Matrix) mat.Matrix {
+	r, c := z.Dims()
+	out := mat.NewDense(r/m.K, c, nil)
+	for i := 0; i < r/m.K; i++ {
+		for j := 0; j < c; j++ {
+			out.Set(i, j, z.At(m.argmax(z, i, j), j))
+		}
+	}
+	return out
+}
+
+// Only the winning piece affected the output, so it gets all of the gradient.
+func (m Maxout) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	r, c := z.Dims()
+	out := mat.NewDense(r, c, nil)
+	for i := 0; i < r/m.K; i++ {
+		for j := 0; j < c; j++ {
+			out.Set(m.argmax(z, i, j), j, grad.At(i, j))
+		}
+	}
+	return out
+}
+
+// The row of neuron i's largest piece in column j.
+func (m Maxout) argmax(z mat.Matrix, i, j int) int {
+	best, max := i*m.K, math.Inf(-1)
+	for p := i * m.K; p < (i+1)*m.K; p++ {
+		if v := z.At(p, j); v > max {
+			best, max = p, v
+		}
+	}
+	return best
+}
+
+// How many weighted sums feed each neuron of a layer with this activation, 1 for everything but maxout.
+func pieces(a Activation) int {
+	if m, ok := a.(Maxout); ok {
+		return m.K
+	}
+	return 1
+}
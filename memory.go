@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const floatBytes = 8
+
+// Roughly how much memory training takes, in bytes, split up by what it's for. Only the big buffers are counted
+// (matrices and sample data), not Go's own bookkeeping, so the real figure comes out a little higher.
+type MemoryEstimate struct {
+	Params         int // Weights and learned activation parameters
+	Gradients      int // Gradient buffers while working out a batch
+	OptimizerState int // What the Trainer keeps between batches: EWC's anchors and importances, replayed samples...
+	Activations    int // Forward pass caches, and augmented copies of the batch
+}
+
+func (m MemoryEstimate) Total() int {
+	return m.Params + m.Gradients + m.OptimizerState + m.Activations
+}
+
+func (m MemoryEstimate) String() string {
+	var b strings.Builder
+	for _, part := range []struct {
+		name  string
+		bytes int
+	}{{"parameters", m.Params}, {"gradients", m.Gradients}, {"optimizer state", m.OptimizerState},
+		{"activations", m.Activations}, {"total", m.Total()}} {
+		fmt.Fprintf(&b, "%-16s %10s\n", part.name, formatBytes(part.bytes))
+	}
+	return b.String()
+}
+
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Works out how much memory training this Trainer's network with batches of batchSize would take, so the size of a
+// network can be checked against a machine before a run runs out of memory halfway through.
+//
+// Batch size matters most with Workers: every sample's gradient is kept until the whole batch is done, so it can
+// be summed in a fixed order. Worked out sequentially, only a running sum and one sample's gradient are held.
+func (t *Trainer) EstimateMemory(batchSize int) MemoryEstimate {
+	net := t.net
+	hidRows, outRows := net.hidden*pieces(net.hidAct), net.out*pieces(net.outAct)
+	weights := hidRows*net.in + outRows*net.hidden
+	actParams := 0
+	for _, a := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := a.(paramActivation); ok {
+			actParams += len(p.params())
+		}
+	}
+
+	var m MemoryEstimate
+	m.Params = (weights + actParams) * floatBytes
+
+	// A running sum plus the latest sample's gradient, or one gradient per sample in the batch.
+	held := 2
+	if t.Workers > 0 {
+		held = 1 + batchSize
+	}
+	m.Gradients = held * (weights + actParams) * floatBytes
+	if t.DropConnect > 0 {
+		m.Gradients += weights * floatBytes // The masked copy of the weights
+	}
+
+	if t.EWC != nil {
+		m.OptimizerState += 4 * weights * floatBytes // Importance and anchor for every weight
+	}
+	if t.SpectralNorm > 0 {
+		m.OptimizerState += (net.in + net.hidden) * floatBytes
+	}
+	if t.Optimizer == nil && (t.Momentum > 0 || t.Schedules.Momentum != nil) {
+		m.OptimizerState += (weights + actParams) * floatBytes // Velocity
+	}
+	if _, ok := t.Optimizer.(*KFAC); ok {
+		// Both factors of both layers, and their Cholesky factorizations while stepping.
+		m.OptimizerState += 2 * (net.in*net.in + hidRows*hidRows + net.hidden*net.hidden + outRows*outRows) * floatBytes
+	}
+	if _, ok := t.Optimizer.(*Rprop); ok {
+		m.OptimizerState += 3 * (weights + actParams) * floatBytes // Step size, last gradient and last change
+	}
+	if t.Auxiliary != nil {
+		m.Params += net.out * net.hidden * floatBytes    // The head's weights
+		m.Gradients += net.out * net.hidden * floatBytes // and their gradient
+	}
+	if t.Domain != nil {
+		m.Params += net.hidden * floatBytes // The domain classifier
+		m.Gradients += net.hidden * floatBytes
+	}
+	if t.EMA != nil {
+		m.OptimizerState += (weights + actParams) * floatBytes // The averaged copy
+	}
+	if t.Replay != nil {
+		m.OptimizerState += t.Replay.capacity * (net.in + net.out) * floatBytes
+	}
+
+	// Each sample in flight has weighted sums and outputs for both layers, plus a dropout mask.
+	inFlight := 1
+	if t.Workers > 0 {
+		inFlight = t.Workers
+		if batchSize < inFlight {
+			inFlight = batchSize
+		}
+	}
+	perSample := net.in + 2*hidRows + 2*net.hidden + 2*outRows + net.out
+	m.Activations = inFlight * perSample * floatBytes
+	if t.MixupAlpha > 0 || t.Erasing != nil || t.Adversarial != nil {
+		copies := 1
+		if t.Adversarial != nil {
+			copies = 2 // The batch and its perturbed twin
+		}
+		m.Activations += copies * batchSize * (net.in + net.out) * floatBytes
+	}
+	return m
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Index of the largest value in a column vector, i.e. the class the network picked.
+func argmax(m mat.Matrix) int {
+	r, _ := m.Dims()
+	best := 0
+	for i := 1; i < r; i++ {
+		if m.At(i, 0) > m.At(best, 0) {
+			best = i
+		}
+	}
+	return best
+}
+
+func argmaxSlice(v []float64) int {
+	return argmax(mat.NewDense(len(v), 1, v))
+}
+
+// Fraction of samples where the network's most confident output matches the target's (one-hot) class.
+func (net *MPNN) accuracy(data []Sample) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, s := range data {
+		if argmax(forwardProp(s.Input, *net)) == argmaxSlice(s.Target) {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(data))
+}
+
+// Fraction of samples whose target class is among the network's k most confident outputs. With many classes
+// (or classes that are easy to mix up) plain accuracy is too strict to tell a nearly right network from a
+// hopeless one, so top-3 or top-5 accuracy is usually reported next to it. k = 1 is plain accuracy.
+func (net *MPNN) topKAccuracy(data []Sample, k int) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, s := range data {
+		out := forwardProp(s.Input, *net)
+		if inTopK(mat.Col(nil, 0, out), argmaxSlice(s.Target), k) {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(data))
+}
+
+// Whether fewer than k outputs beat the one for class. Ties count in the class's favour.
+func inTopK(out []float64, class, k int) bool {
+	better := 0
+	for _, v := range out {
+		if v > out[class] {
+			better++
+		}
+	}
+	return better < k
+}
+
+// Counts how the network's guesses line up with the truth: confusion[t][p] is the number of samples of class t
+// the network put in class p, so the diagonal holds the ones it got right.
+func (net *MPNN) confusionMatrix(data []Sample) [][]int {
+	confusion := make([][]int, net.out)
+	for i := range confusion {
+		confusion[i] = make([]int, net.out)
+	}
+	for _, s := range data {
+		confusion[argmaxSlice(s.Target)][argmax(forwardProp(s.Input, *net))]++
+	}
+	return confusion
+}
+
+// Row sums (how many samples each class really has), column sums (how many the network guessed for each class),
+// the number right and the total.
+func confusionTotals(confusion [][]int) (truth, guessed []float64, correct, total float64) {
+	truth = make([]float64, len(confusion))
+	guessed = make([]float64, len(confusion))
+	for t, row := range confusion {
+		for p, n := range row {
+			truth[t] += float64(n)
+			guessed[p] += float64(n)
+			total += float64(n)
+		}
+		correct += float64(row[t])
+	}
+	return truth, guessed, correct, total
+}
+
+// Matthews correlation coefficient (in its multi-class form, Gorodkin 2004): the correlation between the true
+// and guessed classes, from -1 (always wrong) through 0 (no better than guessing from the class frequencies) to 1
+// (always right). Unlike accuracy it can't be gamed by always guessing the majority class of an imbalanced
+// dataset, which scores 0.
+func matthewsCorrelation(confusion [][]int) float64 {
+	truth, guessed, correct, total := confusionTotals(confusion)
+	chance, sumT, sumP := 0.0, 0.0, 0.0
+	for k := range truth {
+		chance += truth[k] * guessed[k]
+		sumT += truth[k] * truth[k]
+		sumP += guessed[k] * guessed[k]
+	}
+	denom := math.Sqrt((total*total - sumP) * (total*total - sumT))
+	if denom == 0 {
+		return 0
+	}
+	return (correct*total - chance) / denom
+}
+
+// Cohen's kappa: accuracy corrected for the agreement expected by chance, given how often each class occurs and
+// how often the network guesses it. 1 is perfect, 0 is no better than chance.
+func cohensKappa(confusion [][]int) float64 {
+	truth, guessed, correct, total := confusionTotals(confusion)
+	if total == 0 {
+		return 0
+	}
+	chance := 0.0
+	for k := range truth {
+		chance += truth[k] * guessed[k] / (total * total)
+	}
+	if chance == 1 {
+		return 0
+	}
+	return (correct/total - chance) / (1 - chance)
+}
+
+// Average cost over the samples, see sampleLoss().
+func (net *MPNN) meanLoss(data []Sample) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, s := range data {
+		total += net.sampleLoss(s)
+	}
+	return total / float64(len(data))
+}
+
+// One bin of a reliability diagram: the samples whose confidence fell in [Lower, Upper).
+type CalibrationBin struct {
+	Lower, Upper float64
+	Confidence   float64 // Average confidence of the samples in the bin
+	Accuracy     float64 // Fraction of them the network got right
+	Count        int
+}
+
+// Checks whether the network's confidence can be taken at face value. Samples are grouped by how confident the
+// network was (its largest output), and each group's confidence is compared to how often it was actually right.
+// A well calibrated network that says 0.8 is right 80% of the time.
+//
+// Also returns the expected calibration error (ECE): the gap between confidence and accuracy averaged over the
+// bins, weighted by how many samples each bin holds. 0 is perfectly calibrated.
+func (net *MPNN) calibration(data []Sample, bins int) (curve []CalibrationBin, ece float64) {
+	curve = make([]CalibrationBin, bins)
+	for b := range curve {
+		curve[b].Lower = float64(b) / float64(bins)
+		curve[b].Upper = float64(b+1) / float64(bins)
+	}
+
+	for _, s := range data {
+		out := forwardProp(s.Input, *net)
+		guess := argmax(out)
+		conf := out.At(guess, 0)
+		b := int(conf * float64(bins))
+		if b >= bins {
+			b = bins - 1
+		}
+		if b < 0 {
+			b = 0
+		}
+		curve[b].Confidence += conf
+		if guess == argmaxSlice(s.Target) {
+			curve[b].Accuracy++
+		}
+		curve[b].Count++
+	}
+
+	for b := range curve {
+		if n := float64(curve[b].Count); n > 0 {
+			curve[b].Confidence /= n
+			curve[b].Accuracy /= n
+			ece += n / float64(len(data)) * math.Abs(curve[b].Accuracy-curve[b].Confidence)
+		}
+	}
+	return curve, ece
+}
+
+// Prints the reliability diagram as a bar chart of accuracy per confidence bin. Bars that stop short of the
+// marked confidence (|) are overconfident, bars that go past it are underconfident.
+func printReliabilityDiagram(curve []CalibrationBin, ece float64) {
+	const width = 40
+	for _, b := range curve {
+		if b.Count == 0 {
+			fmt.Printf("%.2f-%.2f %s\n", b.Lower, b.Upper, strings.Repeat(" ", width+1)+"(empty)")
+			continue
+		}
+		bar := []rune(strings.Repeat("█", int(b.Accuracy*width+0.5)) + strings.Repeat(" ", width+1))
+		// Outputs that aren't probabilities (Linear, ReLU...) can be outside 0-1, so keep the marker on the chart.
+		mark := int(math.Max(0, math.Min(width, b.Confidence*width+0.5)))
+		bar[mark] = '|'
+		fmt.Printf("%.2f-%.2f %s acc %.2f conf %.2f n=%d\n", b.Lower, b.Upper, string(bar[:width+1]), b.Accuracy, b.Confidence, b.Count)
+	}
+	fmt.Printf("ECE %.4f\n\n", ece)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Serves training progress to a browser: the page at / draws live loss charts from the Server-Sent Events stream
+// at /events, where every epoch's EpochStats arrives as a JSON message. A page opened halfway through training
+// gets every earlier epoch first, so it always shows the whole run. Add it to Trainer.Callbacks:
+//
+//	srv, err := startMetricServer("localhost:8080")
+//	defer srv.Close()
+//	t.Callbacks = append(t.Callbacks, srv)
+//
+// It's meant for watching a run from the same machine (or through an SSH tunnel), so keep it on localhost.
+type MetricServer struct {
+	Logger Logger // nil for defaultLogger
+
+	server *http.Server
+	addr   string
+
+	mu      sync.Mutex
+	history [][]byte
+	clients map[chan []byte]bool
+}
+
+// Starts serving on addr, e.g. "localhost:8080". Port 0 picks a free one, see Addr().
+func startMetricServer(addr string) (*MetricServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &MetricServer{addr: ln.Addr().String(), clients: map[chan []byte]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.page)
+	mux.HandleFunc("/events", s.events)
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(ln); err != http.ErrServerClosed {
+			s.log().Error("metric server stopped", "addr", s.addr, "err", err)
+		}
+	}()
+	return s, nil
+}
+
+func (s *MetricServer) log() Logger {
+	if s.Logger == nil {
+		return defaultLogger
+	}
+	return s.Logger
+}
+
+// Where the server is listening.
+func (s *MetricServer) Addr() string { return s.addr }
+
+func (s *MetricServer) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	msg, err := json.Marshal(stats)
+	if err != nil {
+		s.log().Warn("can't send epoch stats", "epoch", stats.Epoch, "err", err) // NaN losses, most likely
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, msg)
+	for c := range s.clients {
+		// A browser that can't keep up misses an update rather than holding up training.
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+	return false
+}
+
+// Stops the server, ending every open stream.
+func (s *MetricServer) Close() error {
+	return s.server.Close()
+}
+
+func (s *MetricServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Subscribing and catching up happen under the same lock, so no epoch is sent twice or skipped.
+	c := make(chan []byte, 64)
+	s.mu.Lock()
+	for _, msg := range s.history {
+		fmt.Fprintf(w, "data: %s\n\n", msg)
+	}
+	s.clients[c] = true
+	s.mu.Unlock()
+	flusher.Flush()
+	s.log().Debug("metrics client connected", "remote", r.RemoteAddr)
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		s.log().Debug("metrics client disconnected", "remote", r.RemoteAddr)
+	}()
+	for {
+		select {
+		case msg := <-c:
+			fmt.Fprintf(w, "data: %s\n\n", msg)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *MetricServer) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, metricPage)
+}
+
+// No libraries, just a canvas per chart redrawn whenever an epoch comes in.
+const metricPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>MPNN training</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+canvas { border: 1px solid #ccc; display: block; margin-bottom: 1.5em; }
+#status { color: #666; }
+</style>
+</head>
+<body>
+<h2>Training <span id="status">connecting…</span></h2>
+<h3>Loss <small style="color:#1f77b4">train</small> <small style="color:#ff7f0e">validation</small></h3>
+<canvas id="loss" width="800" height="250"></canvas>
+<h3>Gradient norm <small style="color:#2ca02c">hidden</small> <small style="color:#d62728">output</small></h3>
+<canvas id="grad" width="800" height="250"></canvas>
+<script>
+const epochs = [];
+
+function draw(id, lines) {
+	const c = document.getElementById(id), g = c.getContext("2d");
+	g.clearRect(0, 0, c.width, c.height);
+	const all = lines.flatMap(l => epochs.map(l.get)).filter(isFinite);
+	if (all.length == 0) return;
+	let min = Math.min(...all), max = Math.max(...all);
+	if (max == min) max = min + 1;
+	const x = i => 40 + i * (c.width - 50) / Math.max(epochs.length - 1, 1);
+	const y = v => 10 + (max - v) * (c.height - 30) / (max - min);
+	g.fillStyle = "#666";
+	g.fillText(max.toPrecision(4), 2, 14);
+	g.fillText(min.toPrecision(4), 2, c.height - 20);
+	g.fillText("epoch " + epochs.length, c.width - 70, c.height - 4);
+	for (const l of lines) {
+		g.strokeStyle = l.color;
+		g.beginPath();
+		epochs.forEach((e, i) => i ? g.lineTo(x(i), y(l.get(e))) : g.moveTo(x(i), y(l.get(e))));
+		g.stroke();
+	}
+}
+
+const events = new EventSource("/events");
+events.onopen = () => document.getElementById("status").textContent = "";
+events.onerror = () => document.getElementById("status").textContent = "(disconnected)";
+events.onmessage = m => {
+	epochs.push(JSON.parse(m.data));
+	draw("loss", [{get: e => e.TrainLoss, color: "#1f77b4"}, {get: e => e.ValLoss, color: "#ff7f0e"}]);
+	draw("grad", [{get: e => e.GradNorms[0], color: "#2ca02c"}, {get: e => e.GradNorms[1], color: "#d62728"}]);
+};
+</script>
+</body>
+</html>
+`
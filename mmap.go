@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Loads a .mpnn model file by memory mapping it, with the weight matrices pointing straight at the mapped pages
+// instead of being copied. The OS shares those pages between every process that maps the same file, so a pool of
+// server workers only pays for one copy of a large model.
+//
+// The mapping is read only: the network can predict but must not be trained (writing to the weights will crash).
+// Close the returned io.Closer once the network is no longer used.
+func mmapMPNN(path string) (MPNN, io.Closer, error) {
+	data, closer, err := mapFile(path)
+	if err != nil {
+		return MPNN{}, nil, err
+	}
+	network, err := mappedNetwork(data)
+	if err != nil {
+		closer.Close()
+		return MPNN{}, nil, err
+	}
+	return network, closer, nil
+}
+
+func mappedNetwork(data []byte) (MPNN, error) {
+	if sniffCompression(bufio.NewReader(bytes.NewReader(data))) != noCompression {
+		return MPNN{}, errors.New("model file: compressed models can't be memory mapped, save it uncompressed")
+	}
+	h, err := readModelHeader(bytes.NewReader(data))
+	if err != nil {
+		return MPNN{}, err
+	}
+
+	network, err := networkFromParts(h.metadata, func(name string) (*mat.Dense, error) {
+		for _, t := range h.tensors {
+			if t.name == name {
+				return tensorView(data, h.dataOffset+int64(t.offset), t)
+			}
+		}
+		return nil, fmt.Errorf("no tensor named %q", name)
+	})
+	if err != nil {
+		return network, fmt.Errorf("model file: %w", err)
+	}
+	return network, nil
+}
+
+// Reinterprets a tensor's bytes as []float64 in place. That only works if the floats are already in this
+// machine's byte order and 8 byte aligned in memory (mappings start on a page boundary and the file aligns tensor
+// data, so the latter holds for mapped files). Otherwise the tensor is decoded into a copy.
+func tensorView(data []byte, start int64, t tensorInfo) (*mat.Dense, error) {
+	rows, cols, err := t.shape()
+	if err != nil {
+		return nil, err
+	}
+	size := 8 * int64(rows) * int64(cols)
+	if start < 0 || start > int64(len(data))-size {
+		return nil, fmt.Errorf("tensor %q runs past the end of the file", t.name)
+	}
+
+	raw := data[start : start+size]
+	if !nativeLittleEndian() || uintptr(unsafe.Pointer(&raw[0]))%8 != 0 {
+		fr := &fileReader{r: bytes.NewReader(raw)}
+		return fr.tensor(t)
+	}
+	floats := unsafe.Slice((*float64)(unsafe.Pointer(&raw[0])), rows*cols)
+	return mat.NewDense(rows, cols, floats), nil
+}
+
+func nativeLittleEndian() bool {
+	x := uint16(1)
+	b := (*[2]byte)(unsafe.Pointer(&x))
+	return binary.LittleEndian.Uint16(b[:]) == 1
+}
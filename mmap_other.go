@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// No mmap here, so the whole file is read into memory instead. The network still loads the same way, it just
+// isn't shared between processes.
+func mapFile(path string) ([]byte, io.Closer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, io.NopCloser(nil), nil
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The .mpnn model file is a single binary file laid out a lot like GGUF:
+//
+//	magic "MPNN" | version u32 | alignment u32 | metadata count u64 | tensor count u64
+//	metadata:    key string | type u32 | value length u64 | value
+//	tensor info: name string | dims count u32 | dims u64... | type u32 | offset u64
+//	padding up to the alignment, then each tensor's data, each starting on an aligned offset
+//
+// Everything is little endian and strings are a u64 length followed by the bytes. Since every metadata value
+// carries its own length, readers can skip keys (or even value types) they don't know about, and since the tensor
+// info lists where each tensor's data starts, a single tensor can be read without touching the rest of the file.
+
+const (
+	modelMagic     = "MPNN"
+	modelVersion   = 1
+	modelAlignment = 32
+
+	metaUint   = 0
+	metaFloat  = 1
+	metaString = 2
+
+	tensorFloat64 = 0
+
+	// Anything longer than these is a corrupt file rather than a real key/tensor name or metadata value.
+	maxModelString = 1 << 16
+	maxMetaString  = 1 << 26
+
+	// How many floats are encoded or decoded at a time while streaming tensor data.
+	tensorChunk = 4096
+)
+
+type namedTensor struct {
+	name string
+	m    *mat.Dense
+}
+
+type tensorInfo struct {
+	name   string
+	dims   []uint64
+	dtype  uint32
+	offset uint64 // From the start of the data section
+}
+
+type modelHeader struct {
+	version    uint32
+	alignment  uint32
+	metadata   map[string]any // uint64, float64 or string
+	tensors    []tensorInfo
+	dataOffset int64
+}
+
+// Everything about the network that isn't a weight matrix.
+func (net *MPNN) metadata() map[string]any {
+	meta := map[string]any{
+		"general.architecture":   "mpnn",
+		"mpnn.in":                uint64(net.in),
+		"mpnn.hidden":            uint64(net.hidden),
+		"mpnn.out":               uint64(net.out),
+		"mpnn.learn_rate":        net.learnRate,
+		"mpnn.dropout":           net.dropout,
+		"mpnn.activation.hidden": net.hidAct.name(),
+		"mpnn.activation.output": net.outAct.name(),
+	}
+	if net.schema != nil {
+		meta["mpnn.schema"] = net.schema.encode()
+	}
+	if net.tied {
+		meta["mpnn.tied"] = uint64(1)
+	}
+	if heads, ok := net.outAct.(Heads); ok {
+		// Stand in for both the output activation and the loss.
+		meta["mpnn.heads"] = heads.encode()
+	} else if net.loss != nil {
+		meta["mpnn.loss"] = net.loss.name()
+	}
+	return meta
+}
+
+func (net *MPNN) tensors() []namedTensor {
+	tensors := []namedTensor{{"hidden.weight", net.hidWeights}}
+	if !net.tied {
+		tensors = append(tensors, namedTensor{"output.weight", net.outWeights})
+	}
+	// Learned activation parameters are stored as a column, one row per neuron.
+	if p, ok := net.hidAct.(paramActivation); ok {
+		tensors = append(tensors, namedTensor{"hidden.activation", mat.NewDense(len(p.params()), 1, p.params())})
+	}
+	if p, ok := net.outAct.(paramActivation); ok {
+		tensors = append(tensors, namedTensor{"output.activation", mat.NewDense(len(p.params()), 1, p.params())})
+	}
+	return tensors
+}
+
+// Saves the network to a .mpnn model file, compressed if the path ends in .gz or .zst.
+func (net *MPNN) save(path string) error {
+	return saveModel(path, net.metadata(), net.tensors())
+}
+
+// Loads a network from a .mpnn model file, compressed or not.
+func loadMPNN(path string) (MPNN, error) {
+	network, _, err := loadModel(path)
+	return network, err
+}
+
+// Streams the network out in the .mpnn format, so it can go straight to a socket or pipe without the whole
+// file being built up in memory first.
+func (net *MPNN) writeTo(w io.Writer) error {
+	return writeModel(w, net.metadata(), net.tensors())
+}
+
+// Reads a network in the .mpnn format from a stream, decompressing it if needed. Unlike readModelHeader this
+// never seeks, the file is read front to back once.
+func readMPNN(r io.Reader) (MPNN, error) {
+	network, _, err := readModel(r)
+	return network, err
+}
+
+// Does the work for save(), for callers that store extra metadata alongside the network. The file is written
+// atomically, see DirStorage.Put().
+func saveModel(path string, meta map[string]any, tensors []namedTensor) error {
+	return saveModelTo(DirStorage(filepath.Dir(path)), filepath.Base(path), meta, tensors)
+}
+
+// Does the work for loadMPNN(), also handing back all the metadata.
+func loadModel(path string) (MPNN, map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return MPNN{}, nil, err
+	}
+	defer f.Close()
+	return readModel(f)
+}
+
+func readModel(r io.Reader) (MPNN, map[string]any, error) {
+	dr, err := decompressReader(bufio.NewReader(r))
+	if err != nil {
+		return MPNN{}, nil, fmt.Errorf("model file: %w", err)
+	}
+	defer dr.Close()
+
+	fr := &fileReader{r: dr}
+	h, err := parseModelHeader(fr)
+	if err != nil {
+		return MPNN{}, nil, err
+	}
+
+	// Tensor data has to be read in the order it sits in the file.
+	infos := append([]tensorInfo(nil), h.tensors...)
+	sort.Slice(infos, func(i, j int) bool { return infos[i].offset < infos[j].offset })
+
+	tensors := make(map[string]*mat.Dense)
+	for _, t := range infos {
+		start := h.dataOffset + int64(t.offset)
+		if start < fr.n {
+			return MPNN{}, nil, fmt.Errorf("model file: tensor %q overlaps the one before it", t.name)
+		}
+		fr.skip(uint64(start - fr.n))
+		m, err := fr.tensor(t)
+		if err != nil {
+			return MPNN{}, nil, fmt.Errorf("model file: %w", err)
+		}
+		tensors[t.name] = m
+	}
+
+	network, err := networkFromParts(h.metadata, lookupTensor(tensors))
+	if err != nil {
+		return network, nil, fmt.Errorf("model file: %w", err)
+	}
+	return network, h.metadata, nil
+}
+
+func writeModel(w io.Writer, meta map[string]any, tensors []namedTensor) error {
+	fw := &fileWriter{w: bufio.NewWriter(w)}
+	fw.write([]byte(modelMagic))
+	fw.u32(modelVersion)
+	fw.u32(modelAlignment)
+	fw.u64(uint64(len(meta)))
+	fw.u64(uint64(len(tensors)))
+
+	// Map order is random, so sort the keys to keep files byte-for-byte reproducible.
+	for _, k := range sortedKeys(meta) {
+		fw.string(k)
+		switch v := meta[k].(type) {
+		case uint64:
+			fw.u32(metaUint)
+			fw.u64(8)
+			fw.u64(v)
+		case float64:
+			fw.u32(metaFloat)
+			fw.u64(8)
+			fw.u64(math.Float64bits(v))
+		case string:
+			fw.u32(metaString)
+			fw.u64(uint64(len(v)))
+			fw.write([]byte(v))
+		default:
+			return fmt.Errorf("model file: unsupported metadata type %T for %q", v, k)
+		}
+	}
+
+	var offset uint64
+	for _, t := range tensors {
+		r, c := t.m.Dims()
+		fw.string(t.name)
+		fw.u32(2)
+		fw.u64(uint64(r))
+		fw.u64(uint64(c))
+		fw.u32(tensorFloat64)
+		fw.u64(offset)
+		offset = alignUp(offset+uint64(8*r*c), modelAlignment)
+	}
+	fw.pad(modelAlignment)
+
+	// Tensors go out a chunk at a time, so only one chunk's worth of bytes is ever held on top of the weights.
+	chunk := make([]byte, 0, 8*tensorChunk)
+	for _, t := range tensors {
+		r, c := t.m.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				chunk = binary.LittleEndian.AppendUint64(chunk, math.Float64bits(t.m.At(i, j)))
+				if len(chunk) == cap(chunk) {
+					fw.write(chunk)
+					chunk = chunk[:0]
+				}
+			}
+		}
+		fw.write(chunk)
+		chunk = chunk[:0]
+		fw.pad(modelAlignment)
+	}
+
+	if fw.err != nil {
+		return fw.err
+	}
+	return fw.w.Flush()
+}
+
+// Reads the header and tensor info of a model file, leaving the tensor data untouched.
+func readModelHeader(r io.ReaderAt) (modelHeader, error) {
+	return parseModelHeader(&fileReader{r: io.NewSectionReader(r, 0, math.MaxInt64)})
+}
+
+func parseModelHeader(fr *fileReader) (modelHeader, error) {
+	var h modelHeader
+	magic := make([]byte, len(modelMagic))
+	fr.read(magic)
+	if fr.err == nil && string(magic) != modelMagic {
+		return h, errors.New("model file: not an MPNN model file")
+	}
+	h.version = fr.u32()
+	if fr.err == nil && h.version > modelVersion {
+		return h, fmt.Errorf("model file: version %d is newer than this build understands (%d)", h.version, modelVersion)
+	}
+	h.alignment = fr.u32()
+	metaCount := fr.u64()
+	tensorCount := fr.u64()
+	if fr.err != nil {
+		return h, fmt.Errorf("model file: reading header: %w", fr.err)
+	}
+
+	h.metadata = make(map[string]any)
+	for i := uint64(0); i < metaCount && fr.err == nil; i++ {
+		key := fr.string()
+		typ := fr.u32()
+		size := fr.u64()
+		switch {
+		case typ == metaUint && size == 8:
+			h.metadata[key] = fr.u64()
+		case typ == metaFloat && size == 8:
+			h.metadata[key] = math.Float64frombits(fr.u64())
+		case typ == metaString && size <= maxMetaString:
+			s := make([]byte, size)
+			fr.read(s)
+			h.metadata[key] = string(s)
+		default:
+			// Written by a newer version, skip over it.
+			fr.skip(size)
+		}
+	}
+
+	for i := uint64(0); i < tensorCount && fr.err == nil; i++ {
+		t := tensorInfo{name: fr.string()}
+		n := fr.u32()
+		if n > 8 {
+			return h, fmt.Errorf("model file: tensor %q has %d dimensions", t.name, n)
+		}
+		for j := uint32(0); j < n; j++ {
+			t.dims = append(t.dims, fr.u64())
+		}
+		t.dtype = fr.u32()
+		t.offset = fr.u64()
+		h.tensors = append(h.tensors, t)
+	}
+	if fr.err != nil {
+		return h, fmt.Errorf("model file: reading metadata: %w", fr.err)
+	}
+
+	h.dataOffset = int64(alignUp(uint64(fr.n), uint64(h.alignment)))
+	return h, nil
+}
+
+// Reads a single tensor's data, seeking straight to it.
+func (h modelHeader) readTensor(r io.ReaderAt, name string) (*mat.Dense, error) {
+	for _, t := range h.tensors {
+		if t.name == name {
+			fr := &fileReader{r: io.NewSectionReader(r, h.dataOffset+int64(t.offset), math.MaxInt64)}
+			return fr.tensor(t)
+		}
+	}
+	return nil, fmt.Errorf("no tensor named %q", name)
+}
+
+// Builds the network described by the header, reading its weights from r.
+func (h modelHeader) network(r io.ReaderAt) (MPNN, error) {
+	network, err := networkFromParts(h.metadata, func(name string) (*mat.Dense, error) {
+		return h.readTensor(r, name)
+	})
+	if err != nil {
+		return network, fmt.Errorf("model file: %w", err)
+	}
+	return network, nil
+}
+
+// Rebuilds a network from its metadata and weight tensors. Shared by every format that stores the network as
+// the key/value metadata and named tensors from metadata() and tensors().
+func networkFromParts(meta map[string]any, tensor func(name string) (*mat.Dense, error)) (MPNN, error) {
+	var network MPNN
+	if meta["general.architecture"] != "mpnn" {
+		return network, fmt.Errorf("architecture %v isn't mpnn", meta["general.architecture"])
+	}
+	in, ok1 := meta["mpnn.in"].(uint64)
+	hidden, ok2 := meta["mpnn.hidden"].(uint64)
+	out, ok3 := meta["mpnn.out"].(uint64)
+	learn, ok4 := meta["mpnn.learn_rate"].(float64)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return network, errors.New("missing network sizes or learn rate")
+	}
+	for _, size := range []uint64{in, hidden, out} {
+		if size < 1 || size > math.MaxInt32 {
+			return network, fmt.Errorf("bad network sizes %d, %d, %d", in, hidden, out)
+		}
+	}
+
+	network = MPNN{
+		in:        int(in),
+		hidden:    int(hidden),
+		out:       int(out),
+		learnRate: learn,
+	}
+	// Added after the first files were written, so it's allowed to be missing.
+	network.dropout, _ = meta["mpnn.dropout"].(float64)
+	var err error
+	if network.hidAct, err = metaActivation(meta, "mpnn.activation.hidden"); err != nil {
+		return network, err
+	}
+	if spec, ok := meta["mpnn.heads"].(string); ok {
+		heads, err := decodeHeads(spec)
+		if err != nil {
+			return network, err
+		}
+		if err := network.setHeads(heads); err != nil {
+			return network, err
+		}
+	} else {
+		if network.outAct, err = metaActivation(meta, "mpnn.activation.output"); err != nil {
+			return network, err
+		}
+		if name, ok := meta["mpnn.loss"].(string); ok {
+			if network.loss, err = lossByName(name); err != nil {
+				return network, err
+			}
+		}
+	}
+	if schema, ok := meta["mpnn.schema"].(string); ok {
+		s, err := decodeSchema(schema)
+		if err != nil {
+			return network, err
+		}
+		network.schema = s
+	}
+
+	if network.hidWeights, err = tensor("hidden.weight"); err != nil {
+		return network, err
+	}
+	if tied, _ := meta["mpnn.tied"].(uint64); tied == 1 {
+		network.tied = true
+		network.retie()
+	} else if network.outWeights, err = tensor("output.weight"); err != nil {
+		return network, err
+	}
+	// The number of maxout pieces isn't stored, it's however many rows of weights each neuron has.
+	if _, ok := network.hidAct.(Maxout); ok {
+		if network.hidAct, err = maxoutFromWeights(network.hidWeights, network.hidden); err != nil {
+			return network, fmt.Errorf("tensor %q: %w", "hidden.weight", err)
+		}
+	}
+	if _, ok := network.outAct.(Maxout); ok {
+		if network.outAct, err = maxoutFromWeights(network.outWeights, network.out); err != nil {
+			return network, fmt.Errorf("tensor %q: %w", "output.weight", err)
+		}
+	}
+	for _, l := range []struct {
+		name string
+		act  Activation
+	}{{"hidden.activation", network.hidAct}, {"output.activation", network.outAct}} {
+		if p, ok := l.act.(paramActivation); ok {
+			t, err := tensor(l.name)
+			if err != nil {
+				return network, err
+			}
+			if err := loadActivationParams(p, t); err != nil {
+				return network, fmt.Errorf("tensor %q: %w", l.name, err)
+			}
+		}
+	}
+	return network, network.checkShapes()
+}
+
+// A maxout layer of neurons neurons has K rows of weights for each of them.
+func maxoutFromWeights(w *mat.Dense, neurons int) (Maxout, error) {
+	rows := w.RawMatrix().Rows
+	if rows%neurons != 0 {
+		return Maxout{}, fmt.Errorf("%d rows of weights can't be split evenly between %d maxout neurons", rows, neurons)
+	}
+	return Maxout{K: rows / neurons}, nil
+}
+
+// Copies the stored parameters into the activation (the tensor may be a view of a memory mapped file).
+func loadActivationParams(p paramActivation, t *mat.Dense) error {
+	switch p := p.(type) {
+	case *PReLU:
+		p.Alpha = mat.Col(nil, 0, t)
+	case *GroupNorm:
+		p.setParams(mat.Col(nil, 0, t))
+	default:
+		return fmt.Errorf("don't know how to load %s parameters", p.name())
+	}
+	return nil
+}
+
+// Normalization layers learn a gain and a bias for every neuron, PReLU just a slope.
+func paramsPerNeuron(p paramActivation) int {
+	if _, ok := p.(*GroupNorm); ok {
+		return 2
+	}
+	return 1
+}
+
+// Files from before activations were configurable don't say, and those were all sigmoid.
+func metaActivation(meta map[string]any, key string) (Activation, error) {
+	name, ok := meta[key].(string)
+	if !ok {
+		return Sigmoid{}, nil
+	}
+	return activationByName(name)
+}
+
+func lookupTensor(tensors map[string]*mat.Dense) func(name string) (*mat.Dense, error) {
+	return func(name string) (*mat.Dense, error) {
+		t, ok := tensors[name]
+		if !ok {
+			return nil, fmt.Errorf("no tensor named %q", name)
+		}
+		return t, nil
+	}
+}
+
+// Makes sure the weight matrices actually match the layer sizes, so a bad file fails on load instead of
+// panicking inside the matrix math later.
+func (net *MPNN) checkShapes() error {
+	hidRows, outRows := net.hidden*pieces(net.hidAct), net.out*pieces(net.outAct)
+	if r, c := net.hidWeights.Dims(); r != hidRows || c != net.in || r == 0 {
+		return fmt.Errorf("hidden weights are %dx%d, expected %dx%d", r, c, hidRows, net.in)
+	}
+	if r, c := net.outWeights.Dims(); r != outRows || c != net.hidden || r == 0 {
+		return fmt.Errorf("output weights are %dx%d, expected %dx%d", r, c, outRows, net.hidden)
+	}
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		size := []int{net.hidden, net.out}[l]
+		if n, ok := act.(*GroupNorm); ok && (n.Groups < 1 || n.Groups > size) {
+			return fmt.Errorf("can't split %d neurons into %d normalization groups", size, n.Groups)
+		}
+		if p, ok := act.(paramActivation); ok && len(p.params()) != size*paramsPerNeuron(p) {
+			return fmt.Errorf("%s activation has %d parameters, expected %d", p.name(), len(p.params()), size*paramsPerNeuron(p))
+		}
+	}
+	if net.schema != nil && len(net.schema.Features) != net.in {
+		return fmt.Errorf("schema has %d features, expected %d", len(net.schema.Features), net.in)
+	}
+	return nil
+}
+
+// Small helpers for reading the file sequentially. The first error sticks, so a run of reads only needs to be
+// checked once at the end.
+type fileReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (f *fileReader) read(b []byte) {
+	if f.err != nil {
+		return
+	}
+	var n int
+	n, f.err = io.ReadFull(f.r, b)
+	f.n += int64(n)
+}
+
+func (f *fileReader) skip(size uint64) {
+	if f.err != nil {
+		return
+	}
+	var n int64
+	n, f.err = io.CopyN(io.Discard, f.r, int64(size))
+	f.n += n
+}
+
+func (f *fileReader) u32() uint32 {
+	var b [4]byte
+	f.read(b[:])
+	return binary.LittleEndian.Uint32(b[:])
+}
+
+func (f *fileReader) u64() uint64 {
+	var b [8]byte
+	f.read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+func (f *fileReader) string() string {
+	size := f.u64()
+	if f.err == nil && size > maxModelString {
+		f.err = fmt.Errorf("string of %d bytes is too long", size)
+	}
+	if f.err != nil {
+		return ""
+	}
+	b := make([]byte, size)
+	f.read(b)
+	return string(b)
+}
+
+// Reads a tensor's data a chunk at a time, decoding straight into the matrix's backing slice.
+func (f *fileReader) tensor(t tensorInfo) (*mat.Dense, error) {
+	rows, cols, err := t.shape()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]float64, rows*cols)
+	chunk := make([]byte, 8*tensorChunk)
+	for done := 0; done < len(data); {
+		n := len(data) - done
+		if n > tensorChunk {
+			n = tensorChunk
+		}
+		f.read(chunk[:8*n])
+		if f.err != nil {
+			return nil, fmt.Errorf("reading tensor %q: %w", t.name, f.err)
+		}
+		for i := 0; i < n; i++ {
+			data[done+i] = math.Float64frombits(binary.LittleEndian.Uint64(chunk[8*i:]))
+		}
+		done += n
+	}
+	return mat.NewDense(rows, cols, data), nil
+}
+
+// The tensor's rows and columns, if it's a float64 matrix of a sane size. The dims come straight from the file,
+// so each one is bounded before they're multiplied, or a crafted pair could wrap around to a small product.
+func (t tensorInfo) shape() (rows, cols int, err error) {
+	if t.dtype != tensorFloat64 || len(t.dims) != 2 {
+		return 0, 0, fmt.Errorf("tensor %q isn't a float64 matrix", t.name)
+	}
+	r, c := t.dims[0], t.dims[1]
+	if r < 1 || c < 1 || r > math.MaxInt32 || c > math.MaxInt32 || r*c > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("tensor %q has a bad shape %v", t.name, t.dims)
+	}
+	return int(r), int(c), nil
+}
+
+// The writing side of fileReader, keeping track of how much has been written so padding can be added.
+type fileWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (f *fileWriter) write(b []byte) {
+	if f.err != nil {
+		return
+	}
+	var n int
+	n, f.err = f.w.Write(b)
+	f.n += int64(n)
+}
+
+func (f *fileWriter) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	f.write(b[:])
+}
+
+func (f *fileWriter) u64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	f.write(b[:])
+}
+
+func (f *fileWriter) string(s string) {
+	f.u64(uint64(len(s)))
+	f.write([]byte(s))
+}
+
+func (f *fileWriter) pad(align int) {
+	if rem := f.n % int64(align); rem != 0 {
+		f.write(make([]byte, int64(align)-rem))
+	}
+}
+
+func alignUp(n, align uint64) uint64 {
+	if align == 0 {
+		return n
+	}
+	return (n + align - 1) / align * align
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
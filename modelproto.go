@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gonum.org/v1/gonum/mat"
+	"google.golang.org/protobuf/proto"
+
+	"Users/392wa/MPNN/mpnnpb"
+)
+
+// The protobuf types in mpnnpb are generated from mpnn.proto, so rerun this after changing it (needs protoc and
+// protoc-gen-go on the PATH).
+//go:generate protoc --go_out=. --go_opt=module=Users/392wa/MPNN mpnn.proto
+
+// Saves the network as an mpnn.Model protocol buffer.
+func (net *MPNN) saveProto(path string) error {
+	b, err := marshalModelProto(net.metadata(), net.tensors())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Loads a network saved as an mpnn.Model protocol buffer.
+func loadProtoMPNN(path string) (MPNN, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return MPNN{}, err
+	}
+	meta, tensors, err := unmarshalModelProto(b)
+	if err != nil {
+		return MPNN{}, err
+	}
+	network, err := networkFromParts(meta, lookupTensor(tensors))
+	if err != nil {
+		return network, fmt.Errorf("protobuf model: %w", err)
+	}
+	return network, nil
+}
+
+func marshalModelProto(meta map[string]any, tensors []namedTensor) ([]byte, error) {
+	model := &mpnnpb.Model{Metadata: make(map[string]*mpnnpb.Value, len(meta))}
+	for k, v := range meta {
+		switch v := v.(type) {
+		case uint64:
+			model.Metadata[k] = &mpnnpb.Value{Kind: &mpnnpb.Value_UintValue{UintValue: v}}
+		case float64:
+			model.Metadata[k] = &mpnnpb.Value{Kind: &mpnnpb.Value_FloatValue{FloatValue: v}}
+		case string:
+			model.Metadata[k] = &mpnnpb.Value{Kind: &mpnnpb.Value_StringValue{StringValue: v}}
+		default:
+			return nil, fmt.Errorf("protobuf model: unsupported metadata type %T for %q", v, k)
+		}
+	}
+
+	for _, t := range tensors {
+		r, c := t.m.Dims()
+		data := make([]float64, 0, r*c)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				data = append(data, t.m.At(i, j))
+			}
+		}
+		model.Tensors = append(model.Tensors, &mpnnpb.Tensor{Name: t.name, Dims: []uint64{uint64(r), uint64(c)}, Data: data})
+	}
+
+	// Deterministic sorts the metadata map, so the same network always makes the same file.
+	return proto.MarshalOptions{Deterministic: true}.Marshal(model)
+}
+
+func unmarshalModelProto(b []byte) (map[string]any, map[string]*mat.Dense, error) {
+	var model mpnnpb.Model
+	if err := proto.Unmarshal(b, &model); err != nil {
+		return nil, nil, fmt.Errorf("protobuf model: %w", err)
+	}
+
+	// A value of a kind we don't know about (from a newer schema) is left out so it can be ignored.
+	meta := make(map[string]any)
+	for k, v := range model.Metadata {
+		switch kind := v.GetKind().(type) {
+		case *mpnnpb.Value_UintValue:
+			meta[k] = kind.UintValue
+		case *mpnnpb.Value_FloatValue:
+			meta[k] = kind.FloatValue
+		case *mpnnpb.Value_StringValue:
+			meta[k] = kind.StringValue
+		}
+	}
+
+	tensors := make(map[string]*mat.Dense)
+	for _, t := range model.Tensors {
+		dims, data := t.Dims, t.Data
+		if len(dims) != 2 {
+			return nil, nil, fmt.Errorf("protobuf model: tensor %q isn't a matrix", t.Name)
+		}
+		// Each dimension is checked on its own first, so a huge pair can't multiply out to the right size.
+		n := uint64(len(data))
+		if n == 0 || dims[0] > n || dims[1] > n || dims[0]*dims[1] != n {
+			return nil, nil, fmt.Errorf("protobuf model: tensor %q data doesn't match its dims", t.Name)
+		}
+		tensors[t.Name] = mat.NewDense(int(dims[0]), int(dims[1]), data)
+	}
+	return meta, tensors, nil
+}
@@ -0,0 +1,495 @@
+// gRPC service for distributed data-parallel training, so workers can be written in other languages too:
+//
+//   protoc --python_out=. --grpc_python_out=. coordinator.proto
+//
+// The Go code in mpnnpb is generated from it too (see the go:generate line in distributed.go). Calls have to carry
+// "authorization: Bearer <token>" when the coordinator is started with a token.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.33.0
+// 	protoc        (unknown)
+// source: coordinator.proto
+
+package mpnnpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Empty struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_coordinator_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{0}
+}
+
+type JoinReply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Model    []byte `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`                        // A .mpnn model file
+	WorkerId uint64 `protobuf:"varint,2,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"` // Goes with every Push and the Leave
+}
+
+func (x *JoinReply) Reset() {
+	*x = JoinReply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_coordinator_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *JoinReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinReply) ProtoMessage() {}
+
+func (x *JoinReply) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinReply.ProtoReflect.Descriptor instead.
+func (*JoinReply) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *JoinReply) GetModel() []byte {
+	if x != nil {
+		return x.Model
+	}
+	return nil
+}
+
+func (x *JoinReply) GetWorkerId() uint64 {
+	if x != nil {
+		return x.WorkerId
+	}
+	return 0
+}
+
+// The average gradient of one batch. Matrices are row major, shaped like the weights.
+type GradientPush struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	WorkerId uint64    `protobuf:"varint,6,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+	Samples  int64     `protobuf:"varint,1,opt,name=samples,proto3" json:"samples,omitempty"`
+	HidGrad  []float64 `protobuf:"fixed64,2,rep,packed,name=hid_grad,json=hidGrad,proto3" json:"hid_grad,omitempty"`
+	OutGrad  []float64 `protobuf:"fixed64,3,rep,packed,name=out_grad,json=outGrad,proto3" json:"out_grad,omitempty"`
+	// Only for activations that learn parameters (e.g. PReLU), left out otherwise.
+	HidActGrad []float64 `protobuf:"fixed64,4,rep,packed,name=hid_act_grad,json=hidActGrad,proto3" json:"hid_act_grad,omitempty"`
+	OutActGrad []float64 `protobuf:"fixed64,5,rep,packed,name=out_act_grad,json=outActGrad,proto3" json:"out_act_grad,omitempty"`
+}
+
+func (x *GradientPush) Reset() {
+	*x = GradientPush{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_coordinator_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GradientPush) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GradientPush) ProtoMessage() {}
+
+func (x *GradientPush) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GradientPush.ProtoReflect.Descriptor instead.
+func (*GradientPush) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GradientPush) GetWorkerId() uint64 {
+	if x != nil {
+		return x.WorkerId
+	}
+	return 0
+}
+
+func (x *GradientPush) GetSamples() int64 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
+func (x *GradientPush) GetHidGrad() []float64 {
+	if x != nil {
+		return x.HidGrad
+	}
+	return nil
+}
+
+func (x *GradientPush) GetOutGrad() []float64 {
+	if x != nil {
+		return x.OutGrad
+	}
+	return nil
+}
+
+func (x *GradientPush) GetHidActGrad() []float64 {
+	if x != nil {
+		return x.HidActGrad
+	}
+	return nil
+}
+
+func (x *GradientPush) GetOutActGrad() []float64 {
+	if x != nil {
+		return x.OutActGrad
+	}
+	return nil
+}
+
+type LeaveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	WorkerId uint64 `protobuf:"varint,1,opt,name=worker_id,json=workerId,proto3" json:"worker_id,omitempty"`
+}
+
+func (x *LeaveRequest) Reset() {
+	*x = LeaveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_coordinator_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LeaveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeaveRequest) ProtoMessage() {}
+
+func (x *LeaveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeaveRequest.ProtoReflect.Descriptor instead.
+func (*LeaveRequest) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LeaveRequest) GetWorkerId() uint64 {
+	if x != nil {
+		return x.WorkerId
+	}
+	return 0
+}
+
+type WeightUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hid          []float64 `protobuf:"fixed64,1,rep,packed,name=hid,proto3" json:"hid,omitempty"`
+	Out          []float64 `protobuf:"fixed64,2,rep,packed,name=out,proto3" json:"out,omitempty"`
+	HidActParams []float64 `protobuf:"fixed64,3,rep,packed,name=hid_act_params,json=hidActParams,proto3" json:"hid_act_params,omitempty"`
+	OutActParams []float64 `protobuf:"fixed64,4,rep,packed,name=out_act_params,json=outActParams,proto3" json:"out_act_params,omitempty"`
+}
+
+func (x *WeightUpdate) Reset() {
+	*x = WeightUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_coordinator_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WeightUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeightUpdate) ProtoMessage() {}
+
+func (x *WeightUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_coordinator_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeightUpdate.ProtoReflect.Descriptor instead.
+func (*WeightUpdate) Descriptor() ([]byte, []int) {
+	return file_coordinator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *WeightUpdate) GetHid() []float64 {
+	if x != nil {
+		return x.Hid
+	}
+	return nil
+}
+
+func (x *WeightUpdate) GetOut() []float64 {
+	if x != nil {
+		return x.Out
+	}
+	return nil
+}
+
+func (x *WeightUpdate) GetHidActParams() []float64 {
+	if x != nil {
+		return x.HidActParams
+	}
+	return nil
+}
+
+func (x *WeightUpdate) GetOutActParams() []float64 {
+	if x != nil {
+		return x.OutActParams
+	}
+	return nil
+}
+
+var File_coordinator_proto protoreflect.FileDescriptor
+
+var file_coordinator_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x04, 0x6d, 0x70, 0x6e, 0x6e, 0x22, 0x07, 0x0a, 0x05, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x22, 0x3e, 0x0a, 0x09, 0x4a, 0x6f, 0x69, 0x6e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05,
+	0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x1b, 0x0a, 0x09, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x5f,
+	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72,
+	0x49, 0x64, 0x22, 0xbf, 0x01, 0x0a, 0x0c, 0x47, 0x72, 0x61, 0x64, 0x69, 0x65, 0x6e, 0x74, 0x50,
+	0x75, 0x73, 0x68, 0x12, 0x1b, 0x0a, 0x09, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x49, 0x64,
+	0x12, 0x18, 0x0a, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x68, 0x69,
+	0x64, 0x5f, 0x67, 0x72, 0x61, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01, 0x52, 0x07, 0x68, 0x69,
+	0x64, 0x47, 0x72, 0x61, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x6f, 0x75, 0x74, 0x5f, 0x67, 0x72, 0x61,
+	0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x01, 0x52, 0x07, 0x6f, 0x75, 0x74, 0x47, 0x72, 0x61, 0x64,
+	0x12, 0x20, 0x0a, 0x0c, 0x68, 0x69, 0x64, 0x5f, 0x61, 0x63, 0x74, 0x5f, 0x67, 0x72, 0x61, 0x64,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0a, 0x68, 0x69, 0x64, 0x41, 0x63, 0x74, 0x47, 0x72,
+	0x61, 0x64, 0x12, 0x20, 0x0a, 0x0c, 0x6f, 0x75, 0x74, 0x5f, 0x61, 0x63, 0x74, 0x5f, 0x67, 0x72,
+	0x61, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0a, 0x6f, 0x75, 0x74, 0x41, 0x63, 0x74,
+	0x47, 0x72, 0x61, 0x64, 0x22, 0x2b, 0x0a, 0x0c, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x77, 0x6f, 0x72, 0x6b, 0x65, 0x72, 0x49,
+	0x64, 0x22, 0x7e, 0x0a, 0x0c, 0x57, 0x65, 0x69, 0x67, 0x68, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x68, 0x69, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x01, 0x52, 0x03,
+	0x68, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6f, 0x75, 0x74, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01,
+	0x52, 0x03, 0x6f, 0x75, 0x74, 0x12, 0x24, 0x0a, 0x0e, 0x68, 0x69, 0x64, 0x5f, 0x61, 0x63, 0x74,
+	0x5f, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x01, 0x52, 0x0c, 0x68,
+	0x69, 0x64, 0x41, 0x63, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x24, 0x0a, 0x0e, 0x6f,
+	0x75, 0x74, 0x5f, 0x61, 0x63, 0x74, 0x5f, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x01, 0x52, 0x0c, 0x6f, 0x75, 0x74, 0x41, 0x63, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d,
+	0x73, 0x32, 0x8d, 0x01, 0x0a, 0x0b, 0x43, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x6f,
+	0x72, 0x12, 0x24, 0x0a, 0x04, 0x4a, 0x6f, 0x69, 0x6e, 0x12, 0x0b, 0x2e, 0x6d, 0x70, 0x6e, 0x6e,
+	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0f, 0x2e, 0x6d, 0x70, 0x6e, 0x6e, 0x2e, 0x4a, 0x6f,
+	0x69, 0x6e, 0x52, 0x65, 0x70, 0x6c, 0x79, 0x12, 0x2e, 0x0a, 0x04, 0x50, 0x75, 0x73, 0x68, 0x12,
+	0x12, 0x2e, 0x6d, 0x70, 0x6e, 0x6e, 0x2e, 0x47, 0x72, 0x61, 0x64, 0x69, 0x65, 0x6e, 0x74, 0x50,
+	0x75, 0x73, 0x68, 0x1a, 0x12, 0x2e, 0x6d, 0x70, 0x6e, 0x6e, 0x2e, 0x57, 0x65, 0x69, 0x67, 0x68,
+	0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x28, 0x0a, 0x05, 0x4c, 0x65, 0x61, 0x76, 0x65,
+	0x12, 0x12, 0x2e, 0x6d, 0x70, 0x6e, 0x6e, 0x2e, 0x4c, 0x65, 0x61, 0x76, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x0b, 0x2e, 0x6d, 0x70, 0x6e, 0x6e, 0x2e, 0x45, 0x6d, 0x70, 0x74,
+	0x79, 0x42, 0x19, 0x5a, 0x17, 0x55, 0x73, 0x65, 0x72, 0x73, 0x2f, 0x33, 0x39, 0x32, 0x77, 0x61,
+	0x2f, 0x4d, 0x50, 0x4e, 0x4e, 0x2f, 0x6d, 0x70, 0x6e, 0x6e, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_coordinator_proto_rawDescOnce sync.Once
+	file_coordinator_proto_rawDescData = file_coordinator_proto_rawDesc
+)
+
+func file_coordinator_proto_rawDescGZIP() []byte {
+	file_coordinator_proto_rawDescOnce.Do(func() {
+		file_coordinator_proto_rawDescData = protoimpl.X.CompressGZIP(file_coordinator_proto_rawDescData)
+	})
+	return file_coordinator_proto_rawDescData
+}
+
+var file_coordinator_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_coordinator_proto_goTypes = []interface{}{
+	(*Empty)(nil),        // 0: mpnn.Empty
+	(*JoinReply)(nil),    // 1: mpnn.JoinReply
+	(*GradientPush)(nil), // 2: mpnn.GradientPush
+	(*LeaveRequest)(nil), // 3: mpnn.LeaveRequest
+	(*WeightUpdate)(nil), // 4: mpnn.WeightUpdate
+}
+var file_coordinator_proto_depIdxs = []int32{
+	0, // 0: mpnn.Coordinator.Join:input_type -> mpnn.Empty
+	2, // 1: mpnn.Coordinator.Push:input_type -> mpnn.GradientPush
+	3, // 2: mpnn.Coordinator.Leave:input_type -> mpnn.LeaveRequest
+	1, // 3: mpnn.Coordinator.Join:output_type -> mpnn.JoinReply
+	4, // 4: mpnn.Coordinator.Push:output_type -> mpnn.WeightUpdate
+	0, // 5: mpnn.Coordinator.Leave:output_type -> mpnn.Empty
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_coordinator_proto_init() }
+func file_coordinator_proto_init() {
+	if File_coordinator_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_coordinator_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Empty); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_coordinator_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*JoinReply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_coordinator_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GradientPush); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_coordinator_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LeaveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_coordinator_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WeightUpdate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_coordinator_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_coordinator_proto_goTypes,
+		DependencyIndexes: file_coordinator_proto_depIdxs,
+		MessageInfos:      file_coordinator_proto_msgTypes,
+	}.Build()
+	File_coordinator_proto = out.File
+	file_coordinator_proto_rawDesc = nil
+	file_coordinator_proto_goTypes = nil
+	file_coordinator_proto_depIdxs = nil
+}
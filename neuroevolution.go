@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+)
+
+// Trains a network without gradients, by evolution. A population of weight vectors starts out as noisy copies of
+// the network's own, and every generation the fittest few (Elite) carry over unchanged while the rest of the next
+// generation is bred from parents picked by tournament: uniform crossover of two parents, then Gaussian noise on
+// some of the weights.
+//
+// Fitness is whatever Fitness says it is, higher being better, so it doesn't have to be differentiable or even
+// smooth: accuracy, a game's score, the reward an agent collects over an episode. It's a lot less efficient than
+// backprop when there is a gradient to follow, every generation costs Population full evaluations.
+type Evolution struct {
+	Population    int
+	Elite         int     // Best members copied unchanged into the next generation
+	Tournament    int     // Members compared to pick each parent, more means stronger selection
+	CrossoverRate float64 // Chance a child has two parents rather than being a copy of one
+	MutationRate  float64 // Chance of each weight getting noise added
+	MutationScale float64 // Standard deviation of that noise
+
+	Fitness func(net *MPNN) float64
+
+	// Logs every generation's best and mean fitness, nil for defaultLogger.
+	Logger Logger
+
+	rnd *rand.Rand
+}
+
+// An Evolution with settings that work for small networks, maximizing fitness.
+func initEvolution(fitness func(net *MPNN) float64) *Evolution {
+	return &Evolution{
+		Population:    50,
+		Elite:         2,
+		Tournament:    3,
+		CrossoverRate: 0.5,
+		MutationRate:  0.1,
+		MutationScale: 0.1,
+		Fitness:       fitness,
+		rnd:           newRand(),
+	}
+}
+
+// Fitness as the network's Score() on held out samples.
+func scoreFitness(validation []Sample) func(net *MPNN) float64 {
+	return func(net *MPNN) float64 {
+		score, err := net.Score(validation)
+		if err != nil {
+			return math.Inf(-1)
+		}
+		return score
+	}
+}
+
+// Fitness from Evaluate() over a Dataset, so the metaheuristics can score on data that doesn't fit in memory and
+// on any of its metrics: metric picks the one to maximize, e.g. negated Loss or Accuracy. open is called for every
+// evaluation, since a Dataset can only be read through once, and any error counts as the worst fitness there is.
+func datasetFitness(open func() (Dataset, error), batchSize int, metric func(Evaluation) float64) func(net *MPNN) float64 {
+	return func(net *MPNN) float64 {
+		data, err := open()
+		if err != nil {
+			return math.Inf(-1)
+		}
+		e, err := Evaluate(net, data, batchSize)
+		if err != nil || e.Samples == 0 {
+			return math.Inf(-1)
+		}
+		return metric(e)
+	}
+}
+
+func (e *Evolution) log() Logger {
+	if e.Logger == nil {
+		return defaultLogger
+	}
+	return e.Logger
+}
+
+type member struct {
+	params  []float64
+	fitness float64
+}
+
+// Evolves net's parameters for the given number of generations, and leaves net with the fittest ones found.
+// Returns the best fitness of every generation.
+func (e *Evolution) Run(net *MPNN, generations int) ([]float64, error) {
+	if e.Population < 2 || e.Elite >= e.Population {
+		return nil, fmt.Errorf("need a population of at least 2 and fewer than %d elite", e.Population)
+	}
+	if e.Fitness == nil {
+		return nil, fmt.Errorf("no fitness function")
+	}
+	if e.rnd == nil {
+		e.rnd = newRand()
+	}
+
+	// Members are scored on a copy, so the network only changes once it's over.
+	scratch := net.clone()
+	evaluate := func(m *member) {
+		scratch.setParamVector(m.params)
+		m.fitness = e.Fitness(&scratch)
+		if math.IsNaN(m.fitness) {
+			m.fitness = math.Inf(-1)
+		}
+	}
+
+	start := net.paramVector()
+	pop := make([]member, e.Population)
+	for i := range pop {
+		pop[i].params = append([]float64(nil), start...)
+		if i > 0 {
+			// The network as it is stays in the running, so evolution can only improve on it.
+			for j := range pop[i].params {
+				pop[i].params[j] += e.MutationScale * e.rnd.NormFloat64()
+			}
+		}
+		evaluate(&pop[i])
+	}
+
+	var best []float64
+	for g := 0; g < generations; g++ {
+		sort.SliceStable(pop, func(i, j int) bool { return pop[i].fitness > pop[j].fitness })
+
+		next := make([]member, 0, e.Population)
+		next = append(next, pop[:e.Elite]...)
+		for len(next) < e.Population {
+			child := append([]float64(nil), e.pick(pop).params...)
+			if e.rnd.Float64() < e.CrossoverRate {
+				other := e.pick(pop).params
+				for j := range child {
+					if e.rnd.Float64() < 0.5 {
+						child[j] = other[j]
+					}
+				}
+			}
+			for j := range child {
+				if e.rnd.Float64() < e.MutationRate {
+					child[j] += e.MutationScale * e.rnd.NormFloat64()
+				}
+			}
+			m := member{params: child}
+			evaluate(&m)
+			next = append(next, m)
+		}
+		pop = next
+
+		top, mean := math.Inf(-1), 0.0
+		for _, m := range pop {
+			top = math.Max(top, m.fitness)
+			mean += m.fitness
+		}
+		mean /= float64(len(pop))
+		best = append(best, top)
+		e.log().Debug("generation", "gen", g+1, "best", top, "mean", mean)
+	}
+
+	fittest := pop[0]
+	for _, m := range pop[1:] {
+		if m.fitness > fittest.fitness {
+			fittest = m
+		}
+	}
+	net.setParamVector(fittest.params)
+	return best, nil
+}
+
+// Tournament selection: the fittest of Tournament members picked at random.
+func (e *Evolution) pick(pop []member) member {
+	best := pop[e.rnd.Intn(len(pop))]
+	for i := 1; i < e.Tournament; i++ {
+		if m := pop[e.rnd.Intn(len(pop))]; m.fitness > best.fitness {
+			best = m
+		}
+	}
+	return best
+}
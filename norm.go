@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+const normEpsilon = 1e-5 // Keeps the division safe when every weighted sum in a sample is the same
+
+// Group normalization (Wu & He 2018): before a layer's activation, its weighted sums are split into Groups
+// blocks of neighbouring neurons, and each block is shifted and scaled to zero mean and unit variance on its own.
+// The result is then multiplied by a learned Gain and shifted by a learned Bias, one per neuron. The statistics
+// come from each sample alone, never the batch, so it behaves the same with a batch size of 1 (or in PartialFit())
+// as with big batches, and the same in training as in prediction.
+//
+// With one group this is layer normalization (Ba et al. 2016), see initLayerNorm(). For a layer holding
+// convolutional feature maps stored channel by channel, each group is a few whole channels, and one group per
+// channel is instance normalization (Ulyanov et al. 2016), see initInstanceNorm().
+//
+// It wraps the layer's activation, so it's set with e.g. net.setActivations(initLayerNorm(hidden, ReLU{}), ...).
+// Gain and Bias are trained along with the weights. Wrap a plain activation: the parameters of a PReLU wouldn't
+// be trained, and maxout's extra weighted sums aren't supported.
+type GroupNorm struct {
+	Groups     int
+	Act        Activation
+	Gain, Bias []float64 // One per neuron, both halves of values
+
+	values []float64
+}
+
+// Group normalization for a layer of size neurons, starting out as a plain normalization (gain 1, bias 0).
+func initGroupNorm(size, groups int, act Activation) *GroupNorm {
+	n := &GroupNorm{Groups: groups, Act: act}
+	n.setParams(make([]float64, 2*size))
+	for i := range n.Gain {
+		n.Gain[i] = 1
+	}
+	return n
+}
+
+// Normalizes over the whole layer.
+func initLayerNorm(size int, act Activation) *GroupNorm {
+	return initGroupNorm(size, 1, act)
+}
+
+// Normalizes each channel of a layer holding size/channels values per channel.
+func initInstanceNorm(size, channels int, act Activation) *GroupNorm {
+	return initGroupNorm(size, channels, act)
+}
+
+func (n *GroupNorm) setParams(p []float64) {
+	size := len(p) / 2
+	n.values, n.Gain, n.Bias = p, p[:size], p[size:]
+}
+
+// Stored as "layernorm+" or "groupnorm<groups>+", followed by the wrapped activation's name.
+func (n *GroupNorm) name() string {
+	if n.Groups == 1 {
+		return "layernorm+" + n.Act.name()
+	}
+	return "groupnorm" + strconv.Itoa(n.Groups) + "+" + n.Act.name()
+}
+
+func (n *GroupNorm) params() []float64 { return n.values }
+
+// The rows of z in group g. Groups that don't divide the layer evenly just come out a neuron bigger or smaller.
+func (n *GroupNorm) group(g, rows int) (start, end int) {
+	return g * rows / n.Groups, (g + 1) * rows / n.Groups
+}
+
+// Each group of each column (sample) of z normalized on its own, along with the standard deviations they were
+// divided by (per row, so backprop doesn't have to work out groups again).
+func (n *GroupNorm) normalize(z mat.Matrix) (xhat, std *mat.Dense) {
+	r, c := z.Dims()
+	xhat = mat.NewDense(r, c, nil)
+	std = mat.NewDense(r, c, nil)
+	for j := 0; j < c; j++ {
+		for g := 0; g < n.Groups; g++ {
+			start, end := n.group(g, r)
+			size := float64(end - start)
+			mean := 0.0
+			for i := start; i < end; i++ {
+				mean += z.At(i, j) / size
+			}
+			v := 0.0
+			for i := start; i < end; i++ {
+				d := z.At(i, j) - mean
+				v += d * d / size
+			}
+			sd := math.Sqrt(v + normEpsilon)
+			for i := start; i < end; i++ {
+				xhat.Set(i, j, (z.At(i, j)-mean)/sd)
+				std.Set(i, j, sd)
+			}
+		}
+	}
+	return xhat, std
+}
+
+// What goes into the wrapped activation, Gain·x̂ + Bias.
+func (n *GroupNorm) affine(xhat mat.Matrix) mat.Matrix {
+	return apply(func(i, _ int, v float64) float64 { return n.Gain[i]*v + n.Bias[i] }, xhat)
+}
+
+func (n *GroupNorm) activate(z mat.Matrix) mat.Matrix {
+	xhat, _ := n.normalize(z)
+	return n.Act.activate(n.affine(xhat))
+}
+
+// The gradient with respect to the wrapped activation's input, Gain·x̂ + Bias.
+func (n *GroupNorm) affineGrad(xhat mat.Matrix, grad mat.Matrix) mat.Matrix {
+	y := n.affine(xhat)
+	return n.Act.backprop(y, n.Act.activate(y), grad)
+}
+
+// Every weighted sum in a group went into its mean and variance, so each one's gradient also depends on the rest
+// of the group's: dz = (dx̂ - mean(dx̂) - x̂·mean(dx̂·x̂)) / std.
+func (n *GroupNorm) backprop(z, a, grad mat.Matrix) mat.Matrix {
+	xhat, std := n.normalize(z)
+	dy := n.affineGrad(xhat, grad)
+	r, c := z.Dims()
+	out := mat.NewDense(r, c, nil)
+	for j := 0; j < c; j++ {
+		for g := 0; g < n.Groups; g++ {
+			start, end := n.group(g, r)
+			size := float64(end - start)
+			meanD, meanDX := 0.0, 0.0
+			for i := start; i < end; i++ {
+				d := dy.At(i, j) * n.Gain[i]
+				meanD += d / size
+				meanDX += d * xhat.At(i, j) / size
+			}
+			for i := start; i < end; i++ {
+				d := dy.At(i, j) * n.Gain[i]
+				out.Set(i, j, (d-meanD-xhat.At(i, j)*meanDX)/std.At(i, j))
+			}
+		}
+	}
+	return out
+}
+
+// Gradients of Gain followed by Bias, laid out like params().
+func (n *GroupNorm) paramGrad(z, grad mat.Matrix) []float64 {
+	xhat, _ := n.normalize(z)
+	dy := n.affineGrad(xhat, grad)
+	size := len(n.Gain)
+	g := make([]float64, 2*size)
+	r, c := z.Dims()
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			g[i] += dy.At(i, j) * xhat.At(i, j)
+			g[size+i] += dy.At(i, j)
+		}
+	}
+	return g
+}
+
+// Handles the "layernorm+" and "groupnorm<groups>+" names for activationByName().
+func wrappedActivation(name string) (Activation, bool, error) {
+	groups := 1
+	switch {
+	case strings.HasPrefix(name, "layernorm+"):
+		name = strings.TrimPrefix(name, "layernorm+")
+	case strings.HasPrefix(name, "groupnorm"):
+		spec, inner, ok := strings.Cut(strings.TrimPrefix(name, "groupnorm"), "+")
+		g, err := strconv.Atoi(spec)
+		if !ok || err != nil || g < 1 {
+			return nil, true, fmt.Errorf("bad group normalization %q", name)
+		}
+		groups, name = g, inner
+	default:
+		return nil, false, nil
+	}
+	act, err := activationByName(name)
+	if err != nil {
+		return nil, true, err
+	}
+	return &GroupNorm{Groups: groups, Act: act}, true, nil
+}
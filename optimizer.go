@@ -0,0 +1,11 @@
+package main
+
+import "gonum.org/v1/gonum/mat"
+
+// Turns a batch's average gradient into a change of the network's weights, for anything smarter than stepping
+// straight down the gradient. Set on Trainer.Optimizer, nil is plain gradient descent.
+type Optimizer interface {
+	// Updates t's network, given the average gradients of batch. batch is nil when only the gradients are known,
+	// like on a Coordinator, and an optimizer that needs the samples has to make do without them.
+	step(t *Trainer, batch []Sample, hidGrad, outGrad *mat.Dense, actGrad activationGrads)
+}
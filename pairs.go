@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Two inputs, and whether they're the same kind of thing (two photos of the same face, two questions asking the
+// same...). Training on pairs teaches the network an embedding: outputs that are close together for similar
+// inputs and far apart for different ones, so similarity can be judged for inputs it never saw in training.
+type Pair struct {
+	A, B    []float64
+	Similar bool
+}
+
+// A cost on the network's outputs (embeddings) for the two inputs of a Pair.
+type PairLoss interface {
+	loss(a, b []float64, similar bool) float64
+
+	// The gradients of loss() with respect to a and b.
+	grad(a, b []float64, similar bool) (ga, gb []float64)
+}
+
+type (
+	// Hadsell, Chopra & LeCun 2006: half the squared distance between similar pairs' embeddings, and for different
+	// pairs half the square of however much closer than Margin they are. Different pairs already Margin apart
+	// cost nothing, so the network isn't pushed to spread everything out forever.
+	ContrastiveLoss struct{ Margin float64 }
+
+	// Compares directions rather than positions: 1 - cos θ for similar pairs, and for different ones how far
+	// cos θ is above Margin (0 asks for them to be at least at right angles). The embeddings' lengths don't
+	// matter, which suits outputs that get normalized before comparing anyway.
+	CosineEmbeddingLoss struct{ Margin float64 }
+)
+
+func (c ContrastiveLoss) loss(a, b []float64, similar bool) float64 {
+	d := floats.Distance(a, b, 2)
+	if similar {
+		return d * d / 2
+	}
+	if d >= c.Margin {
+		return 0
+	}
+	return (c.Margin - d) * (c.Margin - d) / 2
+}
+
+func (c ContrastiveLoss) grad(a, b []float64, similar bool) (ga, gb []float64) {
+	ga = make([]float64, len(a))
+	floats.SubTo(ga, a, b)
+	if !similar {
+		d := floats.Norm(ga, 2)
+		if d >= c.Margin || d == 0 {
+			// Pairs sitting exactly on top of each other have no direction to be pushed apart in.
+			floats.Scale(0, ga)
+		} else {
+			floats.Scale(-(c.Margin-d)/d, ga)
+		}
+	}
+	gb = make([]float64, len(a))
+	floats.ScaleTo(gb, -1, ga)
+	return ga, gb
+}
+
+func (c CosineEmbeddingLoss) loss(a, b []float64, similar bool) float64 {
+	cos := cosine(a, b)
+	if similar {
+		return 1 - cos
+	}
+	return math.Max(0, cos-c.Margin)
+}
+
+// ∂cos/∂a = b/(|a||b|) - cos·a/|a|², and the same the other way round.
+func (c CosineEmbeddingLoss) grad(a, b []float64, similar bool) (ga, gb []float64) {
+	ga, gb = make([]float64, len(a)), make([]float64, len(b))
+	na, nb := floats.Norm(a, 2), floats.Norm(b, 2)
+	if na == 0 || nb == 0 {
+		return ga, gb
+	}
+	cos := floats.Dot(a, b) / (na * nb)
+	sign := 1.0 // The loss goes down as cos goes up for similar pairs, and the other way for different ones
+	if similar {
+		sign = -1
+	} else if cos <= c.Margin {
+		return ga, gb
+	}
+	for i := range a {
+		ga[i] = sign * (b[i]/(na*nb) - cos*a[i]/(na*na))
+		gb[i] = sign * (a[i]/(na*nb) - cos*b[i]/(nb*nb))
+	}
+	return ga, gb
+}
+
+func cosine(a, b []float64) float64 {
+	na, nb := floats.Norm(a, 2), floats.Norm(b, 2)
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return floats.Dot(a, b) / (na * nb)
+}
+
+// Backpropagates a gradient of the cost with respect to each forward pass's output, and averages the resulting
+// weight gradients over n. Several passes can be of the same sample, or of samples whose costs depend on each
+// other: a pass's weight gradient only depends on its own output's gradient, so adding them up is the chain rule
+// for the cost as a whole.
+func (net *MPNN) outputGradients(caches []forwardCache, outGrads [][]float64, n float64) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	hidGrad, outGrad = zeros(net.hidWeights), zeros(net.outWeights)
+	for i, c := range caches {
+		h, o, a := net.backward(c, mat.NewDense(len(outGrads[i]), 1, outGrads[i]))
+		hidGrad.Add(hidGrad, h)
+		outGrad.Add(outGrad, o)
+		actGrad.add(a)
+	}
+	hidGrad.Scale(1/n, hidGrad)
+	outGrad.Scale(1/n, outGrad)
+	actGrad.scale(1 / n)
+	return hidGrad, outGrad, actGrad
+}
+
+// Trains the network as an embedding on pairs, BatchSize pairs per step, with both inputs of a pair going
+// through the same weights (see FitSiamese()). A step follows the gradient of the batch's average pair loss. The
+// network's own Loss isn't involved, and neither are Optimizers that look at the samples (K-FAC, iRprop+ fall
+// back to what they do without them). Each epoch's average pair loss is logged and added to History as its
+// TrainLoss.
+func (t *Trainer) FitPairs(pairs []Pair, loss PairLoss, epochs int) error {
+	twins := make([]SiamesePair, len(pairs))
+	for i, p := range pairs {
+		twins[i] = SiamesePair{A: Sample{Input: p.A}, B: Sample{Input: p.B}, Similar: p.Similar}
+	}
+	return t.FitSiamese(twins, Siamese{Pair: loss}, epochs)
+}
+
+// Ends an epoch of one of the embedding trainers: its stats, with loss as the TrainLoss, go into History and the
+// log.
+func (t *Trainer) recordEpoch(start time.Time, loss float64) {
+	stats := t.epoch.finish(len(t.History))
+	stats.Duration = time.Since(start)
+	t.trainTime += stats.Duration
+	stats.Elapsed = t.trainTime
+	stats.TrainLoss = loss
+	t.History = append(t.History, stats)
+	t.progress("epoch", stats.logArgs()...)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Reseeds the Trainer's random numbers (shuffling, dropout, augmentation), so the same seed, data and settings
+// always train the same weights. Combined with Workers that holds however many workers there are.
+func (t *Trainer) setSeed(seed uint64) {
+	t.rnd = rand.New(rand.NewSource(seed))
+}
+
+// The batch's average gradient, worked out by Workers goroutines when there's more than one.
+func (t *Trainer) gradients(net *MPNN, batch []Sample) (hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	hidGrad = zeros(net.hidWeights)
+	outGrad = zeros(net.outWeights)
+	t.eachGradient(net, batch, func(h, o *mat.Dense, a activationGrads) {
+		hidGrad.Add(hidGrad, h)
+		outGrad.Add(outGrad, o)
+		actGrad.add(a)
+	})
+	hidGrad.Scale(1/float64(len(batch)), hidGrad)
+	outGrad.Scale(1/float64(len(batch)), outGrad)
+	actGrad.scale(1 / float64(len(batch)))
+	return hidGrad, outGrad, actGrad
+}
+
+// Hands every sample's gradient to add, in batch order, so the gradients come out bit for bit the same whatever
+// the number of workers. Floating point addition isn't associative, so the samples' gradients are always added up
+// one by one in batch order, never per worker. And each sample gets its own dropout random numbers, seeded from
+// one draw of the Trainer's generator plus its position in the batch, so it doesn't matter which worker gets to it,
+// when, or whether there are workers at all.
+func (t *Trainer) eachGradient(net *MPNN, batch []Sample, add func(h, o *mat.Dense, a activationGrads)) {
+	base := t.rnd.Uint64()
+	gradient := func(i int) (*mat.Dense, *mat.Dense, activationGrads) {
+		rnd := rand.New(rand.NewSource(base + uint64(i)))
+		return net.gradients(net.forwardSample(batch[i], rnd), batch[i].Target)
+	}
+	if t.Workers < 1 {
+		for i := range batch {
+			add(gradient(i))
+		}
+		return
+	}
+
+	type result struct {
+		hid, out *mat.Dense
+		act      activationGrads
+	}
+	results := make([]result, len(batch))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < t.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				h, o, a := gradient(i)
+				results[i] = result{h, o, a}
+			}
+		}()
+	}
+	for i := range batch {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, r := range results {
+		add(r.hid, r.out, r.act)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Trains copies of the same network with dropout on, and checks the weights come out bit for bit the same however
+// many workers work out the gradients.
+func TestWorkersTrainSameWeights(t *testing.T) {
+	data := make([]Sample, 40)
+	for i := range data {
+		x := float64(i) / float64(len(data))
+		data[i] = Sample{Input: []float64{x, 1 - x, x * x}, Target: []float64{x, 1 - x}}
+	}
+	start := initMPNN([]int{3, 8, 2}, 0.1)
+	start.dropout = 0.5
+
+	for _, dp := range []bool{false, true} {
+		train := func(workers int) MPNN {
+			net := start.clone()
+			trainer := initTrainer(&net)
+			trainer.BatchSize = 8
+			trainer.Workers = workers
+			trainer.setSeed(42)
+			if dp {
+				trainer.DP = initDPSGD(1, 1.1, 1e-5)
+			}
+			if err := trainer.Fit(data, 3); err != nil {
+				t.Fatal(err)
+			}
+			return net
+		}
+
+		want := train(0)
+		for _, workers := range []int{1, 4} {
+			got := train(workers)
+			if !mat.Equal(got.hidWeights, want.hidWeights) || !mat.Equal(got.outWeights, want.outWeights) {
+				t.Errorf("DP %v: weights trained with %d workers differ from the ones trained with none", dp, workers)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import "gonum.org/v1/gonum/mat"
+
+// Every parameter of the network in one flat vector, for optimizers that don't care about layers: the hidden
+// weights row by row, then the output weights (left out when tied, they're a copy), then the activations'
+// parameters.
+func (net *MPNN) paramVector() []float64 {
+	w := net.weightUpdate()
+	v := w.Hid
+	if !net.tied {
+		v = append(v, w.Out...)
+	}
+	return append(append(v, w.ActParams[0]...), w.ActParams[1]...)
+}
+
+// The reverse of paramVector(). v has to come from a network of the same shape.
+func (net *MPNN) setParamVector(v []float64) {
+	w := net.weightUpdate()
+	v = v[copy(w.Hid, v):]
+	if !net.tied {
+		v = v[copy(w.Out, v):]
+	}
+	for l := range w.ActParams {
+		v = v[copy(w.ActParams[l], v):]
+	}
+	net.setWeights(w)
+	if net.tied {
+		net.retie()
+	}
+}
+
+// The mean cost over data and its gradient with respect to paramVector(), the whole dataset at once and without
+// dropout, so the same parameters always give the same answer. That's what full-batch optimizers like LBFGS
+// need, where the noise of mini-batches would throw off their line searches.
+func (net *MPNN) lossGradient(data []Sample) (float64, []float64) {
+	hidGrad, outGrad := zeros(net.hidWeights), zeros(net.outWeights)
+	var actGrad activationGrads
+	loss := 0.0
+	for _, s := range data {
+		c := net.forwardSample(s, nil)
+		loss += net.lossFn().loss(mat.Col(nil, 0, c.hidLayerWeightsOut), s.Target)
+		h, o, a := net.gradients(c, s.Target)
+		hidGrad.Add(hidGrad, h)
+		outGrad.Add(outGrad, o)
+		actGrad.add(a)
+	}
+	n := float64(len(data))
+	hidGrad.Scale(1/n, hidGrad)
+	outGrad.Scale(1/n, outGrad)
+	actGrad.scale(1 / n)
+	return loss / n, net.flattenGradient(hidGrad, outGrad, actGrad)
+}
+
+// Lays gradients out like paramVector(). With tied weights the output layer's gradient is added (transposed) to
+// the hidden layer's, like step() does.
+func (net *MPNN) flattenGradient(hidGrad, outGrad *mat.Dense, actGrad activationGrads) []float64 {
+	var g []float64
+	if net.tied {
+		g = rowMajor(add(hidGrad, outGrad.T()).(*mat.Dense))
+	} else {
+		g = append(rowMajor(hidGrad), rowMajor(outGrad)...)
+	}
+	for l, act := range []Activation{net.hidAct, net.outAct} {
+		if p, ok := act.(paramActivation); ok {
+			if actGrad[l] == nil {
+				actGrad[l] = make([]float64, len(p.params()))
+			}
+			g = append(g, actGrad[l]...)
+		}
+	}
+	return g
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// One preprocessing step of a Pipeline. fit() learns whatever the step needs from the training inputs (which have
+// already been through the steps before it), and transform() then applies it to one input, returning a copy.
+type Transform interface {
+	fit(inputs [][]float64) error
+	transform(input []float64) []float64
+}
+
+// Binds the preprocessing a network was trained with to the network itself. Raw rows are encoded with a
+// CategoricalEncoder, then go through each of the Steps in order (e.g. an Imputer and then a Scaler), and only then
+// reach the network. Fit() learns all of it from the training rows, and Predict() replays exactly the same
+// preprocessing on a new row, so the caller never has to keep track of how the inputs were prepared.
+type Pipeline struct {
+	Categorical []int // Columns to one-hot encode, nil to detect them from the training rows
+	Steps       []Transform
+
+	hidden    int
+	learnRate float64
+	encoder   *CategoricalEncoder
+	net       *MPNN
+}
+
+func initPipeline(hidden int, learn float64, steps ...Transform) *Pipeline {
+	return &Pipeline{Steps: steps, hidden: hidden, learnRate: learn}
+}
+
+// Fits the encoder and every step on the training rows, then trains a fresh network on the result.
+func (p *Pipeline) Fit(rows [][]string, targets [][]float64, epochs int) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets to fit the pipeline on")
+	}
+	encoder, err := fitCategoricalEncoder(rows, p.Categorical)
+	if err != nil {
+		return err
+	}
+	data, err := encoder.samples(rows, targets)
+	if err != nil {
+		return err
+	}
+
+	// Each step is fit on what the steps before it produced, just like it will see at prediction time.
+	inputs := make([][]float64, len(data))
+	for i, s := range data {
+		inputs[i] = s.Input
+	}
+	for i, step := range p.Steps {
+		if err := step.fit(inputs); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		for j := range inputs {
+			inputs[j] = step.transform(inputs[j])
+		}
+	}
+	for i := range data {
+		data[i].Input = inputs[i]
+	}
+
+	net := initMPNN([]int{encoder.width(), p.hidden, len(targets[0])}, p.learnRate)
+	if err := initTrainer(&net).Fit(data, epochs); err != nil {
+		return err
+	}
+	p.encoder, p.net = encoder, &net
+	return nil
+}
+
+// Turns a raw row into the input the network was trained on.
+func (p *Pipeline) preprocess(row []string) ([]float64, error) {
+	if p.net == nil {
+		return nil, fmt.Errorf("pipeline hasn't been fit")
+	}
+	input, err := p.encoder.encode(row)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range p.Steps {
+		input = step.transform(input)
+	}
+	return input, nil
+}
+
+func (p *Pipeline) Predict(row []string) ([]float64, error) {
+	input, err := p.preprocess(row)
+	if err != nil {
+		return nil, err
+	}
+	return p.net.Predict(input)
+}
+
+// Saves the network to a .mpnn model file with the encoder and steps stored in its metadata.
+func (p *Pipeline) save(path string) error {
+	if p.net == nil {
+		return fmt.Errorf("pipeline hasn't been fit")
+	}
+	steps, err := encodeSteps(p.Steps)
+	if err != nil {
+		return err
+	}
+	meta := p.net.metadata()
+	meta["pipeline.encoder"] = p.encoder.encodeJSON()
+	meta["pipeline.steps"] = steps
+	return saveModel(path, meta, p.net.tensors())
+}
+
+func loadPipeline(path string) (*Pipeline, error) {
+	net, meta, err := loadModel(path)
+	if err != nil {
+		return nil, err
+	}
+	encoderJSON, ok := meta["pipeline.encoder"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't hold a pipeline", path)
+	}
+	encoder, err := decodeCategoricalEncoder(encoderJSON)
+	if err != nil {
+		return nil, err
+	}
+	stepsJSON, _ := meta["pipeline.steps"].(string)
+	steps, err := decodeSteps(stepsJSON)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{Steps: steps, hidden: net.hidden, learnRate: net.learnRate, encoder: encoder, net: &net}, nil
+}
+
+// Steps are stored as a JSON list tagged with their kind, so the right type can be rebuilt when loading.
+type savedStep struct {
+	Kind   string          `json:"kind"`
+	Params json.RawMessage `json:"params"`
+}
+
+func encodeSteps(steps []Transform) (string, error) {
+	saved := make([]savedStep, len(steps))
+	for i, step := range steps {
+		switch step.(type) {
+		case *Imputer:
+			saved[i].Kind = "impute"
+		case *Scaler:
+			saved[i].Kind = "scale"
+		default:
+			return "", fmt.Errorf("step %d: can't save a %T", i, step)
+		}
+		params, err := json.Marshal(step)
+		if err != nil {
+			return "", fmt.Errorf("step %d: %w", i, err)
+		}
+		saved[i].Params = params
+	}
+	b, err := json.Marshal(saved)
+	return string(b), err
+}
+
+func decodeSteps(data string) ([]Transform, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var saved []savedStep
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		return nil, fmt.Errorf("reading pipeline steps: %w", err)
+	}
+	steps := make([]Transform, len(saved))
+	for i, s := range saved {
+		switch s.Kind {
+		case "impute":
+			steps[i] = &Imputer{}
+		case "scale":
+			steps[i] = &Scaler{}
+		default:
+			return nil, fmt.Errorf("reading pipeline steps: unknown kind %q", s.Kind)
+		}
+		if err := json.Unmarshal(s.Params, steps[i]); err != nil {
+			return nil, fmt.Errorf("reading pipeline step %d: %w", i, err)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import "math"
+
+// Cuts the learning rate when the validation loss stops improving: once Patience epochs have gone by without
+// the loss beating its best by more than Threshold (relative), the rate is multiplied by Factor (halved if
+// Factor is 0), down to no less than MinRate. Big steps make fast progress early on, small ones let the network
+// settle into a minimum once the big ones only bounce around it, and this finds the switch-over point without
+// having to tune a decay curve. Needs Trainer.Validation to be set.
+type ReduceLROnPlateau struct {
+	Factor    float64
+	Patience  int
+	Threshold float64
+	MinRate   float64
+
+	best float64
+	wait int
+}
+
+func (r *ReduceLROnPlateau) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	if t.Validation == nil {
+		return false
+	}
+	if r.best == 0 || stats.ValLoss < r.best*(1-r.Threshold) {
+		r.best = stats.ValLoss
+		r.wait = 0
+		return false
+	}
+	r.wait++
+	if r.wait < r.Patience {
+		return false
+	}
+	r.wait = 0
+
+	factor := r.Factor
+	if factor == 0 {
+		factor = 0.5
+	}
+	rate := math.Max(t.net.learnRate*factor, r.MinRate)
+	if rate < t.net.learnRate {
+		t.progress("validation loss stopped improving, reducing learning rate",
+			"epoch", stats.Epoch, "patience", r.Patience, "from", t.net.learnRate, "to", rate)
+	}
+	t.net.learnRate = rate
+	return false
+}
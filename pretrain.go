@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// A made-up supervised problem built from unlabeled inputs, whose only point is that solving it takes hidden
+// features that are useful for the real problem too. Good for when there's plenty of data but few labels.
+type PretextTask interface {
+	// Gets the task ready for inputs of size in, and says how many outputs, with which activation, a network
+	// needs to solve it.
+	prepare(in int, rnd *rand.Rand) (outputs int, act Activation, err error)
+
+	// Turns an unlabeled input into a sample of the task.
+	sample(x []float64, rnd *rand.Rand) Sample
+}
+
+type (
+	// Denoising: a random Rate of the features (0 for 0.15) are zeroed, and the network has to give back the
+	// whole input. Filling in what's missing means learning how the features relate to each other.
+	MaskedFeatures struct{ Rate float64 }
+
+	// The input is cut into Blocks equal runs of features (0 for 3; features left over at the end stay put),
+	// which get shuffled into one of Permutations fixed orders (0 for all of them, up to 24), and the network has
+	// to tell which order it was. Only makes sense when where a feature is matters, like in a time series or a
+	// spectrum.
+	PermutationPrediction struct {
+		Blocks, Permutations int
+
+		perms [][]int
+	}
+)
+
+func (m *MaskedFeatures) prepare(in int, _ *rand.Rand) (int, Activation, error) {
+	if m.Rate == 0 {
+		m.Rate = 0.15
+	}
+	if m.Rate < 0 || m.Rate >= 1 {
+		return 0, nil, fmt.Errorf("mask rate has to be between 0 and 1, got %g", m.Rate)
+	}
+	return in, Linear{}, nil
+}
+
+func (m *MaskedFeatures) sample(x []float64, rnd *rand.Rand) Sample {
+	masked := append([]float64(nil), x...)
+	for i := range masked {
+		if rnd.Float64() < m.Rate {
+			masked[i] = 0
+		}
+	}
+	return Sample{Input: masked, Target: x}
+}
+
+func (p *PermutationPrediction) prepare(in int, rnd *rand.Rand) (int, Activation, error) {
+	if p.Blocks == 0 {
+		p.Blocks = 3
+	}
+	if p.Blocks < 2 || p.Blocks > in {
+		return 0, nil, fmt.Errorf("can't cut %d inputs into %d blocks", in, p.Blocks)
+	}
+	all := 1
+	for i := 2; i <= p.Blocks; i++ {
+		if all *= i; all > 24 {
+			all = 24
+			break
+		}
+	}
+	if p.Permutations == 0 || p.Permutations > all {
+		p.Permutations = all
+	}
+	if p.Permutations < 2 {
+		return 0, nil, fmt.Errorf("need at least 2 permutations to tell apart")
+	}
+
+	// Distinct orders, picked at random. Trying again on a repeat always gets there, there are enough to go around.
+	p.perms = p.perms[:0]
+	seen := map[string]bool{}
+	for len(p.perms) < p.Permutations {
+		perm := rnd.Perm(p.Blocks)
+		if key := fmt.Sprint(perm); !seen[key] {
+			seen[key] = true
+			p.perms = append(p.perms, perm)
+		}
+	}
+	return p.Permutations, Softmax{}, nil
+}
+
+func (p *PermutationPrediction) sample(x []float64, rnd *rand.Rand) Sample {
+	k := rnd.Intn(len(p.perms))
+	size := len(x) / p.Blocks
+	shuffled := append([]float64(nil), x...)
+	for to, from := range p.perms[k] {
+		copy(shuffled[to*size:(to+1)*size], x[from*size:(from+1)*size])
+	}
+	target := make([]float64, len(p.perms))
+	target[k] = 1
+	return Sample{Input: shuffled, Target: target}
+}
+
+// Pretrains the hidden layer on a pretext task for some epochs, before fine-tuning the whole network on the
+// labeled data with Fit(). The task is solved by a copy of the network with an output layer of its own, which is
+// thrown away afterwards, and only the hidden weights (and hidden activation) come back. The copy is trained with
+// this Trainer's BatchSize, Workers and logging, on freshly corrupted inputs every epoch.
+func (t *Trainer) Pretrain(inputs [][]float64, task PretextTask, epochs int) error {
+	net := t.net
+	for i, x := range inputs {
+		if len(x) != net.in {
+			return fmt.Errorf("input %d has %d values, network expects %d", i, len(x), net.in)
+		}
+	}
+	out, act, err := task.prepare(net.in, t.rnd)
+	if err != nil {
+		return err
+	}
+
+	pretext := net.clone()
+	pretext.out, pretext.outAct, pretext.loss = out, act, nil
+	pretext.outWeights = mat.NewDense(out, net.hidden, uniformInit(out, net.hidden))
+	pretext.tied, pretext.schema = false, nil
+
+	pt := initTrainer(&pretext)
+	pt.BatchSize, pt.Workers, pt.Logger, pt.Verbose = t.BatchSize, t.Workers, t.Logger, t.Verbose
+	samples := make([]Sample, len(inputs))
+	for e := 0; e < epochs; e++ {
+		for i, x := range inputs {
+			samples[i] = task.sample(x, t.rnd)
+		}
+		if err := pt.Fit(samples, 1); err != nil {
+			return err
+		}
+	}
+
+	net.hidWeights, net.hidAct = pretext.hidWeights, pretext.hidAct
+	if net.tied {
+		net.retie()
+	}
+	return nil
+}
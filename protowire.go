@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// A tiny protocol buffer encoder, for schemas that aren't ours. Core ML's is hundreds of messages of which
+// the export needs a handful, so writing those few fields by hand beats generating code for all of it. Our own
+// schemas (mpnn.proto, coordinator.proto) use generated types instead.
+// https://protobuf.dev/programming-guides/encoding/
+
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+type pbBuffer struct {
+	b []byte
+}
+
+func (p *pbBuffer) tag(field, wireType int) {
+	p.b = binary.AppendUvarint(p.b, uint64(field)<<3|uint64(wireType))
+}
+
+func (p *pbBuffer) uint(field int, v uint64) {
+	p.tag(field, wireVarint)
+	p.b = binary.AppendUvarint(p.b, v)
+}
+
+func (p *pbBuffer) bool(field int, v bool) {
+	if v {
+		p.uint(field, 1)
+	} else {
+		p.uint(field, 0)
+	}
+}
+
+func (p *pbBuffer) double(field int, v float64) {
+	p.tag(field, wireI64)
+	p.b = binary.LittleEndian.AppendUint64(p.b, math.Float64bits(v))
+}
+
+func (p *pbBuffer) bytes(field int, b []byte) {
+	p.tag(field, wireBytes)
+	p.b = binary.AppendUvarint(p.b, uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *pbBuffer) string(field int, s string) {
+	p.bytes(field, []byte(s))
+}
+
+// Embedded messages are written the same way as bytes, so an empty message is just its tag and a zero length.
+func (p *pbBuffer) message(field int, m *pbBuffer) {
+	p.bytes(field, m.b)
+}
+
+func (p *pbBuffer) packedUints(field int, vs []uint64) {
+	var body []byte
+	for _, v := range vs {
+		body = binary.AppendUvarint(body, v)
+	}
+	p.bytes(field, body)
+}
+
+func (p *pbBuffer) packedFloats(field int, vs []float64) {
+	body := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.LittleEndian.PutUint32(body[4*i:], math.Float32bits(float32(v)))
+	}
+	p.bytes(field, body)
+}
+
+func (p *pbBuffer) packedDoubles(field int, vs []float64) {
+	body := make([]byte, 8*len(vs))
+	for i, v := range vs {
+		binary.LittleEndian.PutUint64(body[8*i:], math.Float64bits(v))
+	}
+	p.bytes(field, body)
+}
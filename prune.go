@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Magnitude pruning: zeroes out the given fraction of each layer's weights, smallest first. Trained networks can
+// usually lose a good share of their weights this way with little change to their predictions, especially after
+// a few more epochs of training to let the rest make up for them (though training will start filling the zeros
+// back in, so prune again afterwards). On its own that doesn't make anything faster, see toSparse() for that.
+func (net *MPNN) prune(fraction float64) error {
+	if fraction < 0 || fraction > 1 {
+		return fmt.Errorf("can't prune a fraction of %v", fraction)
+	}
+	pruneMatrix(net.hidWeights, fraction)
+	if net.tied {
+		net.retie()
+	} else {
+		pruneMatrix(net.outWeights, fraction)
+	}
+	return nil
+}
+
+func pruneMatrix(w *mat.Dense, fraction float64) {
+	r, c := w.Dims()
+	mags := make([]float64, 0, r*c)
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			mags = append(mags, math.Abs(w.At(i, j)))
+		}
+	}
+	n := int(fraction * float64(len(mags)))
+	if n == 0 {
+		return
+	}
+	sort.Float64s(mags)
+	threshold := mags[n-1]
+	for i := 0; i < r; i++ {
+		for j := 0; j < c; j++ {
+			if math.Abs(w.At(i, j)) <= threshold && n > 0 {
+				w.Set(i, j, 0)
+				n--
+			}
+		}
+	}
+}
+
+// Fraction of the network's weights that are exactly zero.
+func (net *MPNN) sparsity() float64 {
+	zeros, total := 0, 0
+	for _, w := range []*mat.Dense{net.hidWeights, net.outWeights} {
+		r, c := w.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if w.At(i, j) == 0 {
+					zeros++
+				}
+			}
+		}
+		total += r * c
+	}
+	return float64(zeros) / float64(total)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/rand"
+)
+
+// Particle swarm optimization (Kennedy & Eberhart 1995) over the network's flattened parameters. Each particle is
+// a whole set of weights moving through parameter space with a velocity, which every iteration is pulled towards
+// the best position that particle has found and the best any particle has found:
+//
+//	v ← Inertia·v + Cognitive·r₁·(personal best - x) + Social·r₂·(swarm best - x),   x ← x + v
+//
+// with r₁ and r₂ uniform random per weight. The defaults are Clerc's constriction coefficients, which keep the
+// swarm from flying apart without needing a velocity cap. Like Evolution and Annealing it only needs a Fitness,
+// use scoreFitness() or datasetFitness() to compare it with backprop on the same data and metrics.
+type ParticleSwarm struct {
+	Particles int
+	Inertia   float64
+	Cognitive float64
+	Social    float64
+
+	// Standard deviation of the particles' starting positions around the network's weights.
+	Spread float64
+
+	// Caps the size of each component of a velocity, 0 for no cap.
+	MaxVelocity float64
+
+	Fitness func(net *MPNN) float64
+
+	// Logs every iteration's best fitness, nil for defaultLogger.
+	Logger Logger
+
+	rnd *rand.Rand
+}
+
+func initParticleSwarm(fitness func(net *MPNN) float64) *ParticleSwarm {
+	return &ParticleSwarm{
+		Particles: 30,
+		Inertia:   0.7298,
+		Cognitive: 1.49618,
+		Social:    1.49618,
+		Spread:    0.5,
+		Fitness:   fitness,
+		rnd:       newRand(),
+	}
+}
+
+func (p *ParticleSwarm) log() Logger {
+	if p.Logger == nil {
+		return defaultLogger
+	}
+	return p.Logger
+}
+
+type particle struct {
+	pos, vel []float64
+	best     []float64
+	bestFit  float64
+}
+
+// Flies the swarm for the given number of iterations and leaves net with the best parameters any particle found.
+// Returns the swarm's best fitness after every iteration.
+func (p *ParticleSwarm) Run(net *MPNN, iterations int) ([]float64, error) {
+	if p.Particles < 1 {
+		return nil, fmt.Errorf("need at least one particle, got %d", p.Particles)
+	}
+	if p.Fitness == nil {
+		return nil, fmt.Errorf("no fitness function")
+	}
+	if p.rnd == nil {
+		p.rnd = newRand()
+	}
+	scratch := net.clone()
+	fitness := func(pos []float64) float64 {
+		scratch.setParamVector(pos)
+		f := p.Fitness(&scratch)
+		if math.IsNaN(f) {
+			return math.Inf(-1)
+		}
+		return f
+	}
+
+	start := net.paramVector()
+	swarm := make([]particle, p.Particles)
+	var swarmBest []float64
+	swarmFit := math.Inf(-1)
+	for i := range swarm {
+		s := &swarm[i]
+		s.pos = append([]float64(nil), start...)
+		s.vel = make([]float64, len(start))
+		for j := range s.pos {
+			if i > 0 {
+				// The first particle starts at the network itself.
+				s.pos[j] += p.Spread * p.rnd.NormFloat64()
+			}
+			s.vel[j] = p.Spread * (p.rnd.Float64() - 0.5)
+		}
+		s.best, s.bestFit = append([]float64(nil), s.pos...), fitness(s.pos)
+		if s.bestFit > swarmFit || swarmBest == nil {
+			swarmBest, swarmFit = append(swarmBest[:0], s.best...), s.bestFit
+		}
+	}
+
+	var history []float64
+	for it := 0; it < iterations; it++ {
+		for i := range swarm {
+			s := &swarm[i]
+			for j := range s.pos {
+				v := p.Inertia*s.vel[j] +
+					p.Cognitive*p.rnd.Float64()*(s.best[j]-s.pos[j]) +
+					p.Social*p.rnd.Float64()*(swarmBest[j]-s.pos[j])
+				if p.MaxVelocity > 0 {
+					v = math.Max(-p.MaxVelocity, math.Min(p.MaxVelocity, v))
+				}
+				s.vel[j] = v
+				s.pos[j] += v
+			}
+			if f := fitness(s.pos); f > s.bestFit {
+				s.best, s.bestFit = append(s.best[:0], s.pos...), f
+			}
+		}
+		// The swarm's best is only updated between iterations, so every particle in one is pulled the same way.
+		for i := range swarm {
+			if swarm[i].bestFit > swarmFit {
+				swarmBest, swarmFit = append(swarmBest[:0], swarm[i].best...), swarm[i].bestFit
+			}
+		}
+		history = append(history, swarmFit)
+		p.log().Debug("swarm", "iteration", it+1, "best", swarmFit)
+	}
+
+	net.setParamVector(swarmBest)
+	return history, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// REINFORCE (Williams 1992), policy gradients: the network's Softmax outputs are the probabilities of taking each
+// action, and actions are sampled from them. After some episodes, every action taken has its log probability
+// pushed up in proportion to how much better than usual the rest of its episode went (its return, minus a
+// baseline), so what led to good outcomes gets more likely and what led to bad ones less.
+//
+// The baseline is the average return-to-go over every step of the update's episodes (not the episodes' total
+// returns), so a step's advantage says how its rest of the episode compares with the typical step's. It doesn't
+// change what the gradient points at on average, only how noisy it is, which without it is noisy enough that
+// learning barely gets anywhere. Normalize then also scales the advantages by those returns' standard deviation.
+type REINFORCE struct {
+	Gamma     float64 // How much later rewards count, 0.99 from initREINFORCE()
+	Episodes  int     // Played per update
+	Normalize bool    // Also divides the advantages by their standard deviation, so the step size doesn't depend on the rewards' scale
+	MaxSteps  int     // Cuts episodes short after this many steps, 0 for no limit
+
+	// Applies the updates, so its Optimizer, Momentum and so on are used. Its BatchSize isn't.
+	Trainer *Trainer
+	Logger  Logger
+
+	net *MPNN
+	rnd *rand.Rand
+}
+
+func initREINFORCE(net *MPNN) *REINFORCE {
+	return &REINFORCE{Gamma: 0.99, Episodes: 5, MaxSteps: 500, Trainer: initTrainer(net), net: net, rnd: newRand()}
+}
+
+func (r *REINFORCE) log() Logger {
+	if r.Logger == nil {
+		return defaultLogger
+	}
+	return r.Logger
+}
+
+// The most likely action in a state.
+func (r *REINFORCE) Act(state []float64) (int, error) {
+	p, err := r.net.Predict(state)
+	if err != nil {
+		return 0, err
+	}
+	return argmaxSlice(p), nil
+}
+
+// Draws an action from the policy's probabilities.
+func (r *REINFORCE) sample(probs []float64) int {
+	u := r.rnd.Float64()
+	for a, p := range probs {
+		if u -= p; u < 0 {
+			return a
+		}
+	}
+	return len(probs) - 1
+}
+
+type policyStep struct {
+	cache   forwardCache
+	action  int
+	returns float64 // Discounted sum of the rewards from this step on
+}
+
+// Plays one episode with the current policy.
+func (r *REINFORCE) episode(env Environment) (steps []policyStep, total float64) {
+	state := env.Reset()
+	var rewards []float64
+	for t := 0; r.MaxSteps == 0 || t < r.MaxSteps; t++ {
+		c := r.net.forward(state)
+		action := r.sample(mat.Col(nil, 0, c.hidLayerWeightsOut))
+		next, reward, done := env.Step(action)
+		steps = append(steps, policyStep{cache: c, action: action})
+		rewards = append(rewards, reward)
+		total += reward
+		state = next
+		if done {
+			break
+		}
+	}
+	g := 0.0
+	for t := len(steps) - 1; t >= 0; t-- {
+		g = rewards[t] + r.Gamma*g
+		steps[t].returns = g
+	}
+	return steps, total
+}
+
+// Trains the policy for a number of updates, Episodes each, and returns every episode's total reward.
+func (r *REINFORCE) Run(env Environment, updates int) ([]float64, error) {
+	if _, ok := r.net.outAct.(Softmax); !ok {
+		return nil, fmt.Errorf("policy needs a softmax output layer, network has %s", r.net.outAct.name())
+	}
+	if env.Actions() != r.net.out {
+		return nil, fmt.Errorf("environment has %d actions, network has %d outputs", env.Actions(), r.net.out)
+	}
+	if r.Episodes < 1 {
+		return nil, fmt.Errorf("need at least 1 episode per update")
+	}
+	var totals []float64
+	for u := 0; u < updates; u++ {
+		var steps []policyStep
+		for e := 0; e < r.Episodes; e++ {
+			s, total := r.episode(env)
+			steps = append(steps, s...)
+			totals = append(totals, total)
+		}
+		if len(steps) == 0 {
+			continue
+		}
+
+		mean, sd := 0.0, 0.0
+		for _, s := range steps {
+			mean += s.returns
+		}
+		mean /= float64(len(steps))
+		for _, s := range steps {
+			sd += (s.returns - mean) * (s.returns - mean)
+		}
+		sd = math.Sqrt(sd / float64(len(steps)))
+
+		// The cost is -Σ advantage·log π(action), so its gradient on the probabilities is -advantage/π(action)
+		// at the action taken and 0 elsewhere.
+		caches := make([]forwardCache, len(steps))
+		grads := make([][]float64, len(steps))
+		for i, s := range steps {
+			advantage := s.returns - mean
+			if r.Normalize && sd > 0 {
+				advantage /= sd
+			}
+			caches[i] = s.cache
+			grads[i] = make([]float64, r.net.out)
+			grads[i][s.action] = -advantage / math.Max(s.cache.hidLayerWeightsOut.At(s.action, 0), minRate)
+		}
+		h, o, a := r.net.outputGradients(caches, grads, float64(len(steps)))
+		r.Trainer.applyGradients(nil, h, o, a, len(steps))
+
+		recent := totals[len(totals)-r.Episodes:]
+		avg := 0.0
+		for _, t := range recent {
+			avg += t
+		}
+		r.log().Debug("update", "update", u+1, "mean_return", avg/float64(len(recent)), "steps", len(steps))
+	}
+	return totals, nil
+}
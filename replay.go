@@ -0,0 +1,54 @@
+package main
+
+import (
+	"golang.org/x/exp/rand"
+)
+
+// A fixed-size memory of past training samples for online learning. When a network only ever trains on the newest
+// data it tends to forget what it learned earlier (catastrophic forgetting), so the Trainer mixes a few of these
+// old samples back into every new batch.
+//
+// The buffer uses reservoir sampling: once it's full, each new sample replaces a random old one with probability
+// capacity / samples seen. That way the buffer always holds a uniform random pick of everything seen so far,
+// rather than just the most recent samples.
+type ReplayBuffer struct {
+	samples  []Sample
+	capacity int
+	seen     int
+	rnd      *rand.Rand
+}
+
+func initReplayBuffer(capacity int) *ReplayBuffer {
+	return &ReplayBuffer{
+		samples:  make([]Sample, 0, capacity),
+		capacity: capacity,
+		rnd:      newRand(),
+	}
+}
+
+func (b *ReplayBuffer) add(s Sample) {
+	b.seen++
+	if len(b.samples) < b.capacity {
+		b.samples = append(b.samples, s)
+		return
+	}
+	if i := b.rnd.Intn(b.seen); i < b.capacity {
+		b.samples[i] = s
+	}
+}
+
+// Draws n stored samples at random (fewer if the buffer doesn't hold that many yet).
+func (b *ReplayBuffer) sample(n int) []Sample {
+	if n > len(b.samples) {
+		n = len(b.samples)
+	}
+	out := make([]Sample, n)
+	for i, j := range b.rnd.Perm(len(b.samples))[:n] {
+		out[i] = b.samples[j]
+	}
+	return out
+}
+
+func (b *ReplayBuffer) Len() int {
+	return len(b.samples)
+}
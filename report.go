@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ClassMetrics struct {
+	Precision float64 // Of the samples guessed as this class, the fraction that really were
+	Recall    float64 // Of the samples really in this class, the fraction guessed as it
+	F1        float64 // Harmonic mean of precision and recall
+	Support   int     // Samples really in this class
+}
+
+// Precision, recall and F1 for every class, plus their averages: macro weighs every class the same, weighted
+// weighs them by support, and micro pools every sample together (which for one label per sample works out to
+// the accuracy).
+type ClassificationReport struct {
+	Labels   []string
+	Classes  []ClassMetrics
+	Accuracy float64
+	Macro    ClassMetrics
+	Micro    ClassMetrics
+	Weighted ClassMetrics
+}
+
+// Builds the report from a confusion matrix (see confusionMatrix()). labels names the classes, nil numbers them.
+func classificationReport(confusion [][]int, labels []string) ClassificationReport {
+	r := ClassificationReport{Labels: labels, Classes: make([]ClassMetrics, len(confusion))}
+	if r.Labels == nil {
+		r.Labels = make([]string, len(confusion))
+		for i := range r.Labels {
+			r.Labels[i] = fmt.Sprint(i)
+		}
+	}
+
+	truth, guessed, correct, total := confusionTotals(confusion)
+	for k := range confusion {
+		tp := float64(confusion[k][k])
+		m := ClassMetrics{Support: int(truth[k])}
+		if guessed[k] > 0 {
+			m.Precision = tp / guessed[k]
+		}
+		if truth[k] > 0 {
+			m.Recall = tp / truth[k]
+		}
+		m.F1 = f1(m.Precision, m.Recall)
+		r.Classes[k] = m
+
+		n := float64(len(confusion))
+		r.Macro.Precision += m.Precision / n
+		r.Macro.Recall += m.Recall / n
+		r.Macro.F1 += m.F1 / n
+		if total > 0 {
+			w := truth[k] / total
+			r.Weighted.Precision += w * m.Precision
+			r.Weighted.Recall += w * m.Recall
+			r.Weighted.F1 += w * m.F1
+		}
+	}
+	if total > 0 {
+		r.Accuracy = correct / total
+	}
+	r.Micro = ClassMetrics{Precision: r.Accuracy, Recall: r.Accuracy, F1: r.Accuracy}
+	r.Macro.Support, r.Micro.Support, r.Weighted.Support = int(total), int(total), int(total)
+	return r
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// Laid out like scikit-learn's classification_report(), so the two can be compared side by side.
+func (r ClassificationReport) String() string {
+	width := len("weighted avg")
+	for _, l := range r.Labels {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	row := func(name string, m ClassMetrics) string {
+		return fmt.Sprintf("%*s %9.2f %9.2f %9.2f %9d\n", width, name, m.Precision, m.Recall, m.F1, m.Support)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s %9s %9s %9s %9s\n\n", width, "", "precision", "recall", "f1-score", "support")
+	for k, m := range r.Classes {
+		b.WriteString(row(r.Labels[k], m))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "%*s %9s %9s %9.2f %9d\n", width, "accuracy", "", "", r.Accuracy, r.Macro.Support)
+	b.WriteString(row("micro avg", r.Micro))
+	b.WriteString(row("macro avg", r.Macro))
+	b.WriteString(row("weighted avg", r.Weighted))
+	return b.String()
+}
+
+func printClassificationReport(r ClassificationReport) {
+	fmt.Print(r)
+}
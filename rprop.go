@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Resilient backpropagation (Riedmiller & Braun 1993). Only the sign of each weight's gradient is used, never its
+// size: every weight has its own step size, which grows by EtaPlus while the gradient keeps its sign and shrinks
+// by EtaMinus when it flips (meaning the last step jumped over a minimum). That makes it indifferent to how the
+// gradient is scaled, so there's no learning rate to tune (Trainer's is ignored), and vanishing gradients in
+// saturated sigmoids don't slow it down.
+//
+// Signs only mean something if they're consistent from step to step, so Rprop is for full-batch training: set
+// Trainer.BatchSize to the size of the dataset, or at least large.
+//
+// With Improved unset it's the original Rprop+, which takes back the previous step whenever a gradient changes
+// sign. With Improved set it's iRprop+ (Igel & Hüsken 2000), which only takes it back if the loss went up too,
+// and usually converges faster.
+type Rprop struct {
+	EtaPlus, EtaMinus float64
+	InitialStep       float64
+	MinStep, MaxStep  float64
+	Improved          bool
+
+	steps, prevGrad, prevDelta []float64
+	prevLoss                   float64
+}
+
+// Rprop+ with the usual constants.
+func initRprop() *Rprop {
+	return &Rprop{EtaPlus: 1.2, EtaMinus: 0.5, InitialStep: 0.1, MinStep: 1e-6, MaxStep: 50, prevLoss: math.Inf(1)}
+}
+
+// iRprop+ with the usual constants.
+func initIRpropPlus() *Rprop {
+	r := initRprop()
+	r.Improved = true
+	return r
+}
+
+func (r *Rprop) step(t *Trainer, batch []Sample, hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	net := t.net
+	grad := net.flattenGradient(hidGrad, outGrad, actGrad)
+	if len(r.steps) != len(grad) {
+		r.steps = make([]float64, len(grad))
+		for i := range r.steps {
+			r.steps[i] = r.InitialStep
+		}
+		r.prevGrad, r.prevDelta = make([]float64, len(grad)), make([]float64, len(grad))
+	}
+
+	// iRprop+ needs to know whether the last step made things worse. The gradient is for the current weights, so
+	// the batch's loss at them is the loss after the last step.
+	worse := true
+	if r.Improved {
+		worse = false
+		if batch != nil {
+			loss := net.meanLoss(batch)
+			worse = loss > r.prevLoss
+			r.prevLoss = loss
+		}
+	}
+
+	w := net.paramVector()
+	for i, g := range grad {
+		switch s := g * r.prevGrad[i]; {
+		case s > 0:
+			r.steps[i] = math.Min(r.steps[i]*r.EtaPlus, r.MaxStep)
+		case s < 0:
+			r.steps[i] = math.Max(r.steps[i]*r.EtaMinus, r.MinStep)
+			if worse {
+				w[i] -= r.prevDelta[i]
+			}
+			// Leaves the next step free of the sign test, so it doesn't get punished twice for the same jump.
+			r.prevGrad[i], r.prevDelta[i] = 0, 0
+			continue
+		}
+		delta := 0.0
+		if g > 0 {
+			delta = -r.steps[i]
+		} else if g < 0 {
+			delta = r.steps[i]
+		}
+		w[i] += delta
+		r.prevGrad[i], r.prevDelta[i] = g, delta
+	}
+	net.setParamVector(w)
+}
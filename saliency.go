@@ -0,0 +1,55 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// A saliency map (Simonyan et al. 2013) shows which input features the network's answer for an output depends
+// on. It's the gradient of that output with respect to the input: features where a small change would move the
+// output a lot get big values, features the network ignores get values near 0.
+func (net *MPNN) saliency(input []float64, output int) []float64 {
+	c := net.forward(input)
+
+	// d output / d output is 1 for the one we're explaining and 0 for the rest.
+	score := mat.NewDense(net.out, 1, nil)
+	score.Set(output, 0, 1)
+	inputGrad := net.inputGradient(c, score)
+
+	sal := make([]float64, net.in)
+	for i := range sal {
+		sal[i] = math.Abs(inputGrad.At(i, 0))
+	}
+	return sal
+}
+
+// Like backward, but carries the gradient one layer further to get it with respect to the input itself. Training
+// never needs this, so it's kept separate to save the extra (input sized) matrix product on every sample.
+func (net *MPNN) inputGradient(c forwardCache, outputGrad mat.Matrix) mat.Matrix {
+	_, hiddenDelta, _ := net.deltas(c, outputGrad)
+	return dot(net.hidWeights.T(), hiddenDelta)
+}
+
+// Turns a saliency map of a width x height image input into a grayscale image, brightest where the network
+// is paying the most attention.
+func saliencyImage(sal []float64, width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	max := 0.0
+	for _, v := range sal {
+		max = math.Max(max, v)
+	}
+	if max == 0 {
+		return img
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if i := y*width + x; i < len(sal) {
+				img.SetGray(x, y, color.Gray{Y: uint8(255 * sal[i] / max)})
+			}
+		}
+	}
+	return img
+}
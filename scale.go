@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Standardizes every input to zero mean and unit variance, (x - mean) / std. Sigmoids saturate quickly, so an
+// input measured in thousands next to one measured in fractions leaves the small one with almost no say in what
+// the hidden layer does. Like the Imputer, the statistics come from the training data only.
+type Scaler struct {
+	Mean []float64 `json:"mean"`
+	Std  []float64 `json:"std"`
+}
+
+// Works out the mean and standard deviation of each input. NaNs are skipped so scaling can come before imputing,
+// and an input that never changes gets a std of 1 so it isn't divided by zero.
+func (s *Scaler) fit(inputs [][]float64) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("no inputs to fit the scaler on")
+	}
+	if err := checkWidths(inputs); err != nil {
+		return err
+	}
+	n := len(inputs[0])
+	s.Mean = make([]float64, n)
+	s.Std = make([]float64, n)
+	for f := 0; f < n; f++ {
+		count, total := 0.0, 0.0
+		for _, in := range inputs {
+			if !math.IsNaN(in[f]) {
+				count++
+				total += in[f]
+			}
+		}
+		if count == 0 {
+			s.Std[f] = 1
+			continue
+		}
+		s.Mean[f] = total / count
+
+		sq := 0.0
+		for _, in := range inputs {
+			if !math.IsNaN(in[f]) {
+				d := in[f] - s.Mean[f]
+				sq += d * d
+			}
+		}
+		s.Std[f] = math.Sqrt(sq / count)
+		if s.Std[f] == 0 {
+			s.Std[f] = 1
+		}
+	}
+	return nil
+}
+
+func (s *Scaler) transform(input []float64) []float64 {
+	out := append([]float64(nil), input...)
+	for i := range out {
+		if i < len(s.Mean) {
+			out[i] = (out[i] - s.Mean[i]) / s.Std[i]
+		}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package main
+
+import "math"
+
+// A hyperparameter's value for each epoch, counted over every Fit() call like EpochStats.Epoch.
+type Schedule func(epoch int) float64
+
+// The hyperparameters that can follow a Schedule instead of staying put, see Trainer.Schedules. Each one that's
+// set is applied at the start of every epoch, overwriting whatever was there: a LearningRate schedule takes over
+// from ReduceLROnPlateau, and a Dropout one from OverfitDetector raising it.
+type Schedules struct {
+	LearningRate Schedule // The network's learning rate, Trainer.Decay still applies on top
+	Momentum     Schedule // Trainer.Momentum
+	WeightDecay  Schedule // Trainer.WeightDecay
+	Dropout      Schedule // The network's dropout
+}
+
+func (t *Trainer) applySchedules(epoch int) {
+	s := t.Schedules
+	if s.LearningRate != nil {
+		t.net.learnRate = s.LearningRate(epoch)
+	}
+	if s.Momentum != nil {
+		t.Momentum = s.Momentum(epoch)
+	}
+	if s.WeightDecay != nil {
+		t.WeightDecay = s.WeightDecay(epoch)
+	}
+	if s.Dropout != nil {
+		t.net.dropout = math.Max(0, math.Min(s.Dropout(epoch), 0.99))
+	}
+	if s.LearningRate != nil || s.Momentum != nil || s.WeightDecay != nil || s.Dropout != nil {
+		t.log().Debug("schedules", "epoch", epoch, "rate", t.net.learnRate, "momentum", t.Momentum,
+			"weight_decay", t.WeightDecay, "dropout", t.net.dropout)
+	}
+}
+
+func constantSchedule(v float64) Schedule {
+	return func(int) float64 { return v }
+}
+
+// Starts at start and is multiplied by factor every `every` epochs.
+func stepSchedule(start, factor float64, every int) Schedule {
+	return func(epoch int) float64 { return start * math.Pow(factor, float64(epoch/every)) }
+}
+
+// start·gammaᵉᵖᵒᶜʰ.
+func exponentialSchedule(start, gamma float64) Schedule {
+	return func(epoch int) float64 { return start * math.Pow(gamma, float64(epoch)) }
+}
+
+// Goes in a straight line from `from` at epoch 0 to `to` at epoch epochs, and stays there.
+func linearSchedule(from, to float64, epochs int) Schedule {
+	return func(epoch int) float64 {
+		if epoch >= epochs {
+			return to
+		}
+		return from + (to-from)*float64(epoch)/float64(epochs)
+	}
+}
+
+// Cosine annealing (Loshchilov & Hutter 2017): from `from` to `to` along half a cosine over epochs epochs, slow
+// to leave `from`, quick through the middle and slow to settle on `to`, where it stays.
+func cosineSchedule(from, to float64, epochs int) Schedule {
+	return func(epoch int) float64 {
+		if epoch >= epochs {
+			return to
+		}
+		return to + (from-to)*(1+math.Cos(math.Pi*float64(epoch)/float64(epochs)))/2
+	}
+}
+
+// Ramps s up linearly over the first epochs epochs, from a fraction 1/epochs of its value to all of it. Big
+// learning rates at the very start, while the weights are still random, can knock training off course for good.
+func warmupSchedule(epochs int, s Schedule) Schedule {
+	return func(epoch int) float64 {
+		if epoch >= epochs {
+			return s(epoch)
+		}
+		return s(epoch) * float64(epoch+1) / float64(epochs)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type FeatureType string
+
+const (
+	Continuous FeatureType = "continuous"
+	Integer    FeatureType = "integer"
+	Binary     FeatureType = "binary" // 0 or 1
+)
+
+// What one input of the network means and what values it's allowed to take.
+type Feature struct {
+	Name string      `json:"name"`
+	Type FeatureType `json:"type"`
+
+	// Expected range (inclusive), ignored if both are 0
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// Describes the network's inputs, in order. It's saved along with the model, so whoever serves it later knows
+// exactly what it expects, and Predict() can turn away inputs that don't fit instead of quietly returning garbage.
+type Schema struct {
+	Features []Feature `json:"features"`
+}
+
+func (net *MPNN) setSchema(features []Feature) error {
+	if len(features) != net.in {
+		return fmt.Errorf("schema has %d features, network has %d inputs", len(features), net.in)
+	}
+	seen := make(map[string]bool)
+	for _, f := range features {
+		if f.Name == "" || seen[f.Name] {
+			return fmt.Errorf("feature names must be unique and non-empty, got %q", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	net.schema = &Schema{Features: features}
+	return nil
+}
+
+// Runs the network on an input. If the network has a schema, the input is checked against it first.
+func (net *MPNN) Predict(input []float64) ([]float64, error) {
+	if len(input) != net.in {
+		return nil, fmt.Errorf("input has %d values, network expects %d", len(input), net.in)
+	}
+	if net.schema != nil {
+		if err := net.schema.validate(input); err != nil {
+			return nil, err
+		}
+	}
+	out := forwardProp(input, *net)
+	result := make([]float64, net.out)
+	for i := range result {
+		result[i] = out.At(i, 0)
+	}
+	return result, nil
+}
+
+// Same as Predict(), but with the features given by name, so callers can't get the order wrong.
+func (net *MPNN) PredictNamed(values map[string]float64) ([]float64, error) {
+	if net.schema == nil {
+		return nil, fmt.Errorf("network has no schema, so features can't be given by name")
+	}
+	input, err := net.schema.vector(values)
+	if err != nil {
+		return nil, err
+	}
+	return net.Predict(input)
+}
+
+func (s *Schema) validate(input []float64) error {
+	var problems []string
+	for i, f := range s.Features {
+		v := input[i]
+		switch {
+		case math.IsNaN(v) || math.IsInf(v, 0):
+			problems = append(problems, fmt.Sprintf("%s (input %d) is %v", f.Name, i, v))
+		case f.Type == Integer && v != math.Trunc(v):
+			problems = append(problems, fmt.Sprintf("%s (input %d) = %v should be a whole number", f.Name, i, v))
+		case f.Type == Binary && v != 0 && v != 1:
+			problems = append(problems, fmt.Sprintf("%s (input %d) = %v should be 0 or 1", f.Name, i, v))
+		case (f.Min != 0 || f.Max != 0) && (v < f.Min || v > f.Max):
+			problems = append(problems, fmt.Sprintf("%s (input %d) = %v is outside [%v, %v]", f.Name, i, v, f.Min, f.Max))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("input doesn't match the schema: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Puts named feature values into the order the network expects.
+func (s *Schema) vector(values map[string]float64) ([]float64, error) {
+	input := make([]float64, len(s.Features))
+	var missing []string
+	for i, f := range s.Features {
+		v, ok := values[f.Name]
+		if !ok {
+			missing = append(missing, f.Name)
+		}
+		input[i] = v
+	}
+	var unknown []string
+	for name := range values {
+		if s.index(name) < 0 {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	if len(missing) > 0 || len(unknown) > 0 {
+		return nil, fmt.Errorf("features don't match the schema: missing %v, unknown %v", missing, unknown)
+	}
+	return input, nil
+}
+
+func (s *Schema) index(name string) int {
+	for i, f := range s.Features {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// The schema travels in the model's metadata as JSON.
+func (s *Schema) encode() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func decodeSchema(data string) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("reading schema: %w", err)
+	}
+	return &s, nil
+}
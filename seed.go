@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/exp/rand"
+)
+
+// Every random number generator in the package (weight initialization, shuffling, dropout masks, augmentation,
+// replay sampling, explanations...) is seeded from this one, in the order they're created. It starts out seeded
+// from the clock, so runs differ, until Seed() pins it.
+var seeder = struct {
+	sync.Mutex
+	rnd *rand.Rand
+}{rnd: rand.New(rand.NewSource(uint64(time.Now().UnixNano())))}
+
+// Makes everything random from here on reproducible: the same seed followed by the same calls gives the same
+// weights, batches and predictions. Call it once at the start of the program, before building any networks.
+func Seed(seed uint64) {
+	seeder.Lock()
+	defer seeder.Unlock()
+	seeder.rnd = rand.New(rand.NewSource(seed))
+}
+
+// A new generator seeded from the global one.
+func newRand() *rand.Rand {
+	seeder.Lock()
+	defer seeder.Unlock()
+	return rand.New(rand.NewSource(seeder.rnd.Uint64()))
+}
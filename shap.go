@@ -0,0 +1,116 @@
+package main
+
+import (
+	"gonum.org/v1/gonum/mat"
+)
+
+// Approximate SHAP values (Lundberg & Lee 2017, "Kernel SHAP") for one prediction. Each feature gets a share of
+// the difference between this prediction and the average prediction over the background dataset:
+//
+//	output(input) = base + Σ phi[i]
+//
+// A feature's share is its Shapley value: its average contribution over every subset ("coalition") of the other
+// features. There are far too many subsets to try them all, so `samples` random coalitions are drawn, each one
+// is scored with the features outside the coalition filled in from the background samples, and the Shapley
+// values are recovered with the weighted linear regression from the Kernel SHAP paper.
+//
+// Every coalition averages over the whole background set, so keep it small (a few dozen representative samples).
+func (net *MPNN) kernelSHAP(input []float64, output int, background []Sample, samples int) (phi []float64, base float64) {
+	d := len(input)
+	phi = make([]float64, d)
+	if len(background) == 0 {
+		return phi, 0
+	}
+
+	full := make([]bool, d)
+	for i := range full {
+		full[i] = true
+	}
+	base = net.coalitionValue(input, make([]bool, d), output, background)
+	delta := net.coalitionValue(input, full, output, background) - base
+	if d == 1 {
+		phi[0] = delta
+		return phi, base
+	}
+
+	// Coalition sizes are drawn in proportion to the Shapley kernel's total weight for that size,
+	// (d-1) / (k(d-k)), which means every sampled coalition then counts equally in the regression.
+	sizeWeights := make([]float64, d)
+	total := 0.0
+	for k := 1; k < d; k++ {
+		sizeWeights[k] = float64(d-1) / float64(k*(d-k))
+		total += sizeWeights[k]
+	}
+	rnd := newRand()
+
+	// Coalitions are drawn in pairs with their complement, which cancels out a lot of the sampling noise.
+	var masks [][]bool
+	for len(masks) < samples {
+		pick, k := rnd.Float64()*total, 1
+		for ; k < d-1 && pick > sizeWeights[k]; k++ {
+			pick -= sizeWeights[k]
+		}
+		mask := make([]bool, d)
+		for _, j := range rnd.Perm(d)[:k] {
+			mask[j] = true
+		}
+		complement := make([]bool, d)
+		for j := range mask {
+			complement[j] = !mask[j]
+		}
+		masks = append(masks, mask, complement)
+	}
+
+	// The shares have to add up to delta, so solve for the first d-1 and give the last feature what's left:
+	// value - base - z[d-1]·delta = Σ (z[j] - z[d-1])·phi[j]
+	x := mat.NewDense(len(masks), d-1, nil)
+	y := mat.NewVecDense(len(masks), nil)
+	for i, mask := range masks {
+		last := boolFloat(mask[d-1])
+		for j := 0; j < d-1; j++ {
+			x.Set(i, j, boolFloat(mask[j])-last)
+		}
+		y.SetVec(i, net.coalitionValue(input, mask, output, background)-base-last*delta)
+	}
+
+	var solved mat.VecDense
+	if err := solved.SolveVec(x, y); err != nil {
+		// Too few samples to pin down every feature, fall back to splitting the difference evenly.
+		for j := range phi {
+			phi[j] = delta / float64(d)
+		}
+		return phi, base
+	}
+	rest := delta
+	for j := 0; j < d-1; j++ {
+		phi[j] = solved.AtVec(j)
+		rest -= phi[j]
+	}
+	phi[d-1] = rest
+	return phi, base
+}
+
+// The network's average output when the features in the coalition come from the input and the rest come from
+// each background sample in turn.
+func (net *MPNN) coalitionValue(input []float64, coalition []bool, output int, background []Sample) float64 {
+	mixed := make([]float64, len(input))
+	total := 0.0
+	for _, b := range background {
+		for j := range mixed {
+			if coalition[j] {
+				mixed[j] = input[j]
+			} else {
+				mixed[j] = b.Input[j]
+			}
+		}
+		total += forwardProp(mixed, *net).At(output, 0)
+	}
+	return total / float64(len(background))
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
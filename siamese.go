@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Two samples for a Siamese network, and whether they're alike. The targets are each twin's own, and only needed
+// when Siamese.Weight is set.
+type SiamesePair struct {
+	A, B    Sample
+	Similar bool
+}
+
+// The combined cost of a twin pass: Pair on the two outputs, plus Weight times the network's own Loss for each
+// twin against its target. Training on both at once (verification plus identification, as in DeepID2) tends to
+// give better embeddings than either alone: the pair loss shapes distances, the per-twin loss keeps the outputs
+// meaningful on their own.
+type Siamese struct {
+	Pair   PairLoss // nil for no pair loss
+	Weight float64  // 0 for no per-twin loss
+}
+
+// Trains a Siamese network, BatchSize pairs per step. There's only one network: the twins are two forward
+// passes through the same weights, each with its own dropout. Since the weights are shared, the combined loss's
+// gradient with respect to them is the sum of what flows back through each pass, so each twin is backpropagated
+// separately with its share of the output gradient (the pair loss's gradient for its side, plus its own loss's)
+// and the two weight gradients are added together, exactly as if the two passes were one bigger network.
+//
+// Each epoch's average combined loss is logged and added to History as its TrainLoss.
+func (t *Trainer) FitSiamese(pairs []SiamesePair, loss Siamese, epochs int) error {
+	if loss.Pair == nil && loss.Weight == 0 {
+		return fmt.Errorf("no pair loss and no per-twin loss, nothing to train on")
+	}
+	if len(pairs) == 0 {
+		return fmt.Errorf("no pairs to train on")
+	}
+	for i, p := range pairs {
+		for _, s := range []Sample{p.A, p.B} {
+			if len(s.Input) != t.net.in {
+				return fmt.Errorf("pair %d has an input of %d values, network expects %d", i, len(s.Input), t.net.in)
+			}
+			if loss.Weight != 0 && len(s.Target) != t.net.out {
+				return fmt.Errorf("pair %d has a target of %d values, network has %d outputs", i, len(s.Target), t.net.out)
+			}
+		}
+	}
+	size := t.BatchSize
+	if size < 1 {
+		size = 1
+	}
+	for e := 0; e < epochs; e++ {
+		t.applySchedules(len(t.History))
+		start := time.Now()
+		order := t.rnd.Perm(len(pairs))
+		total := 0.0
+		for i := 0; i < len(order); i += size {
+			end := i + size
+			if end > len(order) {
+				end = len(order)
+			}
+			batch := make([]SiamesePair, 0, end-i)
+			for _, k := range order[i:end] {
+				batch = append(batch, pairs[k])
+			}
+			total += t.siameseStep(batch, loss)
+		}
+		t.recordEpoch(start, total/float64(len(pairs)))
+	}
+	return nil
+}
+
+// One step on a batch of pairs, returning the batch's summed loss.
+func (t *Trainer) siameseStep(batch []SiamesePair, loss Siamese) float64 {
+	own := t.net.lossFn()
+	total := 0.0
+	caches := make([]forwardCache, 0, 2*len(batch))
+	grads := make([][]float64, 0, 2*len(batch))
+	for _, p := range batch {
+		ca, cb := t.net.forwardDropout(p.A.Input, t.rnd), t.net.forwardDropout(p.B.Input, t.rnd)
+		a, b := mat.Col(nil, 0, ca.hidLayerWeightsOut), mat.Col(nil, 0, cb.hidLayerWeightsOut)
+		ga, gb := make([]float64, len(a)), make([]float64, len(b))
+		if loss.Pair != nil {
+			total += loss.Pair.loss(a, b, p.Similar)
+			ga, gb = loss.Pair.grad(a, b, p.Similar)
+		}
+		if loss.Weight != 0 {
+			total += loss.Weight * (own.loss(a, p.A.Target) + own.loss(b, p.B.Target))
+			for k, g := range own.grad(a, p.A.Target) {
+				ga[k] += loss.Weight * g
+			}
+			for k, g := range own.grad(b, p.B.Target) {
+				gb[k] += loss.Weight * g
+			}
+		}
+		caches, grads = append(caches, ca, cb), append(grads, ga, gb)
+	}
+	h, o, a := t.net.outputGradients(caches, grads, float64(len(batch)))
+	t.applyGradients(nil, h, o, a, len(batch))
+	return total
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// An input vector stored as only its non-zero entries, for data like bag-of-words counts or heavily one-hot
+// encoded rows where almost every value is 0. The hidden layer's weighted sums then only have to visit the
+// weights of the non-zero inputs, and the same goes for their gradient.
+type SparseVector struct {
+	Len   int       // Length of the full vector
+	Index []int     // Positions of the non-zero values
+	Value []float64 // The values at those positions
+}
+
+// Keeps only the non-zero values of a regular vector.
+func sparseFromDense(x []float64) SparseVector {
+	v := SparseVector{Len: len(x)}
+	for i, x := range x {
+		if x != 0 {
+			v.Index = append(v.Index, i)
+			v.Value = append(v.Value, x)
+		}
+	}
+	return v
+}
+
+func (v SparseVector) dense() []float64 {
+	x := make([]float64, v.Len)
+	for k, i := range v.Index {
+		x[i] += v.Value[k]
+	}
+	return x
+}
+
+func (v SparseVector) check(size int) error {
+	if v.Len != size {
+		return fmt.Errorf("sparse input has length %d, network expects %d", v.Len, size)
+	}
+	if len(v.Index) != len(v.Value) {
+		return fmt.Errorf("sparse input has %d indices but %d values", len(v.Index), len(v.Value))
+	}
+	for _, i := range v.Index {
+		if i < 0 || i >= v.Len {
+			return fmt.Errorf("sparse input index %d out of range", i)
+		}
+	}
+	return nil
+}
+
+// W ⋅ v, only touching the columns of W where v isn't zero.
+func (v SparseVector) product(w mat.Matrix) *mat.Dense {
+	r, _ := w.Dims()
+	out := mat.NewDense(r, 1, nil)
+	for k, j := range v.Index {
+		for i := 0; i < r; i++ {
+			out.Set(i, 0, out.At(i, 0)+w.At(i, j)*v.Value[k])
+		}
+	}
+	return out
+}
+
+// d ⋅ vᵀ, the gradient of weights fed by v. Columns of inputs that were zero stay zero.
+func (v SparseVector) outer(d mat.Matrix) *mat.Dense {
+	r, _ := d.Dims()
+	out := mat.NewDense(r, v.Len, nil)
+	for k, j := range v.Index {
+		for i := 0; i < r; i++ {
+			out.Set(i, j, out.At(i, j)+d.At(i, 0)*v.Value[k])
+		}
+	}
+	return out
+}
+
+// forwardDropout() for a sparse input.
+func (net *MPNN) forwardSparse(x SparseVector, rnd *rand.Rand) forwardCache {
+	return net.forwardFrom(forwardCache{sparseIn: &x}, x.product(net.hidWeights), rnd)
+}
+
+// Runs the network on a training sample, through whichever of its inputs is set.
+func (net *MPNN) forwardSample(s Sample, rnd *rand.Rand) forwardCache {
+	if s.Sparse != nil {
+		return net.forwardSparse(*s.Sparse, rnd)
+	}
+	return net.forwardDropout(s.Input, rnd)
+}
+
+// Predict() for a sparse input.
+func (net *MPNN) PredictSparse(x SparseVector) ([]float64, error) {
+	if err := x.check(net.in); err != nil {
+		return nil, err
+	}
+	if net.schema != nil {
+		if err := net.schema.validate(x.dense()); err != nil {
+			return nil, err
+		}
+	}
+	out := net.forwardSparse(x, nil).hidLayerWeightsOut
+	result := make([]float64, net.out)
+	for i := range result {
+		result[i] = out.At(i, 0)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Somewhere to keep model files and checkpoints: a local directory (DirStorage), or an object storage bucket
+// (S3Storage) so a training job in a container can keep its artifacts after the container is gone. Names are
+// relative to the storage, like "epoch-000012.mpnn".
+type Storage interface {
+	// Stores whatever write() writes under name. Nothing is stored, and anything already under name is left
+	// alone, unless write() returns nil, so a failed or interrupted save never leaves half a file behind.
+	Put(name string, write func(w io.Writer) error) error
+
+	Open(name string) (io.ReadCloser, error)
+
+	// Every name starting with prefix, sorted.
+	List(prefix string) ([]string, error)
+
+	// Removing a name that doesn't exist isn't an error.
+	Remove(name string) error
+}
+
+// Stores files in a local directory, created when the first file is saved.
+type DirStorage string
+
+// Writes to a temporary file next to the real one, which is only renamed into place once it's complete and
+// synced to disk. A rename within a directory is atomic, so a crash partway through leaves the old file as it
+// was.
+func (d DirStorage) Put(name string, write func(w io.Writer) error) (err error) {
+	path := filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable by its owner only, give it the usual permissions.
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Makes a rename in dir durable. Best effort: not every platform can sync a directory.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func (d DirStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+// Only looks at the directory itself, not subdirectories.
+func (d DirStorage) List(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d DirStorage) Remove(name string) error {
+	err := os.Remove(filepath.Join(string(d), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Saves the network to storage, compressed if the name ends in .gz or .zst.
+func (net *MPNN) saveTo(s Storage, name string) error {
+	return saveModelTo(s, name, net.metadata(), net.tensors())
+}
+
+func saveModelTo(s Storage, name string, meta map[string]any, tensors []namedTensor) error {
+	return s.Put(name, func(w io.Writer) error {
+		cw, err := compressWriter(w, compressionFor(name))
+		if err != nil {
+			return err
+		}
+		if err := writeModel(cw, meta, tensors); err != nil {
+			return err
+		}
+		return cw.Close()
+	})
+}
+
+func loadFrom(s Storage, name string) (MPNN, error) {
+	r, err := s.Open(name)
+	if err != nil {
+		return MPNN{}, err
+	}
+	defer r.Close()
+	return readMPNN(r)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// Splits text into words: runs of letters and digits (apostrophes inside a word stay, "don't" is one word),
+// lowercased unless KeepCase is set.
+type Tokenizer struct {
+	KeepCase  bool
+	MinLength int             // Shorter words are dropped, 0 keeps them all
+	StopWords map[string]bool // Words to drop, after lowercasing ("the", "a", "of"...)
+	Bigrams   bool            // Also adds each pair of neighbouring words, "not good" as well as "not" and "good"
+}
+
+func (tk Tokenizer) Tokenize(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	words := fields[:0]
+	for _, w := range fields {
+		w = strings.Trim(w, "'")
+		if !tk.KeepCase {
+			w = strings.ToLower(w)
+		}
+		if w == "" || len([]rune(w)) < tk.MinLength || tk.StopWords[w] {
+			continue
+		}
+		words = append(words, w)
+	}
+	if tk.Bigrams {
+		n := len(words)
+		for i := 1; i < n; i++ {
+			words = append(words, words[i-1]+" "+words[i])
+		}
+	}
+	return words
+}
+
+// Turns texts into word count vectors, one input per vocabulary word, for a network to classify. The vectors are
+// SparseVectors, since any one text only uses a handful of the vocabulary.
+type BagOfWords struct {
+	Tokenizer   Tokenizer
+	MinCount    int  // Words seen fewer times in the training texts are ignored, 0 for 1
+	MaxFeatures int  // Keeps only this many of the most frequent words, 0 for no limit
+	Binary      bool // 1 for words that occur at all, rather than how many times
+
+	// Weighs each count by the word's inverse document frequency, ln((1 + texts)/(1 + texts it occurs in)) + 1, and
+	// scales each vector to length 1. Words that turn up everywhere count for less than the ones that set a text
+	// apart, and long texts don't get bigger inputs than short ones.
+	TFIDF bool
+
+	Vocab *Vocabulary
+	IDF   []float64 // Fit on the training texts along with the vocabulary, and saved with it
+}
+
+// Builds the vocabulary from the training texts.
+func (b *BagOfWords) fit(texts []string) error {
+	docs := make([][]string, len(texts))
+	for i, text := range texts {
+		docs[i] = b.Tokenizer.Tokenize(text)
+	}
+	vocab := buildVocabulary(docs, b.MinCount)
+	if b.MaxFeatures > 0 && vocab.Len() > b.MaxFeatures {
+		vocab.Words, vocab.Counts = vocab.Words[:b.MaxFeatures], vocab.Counts[:b.MaxFeatures]
+		vocab.reindex()
+	}
+	if vocab.Len() == 0 {
+		return fmt.Errorf("no words in the training texts made it into the vocabulary")
+	}
+	b.Vocab, b.IDF = vocab, nil
+	if b.TFIDF {
+		df := make([]float64, vocab.Len())
+		for _, doc := range docs {
+			seen := map[int]bool{}
+			for _, w := range doc {
+				if i := vocab.Index(w); i >= 0 && !seen[i] {
+					seen[i] = true
+					df[i]++
+				}
+			}
+		}
+		b.IDF = make([]float64, vocab.Len())
+		for i := range b.IDF {
+			b.IDF[i] = math.Log((1+float64(len(docs)))/(1+df[i])) + 1
+		}
+	}
+	return nil
+}
+
+// A text's counts, or TF-IDF weights. Words that aren't in the vocabulary are left out.
+func (b *BagOfWords) vector(text string) SparseVector {
+	counts := map[int]float64{}
+	var order []int // Positions in the order they first appear, so the same text always gives the same vector
+	for _, w := range b.Tokenizer.Tokenize(text) {
+		i := b.Vocab.Index(w)
+		if i < 0 {
+			continue
+		}
+		if _, ok := counts[i]; !ok {
+			order = append(order, i)
+		}
+		if b.Binary {
+			counts[i] = 1
+		} else {
+			counts[i]++
+		}
+	}
+	v := SparseVector{Len: b.Vocab.Len(), Index: order, Value: make([]float64, len(order))}
+	for k, i := range order {
+		v.Value[k] = counts[i]
+		if b.IDF != nil {
+			v.Value[k] *= b.IDF[i]
+		}
+	}
+	if b.IDF != nil {
+		if norm := floats.Norm(v.Value, 2); norm > 0 {
+			floats.Scale(1/norm, v.Value)
+		}
+	}
+	return v
+}
+
+func (b *BagOfWords) samples(texts []string, targets [][]float64) ([]Sample, error) {
+	if len(texts) != len(targets) {
+		return nil, fmt.Errorf("%d texts but %d targets", len(texts), len(targets))
+	}
+	data := make([]Sample, len(texts))
+	for i, text := range texts {
+		v := b.vector(text)
+		data[i] = Sample{Sparse: &v, Target: targets[i]}
+	}
+	return data, nil
+}
+
+// Text classification from raw strings to predictions: a BagOfWords fit on the training texts feeds a network
+// trained on its vectors, and both get saved together.
+type TextPipeline struct {
+	Vectorizer *BagOfWords
+
+	hidden    int
+	learnRate float64
+	net       *MPNN
+}
+
+func initTextPipeline(hidden int, learn float64) *TextPipeline {
+	return &TextPipeline{Vectorizer: &BagOfWords{}, hidden: hidden, learnRate: learn}
+}
+
+func (p *TextPipeline) Fit(texts []string, targets [][]float64, epochs int) error {
+	if len(targets) == 0 {
+		return fmt.Errorf("no targets to fit the pipeline on")
+	}
+	if err := p.Vectorizer.fit(texts); err != nil {
+		return err
+	}
+	data, err := p.Vectorizer.samples(texts, targets)
+	if err != nil {
+		return err
+	}
+	net := initMPNN([]int{p.Vectorizer.Vocab.Len(), p.hidden, len(targets[0])}, p.learnRate)
+	if err := initTrainer(&net).Fit(data, epochs); err != nil {
+		return err
+	}
+	p.net = &net
+	return nil
+}
+
+func (p *TextPipeline) Predict(text string) ([]float64, error) {
+	if p.net == nil {
+		return nil, fmt.Errorf("pipeline hasn't been fit")
+	}
+	return p.net.PredictSparse(p.Vectorizer.vector(text))
+}
+
+// Saves the network to a .mpnn model file with the vectorizer, vocabulary and all, in its metadata.
+func (p *TextPipeline) save(path string) error {
+	if p.net == nil {
+		return fmt.Errorf("pipeline hasn't been fit")
+	}
+	vectorizer, err := json.Marshal(p.Vectorizer)
+	if err != nil {
+		return err
+	}
+	meta := p.net.metadata()
+	meta["text.vectorizer"] = string(vectorizer)
+	return saveModel(path, meta, p.net.tensors())
+}
+
+func loadTextPipeline(path string) (*TextPipeline, error) {
+	net, meta, err := loadModel(path)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := meta["text.vectorizer"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't hold a text pipeline", path)
+	}
+	vectorizer := &BagOfWords{}
+	if err := json.Unmarshal([]byte(data), vectorizer); err != nil {
+		return nil, fmt.Errorf("reading vectorizer: %w", err)
+	}
+	if vectorizer.Vocab == nil || vectorizer.Vocab.Len() != net.in || vectorizer.IDF != nil && len(vectorizer.IDF) != net.in {
+		return nil, fmt.Errorf("vectorizer doesn't match the network's %d inputs", net.in)
+	}
+	vectorizer.Vocab.reindex()
+	return &TextPipeline{Vectorizer: vectorizer, hidden: net.hidden, learnRate: net.learnRate, net: &net}, nil
+}
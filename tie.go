@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Ties the output layer's weights to the transpose of the hidden layer's, the usual trick for autoencoders: the
+// decoder is made to undo exactly what the encoder did, which halves the number of weights to learn and keeps
+// the two layers from drifting into a lopsided solution. Only possible when the network has as many outputs as
+// inputs.
+//
+// The hidden weights are the only real copy. step() adds the output layer's gradient (transposed) to the hidden
+// layer's before updating them, and outWeights is then refreshed from the result, so everything that reads the
+// weights keeps working unchanged. Model files only store the hidden weights.
+func (net *MPNN) tieWeights() error {
+	if net.in != net.out {
+		return fmt.Errorf("can't tie weights of a network with %d inputs and %d outputs", net.in, net.out)
+	}
+	if pieces(net.hidAct) != 1 || pieces(net.outAct) != 1 {
+		return fmt.Errorf("can't tie the weights of maxout layers")
+	}
+	net.tied = true
+	net.retie()
+	return nil
+}
+
+// Copies the hidden weights (transposed) over the output weights, after something changed the hidden ones.
+func (net *MPNN) retie() {
+	net.outWeights = mat.DenseCopyOf(net.hidWeights.T())
+}
@@ -0,0 +1,122 @@
+package main
+
+import "fmt"
+
+// Turns a time series into forecasting samples by sliding a window along it: each sample's input is Lookback
+// consecutive steps, and its target the Horizon steps right after them. The series is a row per time step with a
+// column per variable, see univariate() for a plain list of values.
+type Windows struct {
+	Lookback, Horizon int
+	Stride            int   // Steps between the starts of consecutive windows, 0 for 1
+	Targets           []int // Columns to forecast, nil for all of them; the input always has every column
+}
+
+// A single variable as a series of one-column rows.
+func univariate(xs []float64) [][]float64 {
+	series := make([][]float64, len(xs))
+	for i, x := range xs {
+		series[i] = []float64{x}
+	}
+	return series
+}
+
+func (w Windows) check(series [][]float64) error {
+	if w.Lookback < 1 || w.Horizon < 1 {
+		return fmt.Errorf("lookback and horizon have to be at least 1")
+	}
+	if w.Stride < 0 {
+		return fmt.Errorf("negative stride")
+	}
+	if len(series) == 0 {
+		return fmt.Errorf("empty series")
+	}
+	for t, row := range series {
+		if len(row) != len(series[0]) {
+			return fmt.Errorf("step %d has %d values, step 0 has %d", t, len(row), len(series[0]))
+		}
+	}
+	for _, c := range w.Targets {
+		if c < 0 || c >= len(series[0]) {
+			return fmt.Errorf("target column %d out of range", c)
+		}
+	}
+	return nil
+}
+
+func (w Windows) stride() int {
+	if w.Stride == 0 {
+		return 1
+	}
+	return w.Stride
+}
+
+// How many values each sample's input and target have.
+func (w Windows) sizes(columns int) (in, out int) {
+	targets := len(w.Targets)
+	if w.Targets == nil {
+		targets = columns
+	}
+	return w.Lookback * columns, w.Horizon * targets
+}
+
+// Every window of the series, oldest first. Inputs and targets are laid out a time step at a time: all of step
+// t's columns, then all of step t+1's...
+func (w Windows) samples(series [][]float64) ([]Sample, error) {
+	if err := w.check(series); err != nil {
+		return nil, err
+	}
+	var data []Sample
+	for start := 0; start+w.Lookback+w.Horizon <= len(series); start += w.stride() {
+		data = append(data, w.window(series, start))
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("series of %d steps is too short for a lookback of %d and horizon of %d",
+			len(series), w.Lookback, w.Horizon)
+	}
+	return data, nil
+}
+
+// The window starting at step start.
+func (w Windows) window(series [][]float64, start int) Sample {
+	in, out := w.sizes(len(series[0]))
+	s := Sample{Input: make([]float64, 0, in), Target: make([]float64, 0, out)}
+	for _, row := range series[start : start+w.Lookback] {
+		s.Input = append(s.Input, row...)
+	}
+	for _, row := range series[start+w.Lookback : start+w.Lookback+w.Horizon] {
+		if w.Targets == nil {
+			s.Target = append(s.Target, row...)
+			continue
+		}
+		for _, c := range w.Targets {
+			s.Target = append(s.Target, row[c])
+		}
+	}
+	return s
+}
+
+// The input for forecasting what comes after the end of the series: its last Lookback steps.
+func (w Windows) last(series [][]float64) ([]float64, error) {
+	if err := w.check(series); err != nil {
+		return nil, err
+	}
+	if len(series) < w.Lookback {
+		return nil, fmt.Errorf("series of %d steps is shorter than the lookback of %d", len(series), w.Lookback)
+	}
+	var input []float64
+	for _, row := range series[len(series)-w.Lookback:] {
+		input = append(input, row...)
+	}
+	return input, nil
+}
+
+// Sizes a network for forecasting the series with these windows, hidden neurons in between.
+func (w Windows) network(series [][]float64, hidden int, learn float64) (MPNN, error) {
+	if err := w.check(series); err != nil {
+		return MPNN{}, err
+	}
+	in, out := w.sizes(len(series[0]))
+	net := initMPNN([]int{in, hidden, out}, learn)
+	net.setActivations(Sigmoid{}, Linear{}) // Forecasts aren't limited to [0, 1]
+	return net, nil
+}
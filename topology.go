@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Architecture search by evolution, loosely after NEAT (Stanley & Miikkulainen 2002): as well as evolving the
+// weights like Evolution does, mutations add hidden neurons, remove them, and switch single connections off and
+// back on. Networks start out as the one given and grow or shrink from there, so the search answers "how small a
+// network can do this?" as much as "how big does it need to be?".
+//
+// A new neuron starts with its outgoing weights at zero, so adding one doesn't change what the network does and
+// the newcomer gets a few generations to find a use before selection judges it. Full NEAT also tracks where every
+// connection came from, to cross over networks of different shapes and to group the population into species;
+// here only networks with the same number of hidden neurons are crossed, anything else is bred by mutation alone.
+//
+// Meant for small problems, every candidate is a full evaluation of Fitness.
+type TopologySearch struct {
+	Evolution
+
+	AddNeuronRate    float64 // Chance of each child gaining a hidden neuron
+	RemoveNeuronRate float64 // and of losing one
+	ToggleRate       float64 // Chance of switching one random connection off, or back on
+	MaxHidden        int     // 0 for no limit
+
+	// Taken off the fitness for every connection, so that between two networks that do as well, the smaller
+	// wins.
+	SizePenalty float64
+}
+
+// The shape of a network TopologySearch found.
+type Architecture struct {
+	In, Hidden, Out int
+	Connections     int // Weights that are switched on, out of In·Hidden + Hidden·Out
+	Fitness         float64
+}
+
+func (a Architecture) String() string {
+	return fmt.Sprintf("%d-%d-%d, %d/%d connections, fitness %.6g", a.In, a.Hidden, a.Out, a.Connections,
+		a.In*a.Hidden+a.Hidden*a.Out, a.Fitness)
+}
+
+func initTopologySearch(fitness func(net *MPNN) float64) *TopologySearch {
+	return &TopologySearch{
+		Evolution:        *initEvolution(fitness),
+		AddNeuronRate:    0.05,
+		RemoveNeuronRate: 0.05,
+		ToggleRate:       0.1,
+	}
+}
+
+// A candidate network, with which of its connections are switched on (1) or off (0). Switched off weights are
+// kept at zero.
+type genome struct {
+	net              MPNN
+	hidMask, outMask *mat.Dense
+	fitness          float64
+}
+
+func (g *genome) connections() int {
+	return int(mat.Sum(g.hidMask) + mat.Sum(g.outMask))
+}
+
+func (g genome) copy() genome {
+	return genome{net: g.net.clone(), hidMask: mat.DenseCopyOf(g.hidMask), outMask: mat.DenseCopyOf(g.outMask),
+		fitness: g.fitness}
+}
+
+func (g *genome) architecture() Architecture {
+	return Architecture{In: g.net.in, Hidden: g.net.hidden, Out: g.net.out, Connections: g.connections(),
+		Fitness: g.fitness}
+}
+
+// Evolves net's shape and weights for the given number of generations, and replaces it with the fittest network
+// found, whose architecture is returned. The fitness includes SizePenalty.
+func (s *TopologySearch) Run(net *MPNN, generations int) (Architecture, error) {
+	if s.Population < 2 || s.Elite >= s.Population {
+		return Architecture{}, fmt.Errorf("need a population of at least 2 and fewer than %d elite", s.Population)
+	}
+	if s.Fitness == nil {
+		return Architecture{}, fmt.Errorf("no fitness function")
+	}
+	// Resizing the hidden layer would have to resize these too.
+	if net.tied || pieces(net.hidAct) != 1 {
+		return Architecture{}, fmt.Errorf("can't change the shape of a tied or maxout network")
+	}
+	if _, ok := net.hidAct.(paramActivation); ok {
+		return Architecture{}, fmt.Errorf("can't change the shape of a hidden layer with activation parameters")
+	}
+	if s.rnd == nil {
+		s.rnd = newRand()
+	}
+
+	evaluate := func(g *genome) {
+		g.fitness = s.Fitness(&g.net) - s.SizePenalty*float64(g.connections())
+		if math.IsNaN(g.fitness) {
+			g.fitness = math.Inf(-1)
+		}
+	}
+
+	pop := make([]genome, s.Population)
+	for i := range pop {
+		pop[i] = genome{net: net.clone(), hidMask: ones(net.hidWeights), outMask: ones(net.outWeights)}
+		if i > 0 {
+			s.mutateWeights(&pop[i], 1)
+		}
+		evaluate(&pop[i])
+	}
+
+	byFitness := func() {
+		sort.SliceStable(pop, func(i, j int) bool { return pop[i].fitness > pop[j].fitness })
+	}
+	for gen := 0; gen < generations; gen++ {
+		byFitness()
+		next := make([]genome, 0, s.Population)
+		next = append(next, pop[:s.Elite]...)
+		for len(next) < s.Population {
+			child := s.pickGenome(pop).copy()
+			if s.rnd.Float64() < s.CrossoverRate {
+				if other := s.pickGenome(pop); other.net.hidden == child.net.hidden {
+					s.crossover(&child, other)
+				}
+			}
+			s.mutate(&child)
+			evaluate(&child)
+			next = append(next, child)
+		}
+		pop = next
+
+		best := pop[0]
+		for _, g := range pop[1:] {
+			if g.fitness > best.fitness {
+				best = g
+			}
+		}
+		s.log().Debug("generation", "gen", gen+1, "hidden", best.net.hidden, "connections", best.connections(),
+			"best", best.fitness)
+	}
+
+	byFitness()
+	*net = pop[0].net
+	return pop[0].architecture(), nil
+}
+
+// Tournament selection, like Evolution.pick().
+func (s *TopologySearch) pickGenome(pop []genome) genome {
+	best := pop[s.rnd.Intn(len(pop))]
+	for i := 1; i < s.Tournament; i++ {
+		if g := pop[s.rnd.Intn(len(pop))]; g.fitness > best.fitness {
+			best = g
+		}
+	}
+	return best
+}
+
+// Uniform crossover of weights and connections from two parents with the same hidden layer size.
+func (s *TopologySearch) crossover(child *genome, other genome) {
+	pairs := [][4]*mat.Dense{
+		{child.net.hidWeights, child.hidMask, other.net.hidWeights, other.hidMask},
+		{child.net.outWeights, child.outMask, other.net.outWeights, other.outMask},
+	}
+	for _, p := range pairs {
+		r, c := p[0].Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if s.rnd.Float64() < 0.5 {
+					p[0].Set(i, j, p[2].At(i, j))
+					p[1].Set(i, j, p[3].At(i, j))
+				}
+			}
+		}
+	}
+}
+
+func (s *TopologySearch) mutate(g *genome) {
+	s.mutateWeights(g, s.MutationRate)
+	if s.rnd.Float64() < s.AddNeuronRate && (s.MaxHidden == 0 || g.net.hidden < s.MaxHidden) {
+		s.addNeuron(g)
+	}
+	if s.rnd.Float64() < s.RemoveNeuronRate && g.net.hidden > 1 {
+		removeNeuron(g, s.rnd.Intn(g.net.hidden))
+	}
+	if s.rnd.Float64() < s.ToggleRate {
+		s.toggle(g)
+	}
+}
+
+// Adds noise to each switched on weight with probability rate.
+func (s *TopologySearch) mutateWeights(g *genome, rate float64) {
+	for _, p := range [][2]*mat.Dense{{g.net.hidWeights, g.hidMask}, {g.net.outWeights, g.outMask}} {
+		r, c := p[0].Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if p[1].At(i, j) == 1 && s.rnd.Float64() < rate {
+					p[0].Set(i, j, p[0].At(i, j)+s.MutationScale*s.rnd.NormFloat64())
+				}
+			}
+		}
+	}
+}
+
+// A new hidden neuron, fully connected, with random incoming weights and outgoing ones at zero.
+func (s *TopologySearch) addNeuron(g *genome) {
+	n := &g.net
+	in := make([]float64, n.in)
+	for i := range in {
+		in[i] = s.MutationScale * s.rnd.NormFloat64()
+	}
+	hidW := mat.NewDense(n.hidden+1, n.in, nil)
+	hidW.Copy(n.hidWeights)
+	hidW.SetRow(n.hidden, in)
+	outW := mat.NewDense(n.out, n.hidden+1, nil)
+	outW.Copy(n.outWeights)
+
+	hidM := mat.NewDense(n.hidden+1, n.in, nil)
+	hidM.Copy(g.hidMask)
+	for j := 0; j < n.in; j++ {
+		hidM.Set(n.hidden, j, 1)
+	}
+	outM := mat.NewDense(n.out, n.hidden+1, nil)
+	outM.Copy(g.outMask)
+	for i := 0; i < n.out; i++ {
+		outM.Set(i, n.hidden, 1)
+	}
+
+	n.hidden++
+	n.hidWeights, n.outWeights, g.hidMask, g.outMask = hidW, outW, hidM, outM
+}
+
+// Takes hidden neuron k out, along with all its connections.
+func removeNeuron(g *genome, k int) {
+	n := &g.net
+	keep := make([]int, 0, n.hidden-1)
+	for i := 0; i < n.hidden; i++ {
+		if i != k {
+			keep = append(keep, i)
+		}
+	}
+	rows := func(m *mat.Dense) *mat.Dense {
+		_, c := m.Dims()
+		r := mat.NewDense(len(keep), c, nil)
+		for i, src := range keep {
+			r.SetRow(i, m.RawRowView(src))
+		}
+		return r
+	}
+	cols := func(m *mat.Dense) *mat.Dense {
+		r, _ := m.Dims()
+		c := mat.NewDense(r, len(keep), nil)
+		for j, src := range keep {
+			c.SetCol(j, mat.Col(nil, src, m))
+		}
+		return c
+	}
+	n.hidden--
+	n.hidWeights, g.hidMask = rows(n.hidWeights), rows(g.hidMask)
+	n.outWeights, g.outMask = cols(n.outWeights), cols(g.outMask)
+}
+
+// Switches one connection, picked at random from both layers, off (zeroing its weight) or back on (with a small
+// random weight).
+func (s *TopologySearch) toggle(g *genome) {
+	hr, hc := g.hidMask.Dims()
+	or, oc := g.outMask.Dims()
+	k := s.rnd.Intn(hr*hc + or*oc)
+	w, m := g.net.hidWeights, g.hidMask
+	if k >= hr*hc {
+		k -= hr * hc
+		w, m = g.net.outWeights, g.outMask
+	}
+	_, c := m.Dims()
+	i, j := k/c, k%c
+	if m.At(i, j) == 1 {
+		m.Set(i, j, 0)
+		w.Set(i, j, 0)
+	} else {
+		m.Set(i, j, 1)
+		w.Set(i, j, s.MutationScale*s.rnd.NormFloat64())
+	}
+}
+
+// A matrix of ones shaped like m.
+func ones(m *mat.Dense) *mat.Dense {
+	r, c := m.Dims()
+	o := mat.NewDense(r, c, nil)
+	o.Apply(func(_, _ int, _ float64) float64 { return 1 }, o)
+	return o
+}
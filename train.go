@@ -0,0 +1,377 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/mat"
+)
+
+// One training example: an input and the output we want the network to give for it.
+type Sample struct {
+	Input  []float64
+	Target []float64
+
+	// Used instead of Input when set. Training steps that edit the input (mixup, random erasing, adversarial
+	// training) only know about Input, so leave them off when training on sparse samples.
+	Sparse *SparseVector
+}
+
+// Trains a network with mini-batch gradient descent, either over a whole dataset with Fit() or a batch at a time
+// with PartialFit() as data arrives (e.g. from a live stream).
+type Trainer struct {
+	net *MPNN
+
+	BatchSize int // Samples averaged into each weight update by Fit()
+
+	// Shrinks the learning rate after every batch, rate = learnRate / (1 + Decay * batches so far).
+	// Zero keeps the rate fixed. Handy for online learning, where there's no "last epoch" to stop at.
+	Decay float64
+
+	// Classical momentum for plain gradient descent (no Optimizer): each step keeps going Momentum of the way the
+	// last one went, which smooths out the zigzagging of noisy batches. Zero turns it off, 0.9 is typical.
+	Momentum float64
+
+	// Shrinks every weight by rate·WeightDecay of itself after each step, pulling weights that aren't being used
+	// back towards zero. Applied separately from the gradient (Loshchilov & Hutter's decoupled weight decay), so
+	// it works the same whatever the Optimizer. Zero turns it off.
+	WeightDecay float64
+
+	// Hyperparameters that change from epoch to epoch, see Schedules.
+	Schedules Schedules
+
+	// When set, PartialFit() mixes ReplayRatio stored old samples per new sample into every batch and then
+	// remembers the new ones, so online learning doesn't forget older data.
+	Replay      *ReplayBuffer
+	ReplayRatio float64
+
+	// When set, keeps the weights that mattered for previously consolidated tasks close to their old values.
+	EWC *EWC
+
+	// When set, an auxiliary head on the hidden layer adds its loss to training, see Auxiliary.
+	Auxiliary *Auxiliary
+
+	// When set, trains the hidden layer to work the same on unlabeled data from another domain, see
+	// DomainAdaptation.
+	Domain *DomainAdaptation
+
+	// When set, Fit() starts on the easiest samples and works up to the full dataset.
+	Curriculum *Curriculum
+
+	// Mixup blends random pairs of samples in each Fit() batch, see mixup(). Zero turns it off.
+	MixupAlpha float64
+
+	// When set, Fit() blanks out random patches of image inputs, see RandomErasing.
+	Erasing *RandomErasing
+
+	// Randomly drops this fraction of the weights for each training step, see dropConnectGradients().
+	DropConnect float64
+
+	// Caps the spectral norm of each weight matrix after every update, see spectralClip(). Zero turns it off.
+	SpectralNorm float64
+
+	// Caps the length of each neuron's incoming weight vector, for the [hidden, output] layer, see maxNormClip().
+	// Zero leaves that layer alone.
+	MaxNorm [2]float64
+
+	// When set, every batch is trained on alongside adversarially perturbed copies of its samples.
+	Adversarial *AdversarialTraining
+
+	// When set, trains with differential privacy: per-sample gradient clipping and added noise, see DPSGD.
+	DP *DPSGD
+
+	// How each batch's gradient turns into a change of the weights, nil for plain gradient descent.
+	Optimizer Optimizer
+
+	// When set, keeps an exponential moving average of the weights as training goes, see EMA.Model().
+	EMA *EMA
+
+	// Goroutines to split the gradient of each batch over, see parallelGradients(). Zero works it out the plain
+	// sequential way.
+	Workers int
+
+	// Held out samples to measure the loss on after every epoch, see EpochStats.
+	Validation []Sample
+
+	// Called after every epoch of Fit(), in order.
+	Callbacks []Callback
+
+	// Stops Fit() once it has been running this long, even if it has epochs left. Zero means no limit. See
+	// FitFor() to train until the time is up and get back the best network along the way.
+	Budget time.Duration
+
+	// Fit() adds an entry after every epoch, and logs it too: at info level if Verbose is set, debug otherwise.
+	History []EpochStats
+	Verbose bool
+
+	// Where training progress and warnings go, nil for defaultLogger.
+	Logger Logger
+
+	batches   int
+	epochs    int           // How many epochs the running Fit() was asked for
+	fitStart  time.Time     // and when it started
+	trainTime time.Duration // Summed over every Fit(), for EpochStats.Elapsed
+	epoch     epochTracker
+	rnd       *rand.Rand
+	spectralU [2]*mat.VecDense // Power iteration state for each weight matrix
+	velocity  [2]*mat.Dense    // Momentum's running step for the [hidden, output] weights
+	velAct    activationGrads  // and the activations' parameters
+}
+
+func initTrainer(net *MPNN) *Trainer {
+	return &Trainer{
+		net:       net,
+		BatchSize: 1,
+		rnd:       newRand(),
+	}
+}
+
+func (t *Trainer) log() Logger {
+	if t.Logger == nil {
+		return defaultLogger
+	}
+	return t.Logger
+}
+
+// Routine progress, which only makes it past the default log level with Verbose set.
+func (t *Trainer) progress(msg string, args ...any) {
+	if t.Verbose {
+		t.log().Info(msg, args...)
+	} else {
+		t.log().Debug(msg, args...)
+	}
+}
+
+// The learning rate for the next batch.
+func (t *Trainer) rate() float64 {
+	return t.net.learnRate / (1 + t.Decay*float64(t.batches))
+}
+
+// Trains on the whole dataset for a number of epochs, visiting the samples in a new random order each epoch.
+func (t *Trainer) Fit(data []Sample, epochs int) (err error) {
+	defer func() {
+		for _, c := range t.Callbacks {
+			if c, ok := c.(fitEndCallback); ok {
+				c.OnFitEnd(t, err)
+			}
+		}
+	}()
+	if err := t.checkConfig(data); err != nil {
+		return err
+	}
+	if t.Curriculum != nil {
+		data = t.Curriculum.order(t.net, data)
+	}
+
+	t.epochs, t.fitStart = epochs, time.Now()
+	if t.DP != nil {
+		t.DP.DatasetSize = len(data)
+	}
+	for e := 0; e < epochs; e++ {
+		t.applySchedules(len(t.History))
+		start := time.Now()
+		for _, batch := range t.epochBatches(data, e) {
+			if t.outOfTime() {
+				break
+			}
+			t.update(batch)
+		}
+
+		stats := t.epoch.finish(len(t.History))
+		stats.Duration = time.Since(start)
+		t.trainTime += stats.Duration
+		stats.Elapsed = t.trainTime
+		if t.DP != nil {
+			stats.Epsilon = t.DP.Epsilon()
+		}
+		if t.Validation != nil {
+			stats.TrainLoss = t.net.meanLoss(data)
+			stats.ValLoss = t.net.meanLoss(t.Validation)
+		}
+		t.History = append(t.History, stats)
+		t.progress("epoch", stats.logArgs()...)
+
+		stop := false
+		for _, c := range t.Callbacks {
+			stop = c.OnEpochEnd(t, stats) || stop
+		}
+		if stop || t.outOfTime() {
+			break
+		}
+	}
+	return nil
+}
+
+// The batch sampler: splits one epoch's worth of samples into shuffled batches of BatchSize.
+func (t *Trainer) epochBatches(data []Sample, epoch int) [][]Sample {
+	size := t.BatchSize
+	if size < 1 {
+		size = 1
+	}
+
+	pool := data
+	if t.Curriculum != nil {
+		pool = t.Curriculum.pool(data, epoch)
+	}
+	shuffled := append([]Sample(nil), pool...)
+	t.rnd.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	var batches [][]Sample
+	for i := 0; i < len(shuffled); i += size {
+		end := i + size
+		if end > len(shuffled) {
+			end = len(shuffled)
+		}
+		batch := shuffled[i:end]
+		if t.Erasing != nil {
+			batch = t.Erasing.apply(batch, t.rnd)
+		}
+		if t.MixupAlpha > 0 {
+			batch = mixup(batch, t.MixupAlpha, t.rnd)
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// Updates the network with one batch of new samples. Can be called over and over as data comes in, the
+// network just keeps learning from wherever it left off.
+func (t *Trainer) PartialFit(batch []Sample) error {
+	if err := t.checkConfig(batch); err != nil {
+		return err
+	}
+	if t.Replay == nil {
+		t.update(batch)
+		return nil
+	}
+
+	// Old samples are drawn before the new ones go in, so a batch never replays itself.
+	n := int(math.Ceil(t.ReplayRatio * float64(len(batch))))
+	mixed := append(append([]Sample(nil), batch...), t.Replay.sample(n)...)
+	t.update(mixed)
+	for _, s := range batch {
+		t.Replay.add(s)
+	}
+	return nil
+}
+
+// One gradient descent step using the gradient averaged over the batch.
+func (t *Trainer) update(batch []Sample) {
+	if len(batch) == 0 {
+		return
+	}
+	if t.Adversarial != nil {
+		batch = t.Adversarial.augment(t.net, batch)
+	}
+	var hidGrad, outGrad *mat.Dense
+	var actGrad activationGrads
+	if t.DP != nil {
+		hidGrad, outGrad, actGrad = t.DP.gradients(t, batch)
+	} else if t.DropConnect > 0 {
+		hidGrad, outGrad, actGrad = t.dropConnectGradients(batch)
+	} else {
+		hidGrad, outGrad, actGrad = t.gradients(t.net, batch)
+	}
+	if t.EWC != nil {
+		hidPenalty, outPenalty := t.EWC.gradients(t.net)
+		hidGrad.Add(hidGrad, hidPenalty)
+		outGrad.Add(outGrad, outPenalty)
+	}
+	if t.Auxiliary != nil {
+		hidGrad.Add(hidGrad, t.Auxiliary.gradients(t.net, batch, t.rate()))
+	}
+	if t.Domain != nil {
+		hidGrad.Add(hidGrad, t.Domain.gradients(t, batch, t.rate()))
+	}
+	t.applyGradients(batch, hidGrad, outGrad, actGrad, len(batch))
+}
+
+// The gradient descent step itself, for a batch of samples whose average gradient has been worked out. batch is
+// nil when only the gradient is known (see Coordinator), which optimizers that look at the samples have to cope
+// with.
+func (t *Trainer) applyGradients(batch []Sample, hidGrad, outGrad *mat.Dense, actGrad activationGrads, samples int) {
+	t.epoch.addBatch(hidGrad, outGrad, samples)
+	switch {
+	case t.Optimizer != nil:
+		t.Optimizer.step(t, batch, hidGrad, outGrad, actGrad)
+	case t.Momentum > 0:
+		t.momentumStep(hidGrad, outGrad, actGrad)
+	default:
+		t.net.step(hidGrad, outGrad, actGrad, t.rate())
+	}
+	if t.WeightDecay > 0 {
+		f := 1 - t.rate()*t.WeightDecay
+		t.net.hidWeights.Scale(f, t.net.hidWeights)
+		if !t.net.tied {
+			t.net.outWeights.Scale(f, t.net.outWeights)
+		}
+	}
+	t.constrain()
+	if t.EMA != nil {
+		t.EMA.update(t.net)
+	}
+	t.batches++
+}
+
+// v = Momentum·v + gradient, then a step along v.
+func (t *Trainer) momentumStep(hidGrad, outGrad *mat.Dense, actGrad activationGrads) {
+	if t.velocity[0] == nil || !sameShape(t.velocity[0], hidGrad) || !sameShape(t.velocity[1], outGrad) {
+		t.velocity = [2]*mat.Dense{zeros(hidGrad), zeros(outGrad)}
+		t.velAct = activationGrads{}
+	}
+	for i, g := range []*mat.Dense{hidGrad, outGrad} {
+		t.velocity[i].Scale(t.Momentum, t.velocity[i])
+		t.velocity[i].Add(t.velocity[i], g)
+	}
+	t.velAct.scale(t.Momentum)
+	t.velAct.add(actGrad)
+	t.net.step(t.velocity[0], t.velocity[1], t.velAct, t.rate())
+}
+
+func sameShape(a, b *mat.Dense) bool {
+	ar, ac := a.Dims()
+	br, bc := b.Dims()
+	return ar == br && ac == bc
+}
+
+// Catches samples, or Trainer settings, that don't fit the network before training starts.
+func (t *Trainer) checkConfig(data []Sample) error {
+	if err := t.net.checkSamples(data); err != nil {
+		return err
+	}
+	if t.Auxiliary != nil {
+		if err := t.Auxiliary.check(); err != nil {
+			return err
+		}
+	}
+	if t.Domain != nil {
+		return t.Domain.check(t.net)
+	}
+	return nil
+}
+
+// Catches samples that don't fit the network before they turn into a panic deep in the matrix math.
+func (net *MPNN) checkSamples(data []Sample) error {
+	for i, s := range data {
+		if s.Sparse != nil {
+			if err := s.Sparse.check(net.in); err != nil {
+				return fmt.Errorf("sample %d: %w", i, err)
+			}
+		} else if len(s.Input) != net.in {
+			return fmt.Errorf("sample %d has %d inputs, network expects %d", i, len(s.Input), net.in)
+		}
+		if len(s.Target) != net.out {
+			return fmt.Errorf("sample %d has %d targets, network has %d outputs", i, len(s.Target), net.out)
+		}
+	}
+	return nil
+}
+
+// The network's loss (half the squared error unless it's been given another) for one sample, the cost the
+// network is trained on.
+func (net *MPNN) sampleLoss(s Sample) float64 {
+	out := net.forwardSample(s, nil).hidLayerWeightsOut
+	return net.lossFn().loss(mat.Col(nil, 0, out), s.Target)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// How FitTriplets() picks the triplets (anchor, positive of the same class, negative of another) in a batch.
+// Most triplets a network meets are already easy, well within the margin, and contribute nothing but noise, so
+// it pays to look for the ones that still have something to teach.
+type TripletMining int
+
+const (
+	// For every anchor, its farthest positive and nearest negative in the batch (Hermans et al. 2017). Learns
+	// fastest, but a few mislabeled samples can get picked over and over.
+	MineBatchHard TripletMining = iota
+
+	// Every anchor-positive pair, with the nearest negative that's farther away than the positive but still
+	// inside the margin (Schroff et al. 2015, FaceNet). Pairs without one are left out. Gentler than batch-hard,
+	// and steadier early on.
+	MineSemiHard
+)
+
+// Learns an embedding where samples of a class sit closer to each other than to any other class's by at least
+// Margin: max(0, d(anchor, positive) - d(anchor, negative) + Margin) with d the Euclidean distance between the
+// network's outputs. Classes come from the targets, one-hot like everywhere else.
+//
+// Every batch is ClassesPerBatch random classes with SamplesPerClass random samples of each, so every anchor has
+// positives and negatives to mine from.
+type TripletLoss struct {
+	Margin          float64
+	Mining          TripletMining
+	ClassesPerBatch int
+	SamplesPerClass int
+}
+
+// Trains the network as an embedding for classes, with triplets mined from each batch (see TripletLoss). Each
+// epoch has about as many samples as data, and its average triplet loss is logged and added to History as its
+// TrainLoss. Like FitPairs(), the network's own Loss isn't involved.
+func (t *Trainer) FitTriplets(data []Sample, loss TripletLoss, epochs int) error {
+	// The targets are only there for their classes, they don't have to be the size of the embedding.
+	for i, s := range data {
+		if len(s.Input) != t.net.in || len(s.Target) == 0 {
+			return fmt.Errorf("sample %d has %d inputs and %d targets, network expects %d inputs", i, len(s.Input),
+				len(s.Target), t.net.in)
+		}
+	}
+	if loss.ClassesPerBatch < 2 || loss.SamplesPerClass < 2 {
+		return fmt.Errorf("need at least 2 classes per batch and 2 samples per class, got %d and %d",
+			loss.ClassesPerBatch, loss.SamplesPerClass)
+	}
+	byClass := map[int][]Sample{}
+	var classes []int
+	for _, s := range data {
+		c := argmaxSlice(s.Target)
+		if byClass[c] == nil {
+			classes = append(classes, c)
+		}
+		byClass[c] = append(byClass[c], s)
+	}
+	if len(classes) < loss.ClassesPerBatch {
+		return fmt.Errorf("only %d classes in the data, %d wanted per batch", len(classes), loss.ClassesPerBatch)
+	}
+
+	perBatch := loss.ClassesPerBatch * loss.SamplesPerClass
+	for e := 0; e < epochs; e++ {
+		t.applySchedules(len(t.History))
+		start := time.Now()
+		total, batches := 0.0, 0
+		for n := 0; n < len(data); n += perBatch {
+			batch, labels := t.tripletBatch(byClass, classes, loss)
+			l, ok := t.tripletStep(batch, labels, loss)
+			if ok {
+				total += l
+				batches++
+			}
+		}
+
+		if batches > 0 {
+			total /= float64(batches)
+		}
+		t.recordEpoch(start, total)
+	}
+	return nil
+}
+
+// The batch sampler for triplets: ClassesPerBatch classes, and SamplesPerClass samples of each (repeating
+// samples only when a class has too few).
+func (t *Trainer) tripletBatch(byClass map[int][]Sample, classes []int, loss TripletLoss) ([]Sample, []int) {
+	var batch []Sample
+	var labels []int
+	for _, ci := range t.rnd.Perm(len(classes))[:loss.ClassesPerBatch] {
+		c := classes[ci]
+		samples := byClass[c]
+		order := t.rnd.Perm(len(samples))
+		for k := 0; k < loss.SamplesPerClass; k++ {
+			batch = append(batch, samples[order[k%len(order)]])
+			labels = append(labels, c)
+		}
+	}
+	return batch, labels
+}
+
+// Mines the batch's triplets and takes a step on their average loss. Returns the loss, and false if the batch
+// had no triplets at all (every candidate too easy for semi-hard mining).
+func (t *Trainer) tripletStep(batch []Sample, labels []int, loss TripletLoss) (float64, bool) {
+	caches := make([]forwardCache, len(batch))
+	emb := make([][]float64, len(batch))
+	for i, s := range batch {
+		caches[i] = t.net.forwardDropout(s.Input, t.rnd)
+		emb[i] = mat.Col(nil, 0, caches[i].hidLayerWeightsOut)
+	}
+	dist := make([][]float64, len(batch))
+	for i := range dist {
+		dist[i] = make([]float64, len(batch))
+		for j := range dist[i] {
+			dist[i][j] = floats.Distance(emb[i], emb[j], 2)
+		}
+	}
+
+	grads := make([][]float64, len(batch))
+	for i := range grads {
+		grads[i] = make([]float64, t.net.out)
+	}
+	total, triplets := 0.0, 0
+	add := func(a, p, n int) {
+		triplets++
+		l := dist[a][p] - dist[a][n] + loss.Margin
+		if l <= 0 {
+			return
+		}
+		total += l
+		// ∂d(x, y)/∂x is the unit vector from y to x.
+		pull := func(x, y int, sign float64) {
+			if d := dist[x][y]; d > 0 {
+				for k := range grads[x] {
+					g := sign * (emb[x][k] - emb[y][k]) / d
+					grads[x][k] += g
+					grads[y][k] -= g
+				}
+			}
+		}
+		pull(a, p, 1)
+		pull(a, n, -1)
+	}
+
+	for a := range batch {
+		switch loss.Mining {
+		case MineBatchHard:
+			p, n := -1, -1
+			for j := range batch {
+				if j == a {
+					continue
+				}
+				if labels[j] == labels[a] && (p < 0 || dist[a][j] > dist[a][p]) {
+					p = j
+				}
+				if labels[j] != labels[a] && (n < 0 || dist[a][j] < dist[a][n]) {
+					n = j
+				}
+			}
+			if p >= 0 && n >= 0 {
+				add(a, p, n)
+			}
+		case MineSemiHard:
+			for p := range batch {
+				if p == a || labels[p] != labels[a] {
+					continue
+				}
+				n, nearest := -1, math.Inf(1)
+				for j := range batch {
+					if d := dist[a][j]; labels[j] != labels[a] && d > dist[a][p] && d < dist[a][p]+loss.Margin && d < nearest {
+						n, nearest = j, d
+					}
+				}
+				if n >= 0 {
+					add(a, p, n)
+				}
+			}
+		}
+	}
+	if triplets == 0 {
+		return 0, false
+	}
+	h, o, a := t.net.outputGradients(caches, grads, float64(triplets))
+	t.applyGradients(nil, h, o, a, len(batch))
+	return total / float64(triplets), true
+}
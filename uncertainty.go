@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+)
+
+// Monte Carlo dropout (Gal & Ghahramani 2016): an estimate of how sure the network is about a prediction. Dropout
+// is left switched on and the input is run through the network nSamples times, each time with a different random
+// set of hidden neurons dropped. Where the network has learned something solid the answers barely change, and
+// where it's guessing they're all over the place, so the variance per output works as an uncertainty score.
+//
+// Only works for networks trained with dropout, since the spread comes from the dropout.
+func (net *MPNN) PredictWithUncertainty(input []float64, nSamples int) (mean, variance []float64, err error) {
+	if net.dropout <= 0 {
+		return nil, nil, errors.New("network has no dropout, so every pass would give the same answer")
+	}
+	if nSamples < 2 {
+		return nil, nil, errors.New("need at least 2 samples to estimate a variance")
+	}
+
+	rnd := newRand()
+	mean = make([]float64, net.out)
+	variance = make([]float64, net.out)
+
+	// Welford's algorithm, which keeps the running mean and variance numerically stable.
+	for n := 1; n <= nSamples; n++ {
+		out := net.forwardDropout(input, rnd).hidLayerWeightsOut
+		for i := range mean {
+			x := out.At(i, 0)
+			d := x - mean[i]
+			mean[i] += d / float64(n)
+			variance[i] += d * (x - mean[i])
+		}
+	}
+	for i := range variance {
+		variance[i] /= float64(nSamples - 1)
+	}
+	return mean, variance, nil
+}
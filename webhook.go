@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Posts training progress to a webhook, so a run left going overnight reports back to a chat channel. The body
+// is {"text": "..."}, which Slack incoming webhooks (and Mattermost, Discord's /slack endpoints, ...) take as is.
+// It posts a summary every Every epochs, once if the loss turns into NaN or infinity, and once when Fit() ends,
+// saying whether it finished or failed.
+//
+// A webhook that can't be reached never stops training: the error is logged and kept in Err.
+type Webhook struct {
+	URL   string
+	Name  string // Put in front of every message, to tell runs apart
+	Every int    // Post every this many epochs, 0 for only the end of training
+
+	Client *http.Client // nil uses one with a 10 second timeout
+	Err    error        // The last failed post
+
+	diverged bool
+	last     EpochStats
+	epochs   int
+}
+
+func (h *Webhook) OnEpochEnd(t *Trainer, stats EpochStats) bool {
+	h.last, h.epochs = stats, h.epochs+1
+	if bad(stats.TrainLoss) || bad(stats.ValLoss) {
+		if !h.diverged {
+			h.diverged = true
+			h.post(t, fmt.Sprintf(":warning: loss diverged at %s", stats))
+		}
+	} else if h.Every > 0 && (stats.Epoch+1)%h.Every == 0 {
+		if t.Budget > 0 {
+			// Stops when the time budget runs out, not after a number of epochs.
+			h.post(t, stats.String())
+		} else {
+			h.post(t, fmt.Sprintf("%s, %d epochs to go", stats, t.epochs-h.epochs))
+		}
+	}
+	return false
+}
+
+func (h *Webhook) OnFitEnd(t *Trainer, err error) {
+	switch {
+	case err != nil:
+		h.post(t, fmt.Sprintf(":x: training failed: %v", err))
+	case h.epochs == 0:
+		h.post(t, "training finished without running an epoch")
+	default:
+		h.post(t, fmt.Sprintf(":white_check_mark: training finished after %d epochs in %s, %s", h.epochs,
+			time.Since(t.fitStart).Round(time.Second), h.last))
+	}
+	h.epochs, h.diverged = 0, false
+}
+
+func bad(x float64) bool { return math.IsNaN(x) || math.IsInf(x, 0) }
+
+func (h *Webhook) post(t *Trainer, text string) {
+	if h.Name != "" {
+		text = "[" + h.Name + "] " + text
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		h.fail(t, err)
+		return
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(h.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		h.fail(t, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		h.fail(t, fmt.Errorf("webhook returned %s", resp.Status))
+	}
+}
+
+func (h *Webhook) fail(t *Trainer, err error) {
+	h.Err = err
+	t.log().Warn("webhook post failed", "url", h.URL, "err", err)
+}
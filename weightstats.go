@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const statsBuckets = 10
+
+// A summary of one layer's parameters, a lot easier to take in than printing the whole matrix. Healthy weights
+// are centred near 0 with a spread that depends on the initialization (around 1/√inputs for the default one).
+// Weights that have blown up, collapsed to 0, or piled up at a clip/max-norm limit all show up here at a glance.
+type WeightStats struct {
+	Name          string // As stored in model files, e.g. "hidden.weight"
+	Mean, Std     float64
+	Min, Max      float64
+	Buckets       []int // Histogram counts over statsBuckets equal-width ranges from Min to Max
+	Zeros, NonFin int   // Exact zeros (e.g. after pruning), and NaNs or infinities
+}
+
+// Statistics for every set of parameters the network saves: each weight matrix, and the parameters of learned
+// activations such as PReLU or layer normalization. The network has no bias vectors of its own.
+func (net *MPNN) Stats() []WeightStats {
+	var stats []WeightStats
+	for _, t := range net.tensors() {
+		r, c := t.m.Dims()
+		values := make([]float64, 0, r*c)
+		for i := 0; i < r; i++ {
+			values = append(values, t.m.RawRowView(i)...)
+		}
+		s := weightStats(values)
+		s.Name = t.name
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+func weightStats(values []float64) WeightStats {
+	s := WeightStats{Min: math.Inf(1), Max: math.Inf(-1), Buckets: make([]int, statsBuckets)}
+	n := 0
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			s.NonFin++
+			continue
+		}
+		if v == 0 {
+			s.Zeros++
+		}
+		n++
+		s.Mean += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	if n == 0 {
+		s.Min, s.Max = 0, 0
+		return s
+	}
+	s.Mean /= float64(n)
+
+	width := (s.Max - s.Min) / statsBuckets
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		d := v - s.Mean
+		s.Std += d * d / float64(n)
+		b := statsBuckets - 1
+		if width > 0 {
+			b = int(math.Min((v-s.Min)/width, statsBuckets-1))
+		}
+		s.Buckets[b]++
+	}
+	s.Std = math.Sqrt(s.Std)
+	return s
+}
+
+func printWeightStats(stats []WeightStats) {
+	for _, s := range stats {
+		total := s.NonFin
+		peak := 1
+		for _, c := range s.Buckets {
+			total += c
+			if c > peak {
+				peak = c
+			}
+		}
+		fmt.Printf("[%s] %d values  mean %.4f  std %.4f  min %.4f  max %.4f", s.Name, total, s.Mean, s.Std, s.Min, s.Max)
+		if s.Zeros > 0 {
+			fmt.Printf("  %d zero", s.Zeros)
+		}
+		if s.NonFin > 0 {
+			fmt.Printf("  %d NaN/Inf", s.NonFin)
+		}
+		fmt.Println()
+
+		width := (s.Max - s.Min) / statsBuckets
+		for i, c := range s.Buckets {
+			fmt.Printf("  %8.4f | %-30s %d\n", s.Min+float64(i)*width, strings.Repeat("#", c*30/peak), c)
+		}
+		fmt.Println()
+	}
+}
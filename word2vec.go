@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// The words of a corpus that occur often enough to learn anything about, most frequent first, each with its
+// position and how many times it occurred.
+type Vocabulary struct {
+	Words  []string
+	Counts []int
+
+	index map[string]int
+}
+
+// Counts every word in docs and keeps the ones seen at least minCount times. Ties in frequency go in alphabetical
+// order, so the same corpus always gives the same vocabulary.
+func buildVocabulary(docs [][]string, minCount int) *Vocabulary {
+	counts := map[string]int{}
+	for _, doc := range docs {
+		for _, w := range doc {
+			counts[w]++
+		}
+	}
+	v := &Vocabulary{}
+	for w, n := range counts {
+		if n >= minCount {
+			v.Words = append(v.Words, w)
+		}
+	}
+	sort.Slice(v.Words, func(i, j int) bool {
+		a, b := v.Words[i], v.Words[j]
+		return counts[a] > counts[b] || counts[a] == counts[b] && a < b
+	})
+	v.Counts = make([]int, len(v.Words))
+	for i, w := range v.Words {
+		v.Counts[i] = counts[w]
+	}
+	v.reindex()
+	return v
+}
+
+func (v *Vocabulary) reindex() {
+	v.index = make(map[string]int, len(v.Words))
+	for i, w := range v.Words {
+		v.index[w] = i
+	}
+}
+
+func (v *Vocabulary) Len() int { return len(v.Words) }
+
+// A word's position, or -1 for words that didn't make it in.
+func (v *Vocabulary) Index(word string) int {
+	if i, ok := v.index[word]; ok {
+		return i
+	}
+	return -1
+}
+
+// Word2vec's skip-gram with negative sampling (Mikolov et al. 2013): learns a vector for every word of a corpus
+// such that words used in similar company end up close together. Each word is trained to tell the words around it
+// (within Window) apart from Negatives random ones, drawn in proportion to their frequency to the power of ¾.
+//
+// It's an MPNN underneath: a one-hot word goes in, a Linear hidden layer of Dims neurons is the embedding layer
+// (a word's vector is its column of the hidden weights), and each output neuron says how likely its word is to
+// turn up nearby. Only the outputs of the real and sampled words are worked out and updated for each pair, rather
+// than a softmax over the whole vocabulary.
+type SkipGram struct {
+	Dims      int
+	Window    int     // How many words either side count as context, 0 for 5
+	Negatives int     // Random words per real one, 0 for 5
+	MinCount  int     // Words seen fewer times are left out, 0 for 5
+	Subsample float64 // Randomly skips very frequent words, the higher the less often (1e-3 is usual), 0 to keep all
+	Rate      float64 // Starting learning rate, falling linearly towards 0 over training, 0 for 0.025
+
+	Logger Logger
+	rnd    *rand.Rand
+}
+
+func initSkipGram(dims int) *SkipGram {
+	return &SkipGram{Dims: dims, rnd: newRand()}
+}
+
+func (s *SkipGram) defaults() {
+	for _, f := range []*int{&s.Window, &s.Negatives, &s.MinCount} {
+		if *f == 0 {
+			*f = 5
+		}
+	}
+	if s.Rate == 0 {
+		s.Rate = 0.025
+	}
+	if s.rnd == nil {
+		s.rnd = newRand()
+	}
+}
+
+func (s *SkipGram) log() Logger {
+	if s.Logger == nil {
+		return defaultLogger
+	}
+	return s.Logger
+}
+
+// Trained word vectors, see SkipGram.
+type WordEmbeddings struct {
+	Vocab *Vocabulary
+	net   MPNN
+}
+
+// Trains word vectors on sentences (or documents) of words, going over all of them epochs times.
+func (s *SkipGram) Fit(sentences [][]string, epochs int) (*WordEmbeddings, error) {
+	s.defaults()
+	vocab := buildVocabulary(sentences, s.MinCount)
+	if vocab.Len() < 2 {
+		return nil, fmt.Errorf("only %d words occur at least %d times, not enough to learn from", vocab.Len(), s.MinCount)
+	}
+	if s.Dims < 1 || s.Window < 1 || s.Negatives < 1 {
+		return nil, fmt.Errorf("dims, window and negatives have to be positive")
+	}
+	n := vocab.Len()
+	net := initMPNN([]int{n, s.Dims, n}, s.Rate)
+	net.setActivations(Linear{}, Sigmoid{})
+	// Word2vec's own starting point: small random word vectors, and context vectors at 0.
+	net.hidWeights = mat.NewDense(s.Dims, n, nil)
+	for i := 0; i < s.Dims; i++ {
+		for j := 0; j < n; j++ {
+			net.hidWeights.Set(i, j, (s.rnd.Float64()-0.5)/float64(s.Dims))
+		}
+	}
+	net.outWeights = mat.NewDense(n, s.Dims, nil)
+
+	noise := s.noiseTable(vocab)
+	total, kept := 0, 0
+	for _, sent := range sentences {
+		total += len(sent)
+	}
+	for _, c := range vocab.Counts {
+		kept += c
+	}
+	var words []int
+	seen := 0
+	in, ctx := net.hidWeights.RawMatrix(), net.outWeights.RawMatrix()
+	h, grad := make([]float64, s.Dims), make([]float64, s.Dims)
+	for e := 0; e < epochs; e++ {
+		loss, pairs := 0.0, 0
+		for _, sent := range sentences {
+			words = s.keep(vocab, kept, sent, words[:0])
+			seen += len(sent)
+			rate := s.Rate * math.Max(1e-4, 1-float64(seen)/float64(total*epochs+1))
+			for i, w := range words {
+				// Column w of the embedding layer, which is what a one-hot w gets out of it.
+				for d := range h {
+					h[d] = in.Data[d*in.Stride+w]
+				}
+				window := 1 + s.rnd.Intn(s.Window) // Nearer words count more, as in word2vec
+				for j := i - window; j <= i+window; j++ {
+					if j < 0 || j >= len(words) || j == i {
+						continue
+					}
+					for d := range grad {
+						grad[d] = 0
+					}
+					for k := 0; k <= s.Negatives; k++ {
+						c, label := words[j], 1.0
+						if k > 0 {
+							if c = sort.SearchFloat64s(noise, s.rnd.Float64()); c == words[j] {
+								continue
+							}
+							label = 0
+						}
+						u := ctx.Data[c*ctx.Stride : c*ctx.Stride+s.Dims]
+						p := sigmoid(0, 0, floats.Dot(u, h))
+						if label == 1 {
+							loss -= math.Log(math.Max(minRate, p))
+						} else {
+							loss -= math.Log(math.Max(minRate, 1-p))
+						}
+						g := rate * (label - p)
+						floats.AddScaled(grad, g, u)
+						floats.AddScaled(u, g, h)
+					}
+					pairs++
+					floats.Add(h, grad)
+				}
+				for d := range h {
+					in.Data[d*in.Stride+w] = h[d]
+				}
+			}
+		}
+		if pairs > 0 {
+			loss /= float64(pairs)
+		}
+		s.log().Debug("epoch", "epoch", e+1, "loss", loss, "pairs", pairs)
+	}
+	return &WordEmbeddings{Vocab: vocab, net: net}, nil
+}
+
+// The positions of a sentence's words, leaving out the ones not in the vocabulary and, with Subsample, some of the
+// frequent ones: a word making up a fraction f of the corpus is kept with probability √(Subsample/f) +
+// Subsample/f, so "the" and "of" stop drowning out everything else. total is the count of every vocabulary word.
+func (s *SkipGram) keep(vocab *Vocabulary, total int, sent []string, words []int) []int {
+	for _, w := range sent {
+		i := vocab.Index(w)
+		if i < 0 {
+			continue
+		}
+		if s.Subsample > 0 {
+			r := s.Subsample * float64(total) / float64(vocab.Counts[i])
+			if math.Sqrt(r)+r < s.rnd.Float64() {
+				continue
+			}
+		}
+		words = append(words, i)
+	}
+	return words
+}
+
+// The cumulative distribution of count^¾ over the vocabulary, for drawing negatives with a binary search.
+func (s *SkipGram) noiseTable(vocab *Vocabulary) []float64 {
+	cdf := make([]float64, vocab.Len())
+	sum := 0.0
+	for i, c := range vocab.Counts {
+		sum += math.Pow(float64(c), 0.75)
+		cdf[i] = sum
+	}
+	floats.Scale(1/sum, cdf)
+	cdf[len(cdf)-1] = 1
+	return cdf
+}
+
+// A word's vector, and false for words not in the vocabulary.
+func (e *WordEmbeddings) Vector(word string) ([]float64, bool) {
+	i := e.Vocab.Index(word)
+	if i < 0 {
+		return nil, false
+	}
+	return mat.Col(nil, i, e.net.hidWeights), true
+}
+
+func (e *WordEmbeddings) Dims() int { return e.net.hidden }
+
+// The average vector of the known words in a text, as an input for a downstream network: a classifier over Dims()
+// inputs gets what the embeddings learned from the whole corpus, even with few labeled texts. All zeros when none
+// of the words are known.
+func (e *WordEmbeddings) Embed(words []string) []float64 {
+	v := make([]float64, e.Dims())
+	n := 0
+	for _, w := range words {
+		if i := e.Vocab.Index(w); i >= 0 {
+			for d := range v {
+				v[d] += e.net.hidWeights.At(d, i)
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		floats.Scale(1/float64(n), v)
+	}
+	return v
+}
+
+// The k words whose vectors point the most the same way as word's, most similar first.
+func (e *WordEmbeddings) Nearest(word string, k int) ([]string, error) {
+	v, ok := e.Vector(word)
+	if !ok {
+		return nil, fmt.Errorf("%q isn't in the vocabulary", word)
+	}
+	type scored struct {
+		word string
+		cos  float64
+	}
+	var all []scored
+	for i, w := range e.Vocab.Words {
+		if w != word {
+			all = append(all, scored{w, cosine(v, mat.Col(nil, i, e.net.hidWeights))})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].cos > all[j].cos })
+	if k > len(all) {
+		k = len(all)
+	}
+	words := make([]string, k)
+	for i := range words {
+		words[i] = all[i].word
+	}
+	return words, nil
+}
+
+// Saved like any other network, with the vocabulary in the metadata.
+func (e *WordEmbeddings) save(path string) error {
+	vocab, err := json.Marshal(e.Vocab)
+	if err != nil {
+		return err
+	}
+	meta := e.net.metadata()
+	meta["word2vec.vocabulary"] = string(vocab)
+	return saveModel(path, meta, e.net.tensors())
+}
+
+func loadWordEmbeddings(path string) (*WordEmbeddings, error) {
+	net, meta, err := loadModel(path)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := meta["word2vec.vocabulary"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't hold word embeddings", path)
+	}
+	vocab := &Vocabulary{}
+	if err := json.Unmarshal([]byte(data), vocab); err != nil {
+		return nil, fmt.Errorf("reading vocabulary: %w", err)
+	}
+	if vocab.Len() != net.in || len(vocab.Counts) != len(vocab.Words) {
+		return nil, fmt.Errorf("vocabulary has %d words, network has %d inputs", vocab.Len(), net.in)
+	}
+	vocab.reindex()
+	return &WordEmbeddings{Vocab: vocab, net: net}, nil
+}